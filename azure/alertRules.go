@@ -29,9 +29,9 @@ type AlertRule struct {
 	Criteria AlertRuleCriteria `json:"criteria"`
 }
 
-func FetchAlertRules() ([]AlertRule, error) {
+func (c *Client) FetchAlertRules() ([]AlertRule, error) {
 	fmt.Println("Fetching alert rules...")
-	output, err := RunCommand("az monitor metrics alert list")
+	output, err := c.RunCommand("monitor", "metrics", "alert", "list")
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,558 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultARMEndpoint = "https://management.azure.com"
+
+// ARMClient collects resources by calling the Azure Resource Manager REST API directly instead of going through
+// the az CLI. It implements Collector and SubscriptionSource.
+type ARMClient struct {
+	// Endpoint is the ARM base URL. It can point at a sovereign cloud, or at a local stand-in during testing.
+	Endpoint       string
+	Tokens         TokenSource
+	HTTPClient     *http.Client
+	SubscriptionId string
+	// PollInterval is how long to wait between polls of long running operations such as patch assessments.
+	PollInterval time.Duration
+}
+
+func NewARMClient(endpoint string, tokens TokenSource, subscriptionId string) *ARMClient {
+	if endpoint == "" {
+		endpoint = DefaultARMEndpoint
+	}
+
+	return &ARMClient{
+		Endpoint:       strings.TrimSuffix(endpoint, "/"),
+		Tokens:         tokens,
+		HTTPClient:     &http.Client{Timeout: 2 * time.Minute},
+		SubscriptionId: subscriptionId,
+		PollInterval:   10 * time.Second,
+	}
+}
+
+type armError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// newARMError describes a failed request with the same CommandError type the CLI backend uses, so problems are
+// classified and reported the same way. The exit code is the HTTP status.
+func newARMError(method string, requestURL string, response *http.Response, body []byte) *CommandError {
+	var parsed armError
+	message := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &parsed) == nil && parsed.Error.Message != "" {
+		message = fmt.Sprintf("(%s) %s", parsed.Error.Code, parsed.Error.Message)
+	}
+
+	commandError := &CommandError{
+		Command:  fmt.Sprintf("%s %s", method, requestURL),
+		ExitCode: response.StatusCode,
+		Stderr:   message,
+		Kind:     classifyStderr(message),
+	}
+
+	switch response.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		commandError.Kind = ErrorKindAuth
+	case http.StatusNotFound:
+		commandError.Kind = ErrorKindNotFound
+	case http.StatusTooManyRequests:
+		commandError.Kind = ErrorKindThrottled
+	}
+
+	return commandError
+}
+
+// url builds the request URL of a path with the API version and any other query parameters. Absolute URLs, e.g. the
+// next page of a collection, are returned as they are.
+func (c *ARMClient) url(path string, apiVersion string, query url.Values) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+
+	values := url.Values{"api-version": {apiVersion}}
+	for key, value := range query {
+		values[key] = value
+	}
+
+	return fmt.Sprintf("%s%s?%s", c.Endpoint, path, values.Encode())
+}
+
+// do sends a request and returns the response body, retrying throttled requests like the CLI backend does.
+func (c *ARMClient) do(method string, requestURL string, body interface{}) (*http.Response, []byte, error) {
+	return c.doContext(context.Background(), method, requestURL, body)
+}
+
+// doContext is do for requests that are given up on when ctx is done, also while waiting to retry.
+func (c *ARMClient) doContext(ctx context.Context, method string, requestURL string, body interface{}) (*http.Response, []byte, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	backoff := throttleBackoff
+	for attempt := 0; ; attempt++ {
+		token, err := c.Tokens.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		request, err := http.NewRequestWithContext(ctx, method, requestURL, bytes.NewReader(payload))
+		if err != nil {
+			return nil, nil, err
+		}
+		request.Header.Set("Authorization", "Bearer "+token)
+		if body != nil {
+			request.Header.Set("Content-Type", "application/json")
+		}
+
+		response, err := c.HTTPClient.Do(request)
+		if err != nil {
+			return nil, nil, err
+		}
+		responseBody, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if response.StatusCode < 300 {
+			return response, responseBody, nil
+		}
+
+		armErr := newARMError(method, requestURL, response, responseBody)
+		if !armErr.Retryable() || attempt == maxThrottleRetries {
+			return nil, nil, armErr
+		}
+
+		wait := backoff
+		if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil {
+			wait = time.Duration(seconds) * time.Second
+		}
+		fmt.Println(fmt.Sprintf("Throttled while requesting %s, retrying in %s...", requestURL, wait))
+		err = sleep(ctx, wait)
+		if err != nil {
+			return nil, nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// sleep waits for the given duration, or returns the error of ctx when it is done first.
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *ARMClient) get(path string, apiVersion string, result interface{}) error {
+	_, body, err := c.do(http.MethodGet, c.url(path, apiVersion, nil), nil)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, result)
+}
+
+// list fetches every page of an ARM collection.
+func (c *ARMClient) list(path string, apiVersion string, query url.Values) ([]json.RawMessage, error) {
+	var result []json.RawMessage
+	next := c.url(path, apiVersion, query)
+	for next != "" {
+		_, body, err := c.do(http.MethodGet, next, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var page struct {
+			Value    []json.RawMessage `json:"value"`
+			NextLink string            `json:"nextLink"`
+		}
+		err = json.Unmarshal(body, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, page.Value...)
+		next = page.NextLink
+	}
+
+	return result, nil
+}
+
+func (c *ARMClient) subscriptionPath(provider string) string {
+	return fmt.Sprintf("/subscriptions/%s/providers/%s", c.SubscriptionId, provider)
+}
+
+// resourceGroupFromId extracts the resource group, which ARM (unlike az) does not return as a separate field.
+func resourceGroupFromId(id string) string {
+	parts := strings.Split(id, "/")
+	for i := 0; i < len(parts)-1; i++ {
+		if strings.EqualFold(parts[i], "resourceGroups") {
+			return parts[i+1]
+		}
+	}
+
+	return ""
+}
+
+type armResource struct {
+	Resource
+	Kind string `json:"kind"`
+}
+
+func (c *ARMClient) listResources(provider string, apiVersion string, query url.Values, name string, include func(raw json.RawMessage, resource armResource) bool) (map[string]Resource, error) {
+	fmt.Println(fmt.Sprintf("Fetching %s...", name))
+	err := ValidateSubscriptionId(c.SubscriptionId)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := c.list(c.subscriptionPath(provider), apiVersion, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]Resource)
+	for _, item := range items {
+		var resource armResource
+		err = json.Unmarshal(item, &resource)
+		if err != nil {
+			return nil, err
+		}
+		if include != nil && !include(item, resource) {
+			continue
+		}
+
+		resource.ResourceGroup = resourceGroupFromId(resource.Id)
+		resource.Raw = item
+		result[strings.ToLower(resource.Id)] = resource.Resource
+	}
+
+	return result, nil
+}
+
+type vmStatus struct {
+	Properties struct {
+		InstanceView struct {
+			Statuses []struct {
+				Code string `json:"code"`
+			} `json:"statuses"`
+		} `json:"instanceView"`
+	} `json:"properties"`
+}
+
+func isRunning(raw json.RawMessage) bool {
+	var status vmStatus
+	if json.Unmarshal(raw, &status) != nil {
+		return false
+	}
+
+	for _, s := range status.Properties.InstanceView.Statuses {
+		if s.Code == "PowerState/running" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// vmListQuery makes ARM include the instance view, which holds the power state, in the VM list.
+var vmListQuery = url.Values{"statusOnly": {"true"}}
+
+func (c *ARMClient) FetchVMs() (map[string]Resource, error) {
+	return c.listResources("Microsoft.Compute/virtualMachines", "2023-03-01", vmListQuery, "virtual machines", func(raw json.RawMessage, _ armResource) bool {
+		return isRunning(raw)
+	})
+}
+
+func (c *ARMClient) FetchDeallocatedVMs() (map[string]Resource, error) {
+	return c.listResources("Microsoft.Compute/virtualMachines", "2023-03-01", vmListQuery, "deallocated virtual machines", func(raw json.RawMessage, _ armResource) bool {
+		return !isRunning(raw)
+	})
+}
+
+func (c *ARMClient) FetchAKSClusters() (map[string]Resource, error) {
+	return c.listResources("Microsoft.ContainerService/managedClusters", "2023-05-01", nil, "AKS clusters", nil)
+}
+
+func (c *ARMClient) FetchMySQLServers() (map[string]Resource, error) {
+	return c.listResources("Microsoft.DBforMySQL/servers", "2017-12-01", nil, "mysql servers", nil)
+}
+
+func (c *ARMClient) FetchFlexibleMySQLServers() (map[string]Resource, error) {
+	return c.listResources("Microsoft.DBforMySQL/flexibleServers", "2021-05-01", nil, "flexible mysql servers", nil)
+}
+
+func (c *ARMClient) FetchSQLServers() (map[string]Resource, error) {
+	return c.listResources("Microsoft.Sql/servers", "2021-11-01", nil, "sql servers", nil)
+}
+
+func (c *ARMClient) FetchStorageAccounts() (map[string]Resource, error) {
+	return c.listResources("Microsoft.Storage/storageAccounts", "2022-09-01", nil, "storage accounts", nil)
+}
+
+func (c *ARMClient) FetchWebApps() (map[string]Resource, error) {
+	// az webapp list leaves out function apps, which share the Microsoft.Web/sites type.
+	return c.listResources("Microsoft.Web/sites", "2022-03-01", nil, "web apps", func(_ json.RawMessage, resource armResource) bool {
+		return !strings.Contains(strings.ToLower(resource.Kind), "functionapp")
+	})
+}
+
+func (c *ARMClient) FetchAlertRules() ([]AlertRule, error) {
+	fmt.Println("Fetching alert rules...")
+	items, err := c.list(c.subscriptionPath("Microsoft.Insights/metricAlerts"), "2018-03-01", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var alertRules []AlertRule
+	for _, item := range items {
+		var rule struct {
+			Id         string `json:"id"`
+			Name       string `json:"name"`
+			Properties struct {
+				Scopes   []string          `json:"scopes"`
+				Criteria AlertRuleCriteria `json:"criteria"`
+			} `json:"properties"`
+		}
+		err = json.Unmarshal(item, &rule)
+		if err != nil {
+			return nil, err
+		}
+
+		alertRules = append(alertRules, AlertRule{
+			Scopes:   rule.Properties.Scopes,
+			Name:     rule.Name,
+			Id:       rule.Id,
+			Criteria: rule.Properties.Criteria,
+		})
+	}
+
+	return alertRules, nil
+}
+
+func (c *ARMClient) FetchAdvisorRecommendations() (map[string][]AdvisorRecommendation, error) {
+	fmt.Println("Fetching advisor recommendations...")
+	items, err := c.list(c.subscriptionPath("Microsoft.Advisor/recommendations"), "2020-01-01", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]AdvisorRecommendation)
+	for _, item := range items {
+		var recommendation struct {
+			Properties struct {
+				AdvisorRecommendation
+				ResourceMetadata struct {
+					ResourceId string `json:"resourceId"`
+				} `json:"resourceMetadata"`
+			} `json:"properties"`
+		}
+		err = json.Unmarshal(item, &recommendation)
+		if err != nil {
+			return nil, err
+		}
+
+		rec := recommendation.Properties.AdvisorRecommendation
+		rec.ResourceGroup = resourceGroupFromId(recommendation.Properties.ResourceMetadata.ResourceId)
+		result[rec.Category] = append(result[rec.Category], rec)
+	}
+
+	return result, nil
+}
+
+func (c *ARMClient) findBackupVault(vm Resource) (*Resource, error) {
+	err := ValidateResourceId(vm.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	path := c.subscriptionPath(fmt.Sprintf("Microsoft.RecoveryServices/locations/%s/backupStatus", url.PathEscape(vm.Location)))
+	_, body, err := c.do(http.MethodPost, c.url(path, "2023-02-01", nil), map[string]string{
+		"resourceId":   vm.Id,
+		"resourceType": "VM",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var status struct {
+		VaultId string `json:"vaultId"`
+	}
+	err = json.Unmarshal(body, &status)
+	if err != nil || status.VaultId == "" {
+		return nil, err
+	}
+
+	var vault Resource
+	err = c.get(status.VaultId, "2023-04-01", &vault)
+	vault.ResourceGroup = resourceGroupFromId(vault.Id)
+
+	return &vault, err
+}
+
+func (c *ARMClient) FetchVMBackups(vms map[string]Resource) map[string]error {
+	return collectVMBackups(vms, c.findBackupVault)
+}
+
+// assessPatches starts a patch assessment and polls the long running operation until it has finished, or until ctx
+// is done.
+func (c *ARMClient) assessPatches(ctx context.Context, vm *Resource) (PatchAssessmentResult, error) {
+	var result PatchAssessmentResult
+	err := ValidateResourceId(vm.Id)
+	if err != nil {
+		return result, err
+	}
+
+	response, body, err := c.doContext(ctx, http.MethodPost, c.url(vm.Id+"/assessPatches", "2023-03-01", nil), nil)
+	if err != nil {
+		return result, err
+	}
+
+	if response.StatusCode == http.StatusOK {
+		err = json.Unmarshal(body, &result)
+		return result, err
+	}
+
+	operationURL := response.Header.Get("Azure-AsyncOperation")
+	resultURL := response.Header.Get("Location")
+	if operationURL == "" {
+		operationURL = resultURL
+	}
+	if operationURL == "" {
+		return result, errors.New(fmt.Sprintf("ARM did not return an operation to poll for the patch assessment of %s", vm.Name))
+	}
+
+	for {
+		err = sleep(ctx, c.PollInterval)
+		if err != nil {
+			return result, errors.New(fmt.Sprintf("gave up on the patch assessment of %s: %s", vm.Name, err.Error()))
+		}
+
+		var operation struct {
+			Status     string `json:"status"`
+			Properties struct {
+				Output json.RawMessage `json:"output"`
+			} `json:"properties"`
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		response, body, err = c.doContext(ctx, http.MethodGet, operationURL, nil)
+		if err != nil {
+			return result, err
+		}
+		if response.StatusCode == http.StatusAccepted {
+			continue
+		}
+
+		err = json.Unmarshal(body, &operation)
+		if err != nil {
+			return result, err
+		}
+
+		switch strings.ToLower(operation.Status) {
+		case "", "succeeded":
+			if len(operation.Properties.Output) > 0 {
+				err = json.Unmarshal(operation.Properties.Output, &result)
+				return result, err
+			}
+			if resultURL != "" && resultURL != operationURL {
+				_, body, err = c.doContext(ctx, http.MethodGet, resultURL, nil)
+				if err != nil {
+					return result, err
+				}
+			}
+			err = json.Unmarshal(body, &result)
+			return result, err
+		case "failed", "canceled":
+			return result, errors.New(fmt.Sprintf("patch assessment for %s %s: %s", vm.Name, strings.ToLower(operation.Status), operation.Error.Message))
+		}
+	}
+}
+
+func (c *ARMClient) AssessPatches(vm *Resource, patchResults chan<- PatchResult, wg *sync.WaitGroup) {
+	assessWithTimeout(vm, patchResults, wg, func(ctx context.Context) (PatchAssessmentResult, error) {
+		return c.assessPatches(ctx, vm)
+	})
+}
+
+func (c *ARMClient) FetchSubscriptions() ([]Subscription, error) {
+	items, err := c.list("/subscriptions", "2020-01-01", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var subscriptions []Subscription
+	for _, item := range items {
+		var subscription struct {
+			SubscriptionId string `json:"subscriptionId"`
+			DisplayName    string `json:"displayName"`
+			TenantId       string `json:"tenantId"`
+			State          string `json:"state"`
+		}
+		err = json.Unmarshal(item, &subscription)
+		if err != nil {
+			return nil, err
+		}
+
+		subscriptions = append(subscriptions, Subscription{
+			Id:       subscription.SubscriptionId,
+			Name:     subscription.DisplayName,
+			TenantId: subscription.TenantId,
+			State:    subscription.State,
+		})
+	}
+
+	return subscriptions, nil
+}
+
+func (c *ARMClient) FetchManagementGroupSubscriptionIds(group string) ([]string, error) {
+	err := ValidateResourceName(group)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := c.list(fmt.Sprintf("/providers/Microsoft.Management/managementGroups/%s/subscriptions", url.PathEscape(group)), "2020-05-01", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, item := range items {
+		var subscription managementGroupSubscription
+		err = json.Unmarshal(item, &subscription)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, strings.ToLower(subscription.Name))
+	}
+
+	return result, nil
+}
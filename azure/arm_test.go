@@ -0,0 +1,275 @@
+package azure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const armSubscriptionId = "00000000-0000-0000-0000-000000000001"
+
+const armVMId = "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/rg-web/providers/Microsoft.Compute/virtualMachines/vm-web-01"
+
+const armAssessment = `{"status": "Succeeded", "criticalAndSecurityPatchCount": 2, "otherPatchCount": 1, "availablePatches": [{"kbId": "5031364", "classifications": ["Security"]}]}`
+
+// newTestARMClient starts a stand-in for ARM and returns a client pointed at it.
+func newTestARMClient(t *testing.T, handler http.Handler) *ARMClient {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	client := NewARMClient(server.URL, StaticToken("test-token"), armSubscriptionId)
+	client.PollInterval = time.Millisecond
+
+	return client
+}
+
+func vmJSON(name string, powerState string) string {
+	return fmt.Sprintf(`{"id": "/subscriptions/%s/resourceGroups/rg-web/providers/Microsoft.Compute/virtualMachines/%s", "name": "%s", "location": "westeurope", "properties": {"instanceView": {"statuses": [{"code": "ProvisioningState/succeeded"}, {"code": "%s"}]}}}`,
+		armSubscriptionId, name, name, powerState)
+}
+
+func TestARMListFollowsNextLink(t *testing.T) {
+	var firstQuery string
+	mux := http.NewServeMux()
+	var client *ARMClient
+	mux.HandleFunc("/subscriptions/"+armSubscriptionId+"/providers/Microsoft.Compute/virtualMachines", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			t.Errorf("got Authorization %q", r.Header.Get("Authorization"))
+		}
+		firstQuery = r.URL.RawQuery
+		fmt.Fprintf(w, `{"value": [%s], "nextLink": "%s/next-page?page=2"}`, vmJSON("vm-web-01", "PowerState/running"), client.Endpoint)
+	})
+	mux.HandleFunc("/next-page", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "2" {
+			t.Errorf("the next link was not followed as it is: %s", r.URL)
+		}
+		fmt.Fprintf(w, `{"value": [%s, %s]}`, vmJSON("vm-web-02", "PowerState/running"), vmJSON("vm-old", "PowerState/deallocated"))
+	})
+	client = newTestARMClient(t, mux)
+
+	vms, err := client.FetchVMs()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(vms) != 2 {
+		t.Fatalf("got %d running VMs, want 2: %v", len(vms), vms)
+	}
+	vm := vms[strings.ToLower(armVMId)]
+	if vm.Name != "vm-web-01" || vm.ResourceGroup != "rg-web" {
+		t.Errorf("unexpected VM: %+v", vm)
+	}
+	if !strings.Contains(string(vm.Raw), `"instanceView"`) {
+		t.Errorf("got raw JSON %s, want the resource as ARM returned it", vm.Raw)
+	}
+
+	if firstQuery != "api-version=2023-03-01&statusOnly=true" {
+		t.Errorf("got query %q, want the API version and statusOnly as separate parameters", firstQuery)
+	}
+}
+
+func TestARMRetriesThrottledRequests(t *testing.T) {
+	var requests int32
+	client := newTestARMClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error": {"code": "TooManyRequests", "message": "Rate limit exceeded"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"value": [{"subscriptionId": "00000000-0000-0000-0000-000000000001", "displayName": "Prod-Web", "tenantId": "tenant-a", "state": "Enabled"}]}`)
+	}))
+
+	subscriptions, err := client.FetchSubscriptions()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if requests != 3 {
+		t.Errorf("got %d requests, want 3", requests)
+	}
+	if len(subscriptions) != 1 || subscriptions[0].Name != "Prod-Web" || subscriptions[0].TenantId != "tenant-a" {
+		t.Errorf("unexpected subscriptions: %+v", subscriptions)
+	}
+}
+
+func TestARMGivesUpWhenThrottled(t *testing.T) {
+	var requests int32
+	client := newTestARMClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Retry-After", "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+
+	_, err := client.FetchSubscriptions()
+
+	var commandError *CommandError
+	if !errors.As(err, &commandError) || commandError.Kind != ErrorKindThrottled {
+		t.Fatalf("got %v, want a throttled error", err)
+	}
+	if requests != maxThrottleRetries+1 {
+		t.Errorf("got %d requests, want %d", requests, maxThrottleRetries+1)
+	}
+}
+
+func TestARMDoesNotRetryOtherErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		kind   ErrorKind
+	}{
+		{http.StatusUnauthorized, ErrorKindAuth},
+		{http.StatusForbidden, ErrorKindAuth},
+		{http.StatusNotFound, ErrorKindNotFound},
+		{http.StatusInternalServerError, ErrorKindUnknown},
+	}
+
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			var requests int32
+			client := newTestARMClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				w.WriteHeader(test.status)
+				fmt.Fprint(w, `{"error": {"code": "Failed", "message": "request failed"}}`)
+			}))
+
+			_, err := client.FetchStorageAccounts()
+
+			var commandError *CommandError
+			if !errors.As(err, &commandError) || commandError.Kind != test.kind || commandError.ExitCode != test.status {
+				t.Fatalf("got %v, want a %s error", err, test.kind)
+			}
+			if !strings.Contains(commandError.Stderr, "(Failed) request failed") {
+				t.Errorf("got message %q", commandError.Stderr)
+			}
+			if requests != 1 {
+				t.Errorf("got %d requests, want 1", requests)
+			}
+		})
+	}
+}
+
+func TestARMAssessPatches(t *testing.T) {
+	tests := []struct {
+		name       string
+		operations []string // the responses to the polls, the last one repeats
+		location   bool     // whether the result is fetched from the Location header
+	}{
+		{"output", []string{"", `{"status": "InProgress"}`, `{"status": "Succeeded", "properties": {"output": ` + armAssessment + `}}`}, false},
+		{"location", []string{`{"status": "InProgress"}`, `{"status": "Succeeded"}`}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var polls int32
+			var client *ARMClient
+			mux := http.NewServeMux()
+			mux.HandleFunc(armVMId+"/assessPatches", func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost {
+					t.Errorf("got %s, want POST", r.Method)
+				}
+				w.Header().Set("Azure-AsyncOperation", client.Endpoint+"/operations/1")
+				if test.location {
+					w.Header().Set("Location", client.Endpoint+"/operations/1/result")
+				}
+				w.WriteHeader(http.StatusAccepted)
+			})
+			mux.HandleFunc("/operations/1", func(w http.ResponseWriter, r *http.Request) {
+				poll := int(atomic.AddInt32(&polls, 1)) - 1
+				if poll >= len(test.operations) {
+					poll = len(test.operations) - 1
+				}
+				// An empty response means the operation is still accepted.
+				if test.operations[poll] == "" {
+					w.WriteHeader(http.StatusAccepted)
+					return
+				}
+				fmt.Fprint(w, test.operations[poll])
+			})
+			mux.HandleFunc("/operations/1/result", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, armAssessment)
+			})
+			client = newTestARMClient(t, mux)
+
+			result, err := client.assessPatches(context.Background(), &Resource{Id: armVMId, Name: "vm-web-01"})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if int(polls) != len(test.operations) {
+				t.Errorf("got %d polls, want %d", polls, len(test.operations))
+			}
+			if result.Status != "Succeeded" || result.CriticalAndSecurityPatchCount != 2 || len(result.AvailablePatches) != 1 {
+				t.Errorf("unexpected result: %+v", result)
+			}
+		})
+	}
+}
+
+func TestARMAssessPatchesCompletesImmediately(t *testing.T) {
+	client := newTestARMClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, armAssessment)
+	}))
+
+	result, err := client.assessPatches(context.Background(), &Resource{Id: armVMId, Name: "vm-web-01"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.CriticalAndSecurityPatchCount != 2 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestARMAssessPatchesFailed(t *testing.T) {
+	var client *ARMClient
+	client = newTestARMClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Header().Set("Location", client.Endpoint+"/operations/1")
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		fmt.Fprint(w, `{"status": "Failed", "error": {"message": "the VM agent is not ready"}}`)
+	}))
+
+	_, err := client.assessPatches(context.Background(), &Resource{Id: armVMId, Name: "vm-web-01"})
+	if err == nil || !strings.Contains(err.Error(), "failed: the VM agent is not ready") {
+		t.Errorf("got %v, want the reason the assessment failed", err)
+	}
+}
+
+func TestARMAssessPatchesStopsAtDeadline(t *testing.T) {
+	var polls int32
+	var client *ARMClient
+	client = newTestARMClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Header().Set("Azure-AsyncOperation", client.Endpoint+"/operations/1")
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		atomic.AddInt32(&polls, 1)
+		fmt.Fprint(w, `{"status": "InProgress"}`)
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.assessPatches(ctx, &Resource{Id: armVMId, Name: "vm-web-01"})
+	if err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Fatalf("got %v, want an error once the deadline has passed", err)
+	}
+
+	// No more polls are sent once the assessment has been given up on. A poll already in flight may still arrive.
+	time.Sleep(10 * time.Millisecond)
+	after := atomic.LoadInt32(&polls)
+	time.Sleep(20 * time.Millisecond)
+	if atomic.LoadInt32(&polls) != after {
+		t.Error("the operation is still polled after the deadline")
+	}
+}
@@ -0,0 +1,158 @@
+package azure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	envAccessToken  = "AZURE_ACCESS_TOKEN"
+	envTenantId     = "AZURE_TENANT_ID"
+	envClientId     = "AZURE_CLIENT_ID"
+	envClientSecret = "AZURE_CLIENT_SECRET"
+
+	DefaultAuthorityURL   = "https://login.microsoftonline.com"
+	armManagementResource = "https://management.azure.com/"
+)
+
+// TokenSource supplies bearer tokens for ARM requests.
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// StaticToken is a token obtained elsewhere, e.g. from a pipeline's service connection.
+type StaticToken string
+
+func (t StaticToken) Token() (string, error) {
+	return string(t), nil
+}
+
+// ClientCredentials requests tokens for a service principal and caches them until shortly before they expire.
+type ClientCredentials struct {
+	TenantId     string
+	ClientId     string
+	ClientSecret string
+	AuthorityURL string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (c *ClientCredentials) Token() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+
+	authority := c.AuthorityURL
+	if authority == "" {
+		authority = DefaultAuthorityURL
+	}
+
+	response, err := http.PostForm(fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimSuffix(authority, "/"), url.PathEscape(c.TenantId)), url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {c.ClientId},
+		"client_secret": {c.ClientSecret},
+		"scope":         {armManagementResource + ".default"},
+	})
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		ErrorDescription string `json:"error_description"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&body)
+	if err != nil {
+		return "", err
+	}
+
+	if response.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return "", &CommandError{
+			Command:  "client credentials token request",
+			ExitCode: response.StatusCode,
+			Stderr:   body.ErrorDescription,
+			Kind:     ErrorKindAuth,
+		}
+	}
+
+	c.token = body.AccessToken
+	c.expires = time.Now().Add(time.Duration(body.ExpiresIn)*time.Second - time.Minute)
+
+	return c.token, nil
+}
+
+// CLIToken borrows the token of the account logged into the az CLI. Only the login is needed from az; all other
+// requests go to ARM directly.
+type CLIToken struct {
+	Runner Runner
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (c *CLIToken) Token() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+
+	output, err := c.Runner.Run("account", "get-access-token", "--resource", armManagementResource)
+	if err != nil {
+		return "", err
+	}
+
+	var body struct {
+		AccessToken string `json:"accessToken"`
+		ExpiresOn   int64  `json:"expires_on"`
+	}
+	err = json.Unmarshal(output, &body)
+	if err != nil {
+		return "", err
+	}
+	if body.AccessToken == "" {
+		return "", errors.New("az account get-access-token did not return a token")
+	}
+
+	c.token = body.AccessToken
+	c.expires = time.Now().Add(5 * time.Minute)
+	if body.ExpiresOn > 0 {
+		c.expires = time.Unix(body.ExpiresOn, 0).Add(-time.Minute)
+	}
+
+	return c.token, nil
+}
+
+// DefaultTokenSource picks a token source from the environment: a ready-made token in AZURE_ACCESS_TOKEN, a
+// service principal in AZURE_TENANT_ID/AZURE_CLIENT_ID/AZURE_CLIENT_SECRET, or else the az CLI login.
+func DefaultTokenSource(runner Runner) TokenSource {
+	if token := os.Getenv(envAccessToken); token != "" {
+		return StaticToken(token)
+	}
+
+	if os.Getenv(envClientId) != "" && os.Getenv(envClientSecret) != "" && os.Getenv(envTenantId) != "" {
+		return &ClientCredentials{
+			TenantId:     os.Getenv(envTenantId),
+			ClientId:     os.Getenv(envClientId),
+			ClientSecret: os.Getenv(envClientSecret),
+		}
+	}
+
+	return &CLIToken{Runner: runner}
+}
@@ -2,8 +2,8 @@ package azure
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
+	"strings"
 	"sync"
 )
 
@@ -13,45 +13,68 @@ type VMBackupResult struct {
 	err              error
 }
 
-func FetchBackupsForVM(vmId string, backups chan<- VMBackupResult, wg *sync.WaitGroup) {
-	fmt.Println(fmt.Sprintf("Checking backups for VM %s...", vmId))
-	output, err := RunCommand(fmt.Sprintf("az backup protection check-vm --vm %s", vmId))
-	backupVaultId := string(output)
+// parseVaultId reads the output of check-vm, which is either empty or the vault ID encoded as a JSON string.
+func parseVaultId(output []byte) string {
+	trimmed := strings.TrimSpace(string(output))
 
+	var vaultId string
+	if json.Unmarshal([]byte(trimmed), &vaultId) == nil {
+		return vaultId
+	}
+
+	return trimmed
+}
+
+// findBackupVault returns the vault protecting a VM, or nil if it is not backed up.
+func (c *Client) findBackupVault(vm Resource) (*Resource, error) {
+	err := ValidateResourceId(vm.Id)
 	if err != nil {
-		fmt.Println(err.Error())
-		backups <- VMBackupResult{nil, vmId, err}
-		wg.Done()
-		return
+		return nil, err
 	}
 
-	if backupVaultId != "" {
-		output, err := FetchResourceDetails(backupVaultId)
+	output, err := c.RunCommand("backup", "protection", "check-vm", "--vm", vm.Id)
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			backups <- VMBackupResult{nil, vmId, err}
-		}
+	backupVaultId := parseVaultId(output)
+	if backupVaultId == "" {
+		return nil, nil
+	}
 
-		var vault Resource
-		err = json.Unmarshal(output, &vault)
-		if err == nil {
-			fmt.Println(fmt.Sprintf("Found backup vault for %s.", vmId))
-		}
-		backups <- VMBackupResult{&vault, vmId, err}
-	} else {
-		fmt.Println(fmt.Sprintf("No backup vault for %s.", vmId))
-		backups <- VMBackupResult{nil, vmId, errors.New(fmt.Sprintf("No backup vault provided for VM %s", vmId))}
+	output, err = c.FetchResourceDetails(backupVaultId)
+	if err != nil {
+		return nil, err
 	}
-	wg.Done()
+
+	var vault Resource
+	err = json.Unmarshal(output, &vault)
+
+	return &vault, err
 }
 
-func FetchVMBackups(vms map[string]Resource) error {
+// collectVMBackups looks up the backup vault of every VM concurrently and fills it in. VMs whose backup status
+// could not be determined are returned with the reason, keyed by VM ID.
+func collectVMBackups(vms map[string]Resource, findVault func(vm Resource) (*Resource, error)) map[string]error {
 	backups := make(chan VMBackupResult, len(vms))
 	var wg sync.WaitGroup
 
-	for id := range vms {
+	for id, vm := range vms {
 		wg.Add(1)
-		go FetchBackupsForVM(id, backups, &wg)
+		go func(id string, vm Resource) {
+			defer wg.Done()
+			fmt.Println(fmt.Sprintf("Checking backups for VM %s...", id))
+
+			vault, err := findVault(vm)
+			if err != nil {
+				fmt.Println(err.Error())
+			} else if vault != nil {
+				fmt.Println(fmt.Sprintf("Found backup vault for %s.", id))
+			} else {
+				fmt.Println(fmt.Sprintf("No backup vault for %s.", id))
+			}
+			backups <- VMBackupResult{vault, id, err}
+		}(id, vm)
 	}
 
 	go func() {
@@ -59,13 +82,23 @@ func FetchVMBackups(vms map[string]Resource) error {
 		wg.Wait()
 	}()
 
+	failures := make(map[string]error)
 	for b := range backups {
-		if b.err == nil {
-			vm := vms[b.VirtualMachineId]
-			vm.BackupVault = b.Vault
-			vms[b.VirtualMachineId] = vm
+		if b.err != nil {
+			failures[b.VirtualMachineId] = b.err
+			continue
 		}
+
+		vm := vms[b.VirtualMachineId]
+		vm.BackupVault = b.Vault
+		vms[b.VirtualMachineId] = vm
 	}
 
-	return nil
+	return failures
+}
+
+// FetchVMBackups fills in the backup vault of every VM that has one. VMs whose backup status could not be
+// determined are returned with the reason, keyed by VM ID.
+func (c *Client) FetchVMBackups(vms map[string]Resource) map[string]error {
+	return collectVMBackups(vms, c.findBackupVault)
 }
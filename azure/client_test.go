@@ -0,0 +1,341 @@
+package azure
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const fixtureSubscriptionId = "00000000-0000-0000-0000-000000000001"
+
+const fixtureVMId = "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/rg-web/providers/Microsoft.Compute/virtualMachines/vm-web-01"
+
+func loadFixtureClient(t *testing.T, subscriptionId string) (*Client, *FakeRunner) {
+	t.Helper()
+
+	runner, err := LoadFakeRunner(filepath.Join("testdata", "cli"))
+	if err != nil {
+		t.Fatalf("could not load fixtures: %s", err)
+	}
+
+	return NewClient(runner, subscriptionId), runner
+}
+
+func TestFetchResources(t *testing.T) {
+	client, _ := loadFixtureClient(t, fixtureSubscriptionId)
+
+	tests := []struct {
+		name  string
+		fetch func() (map[string]Resource, error)
+		names []string
+	}{
+		{"vms", client.FetchVMs, []string{"VM-WEB-02", "vm-web-01"}},
+		{"deallocated vms", client.FetchDeallocatedVMs, []string{"vm-old"}},
+		{"aks", client.FetchAKSClusters, nil},
+		{"mysql", client.FetchMySQLServers, nil},
+		{"flexible mysql", client.FetchFlexibleMySQLServers, nil},
+		{"sql", client.FetchSQLServers, []string{"sql-web"}},
+		{"storage", client.FetchStorageAccounts, []string{"stweb"}},
+		{"webapps", client.FetchWebApps, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resources, err := test.fetch()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			var names []string
+			for id, resource := range resources {
+				if id != strings.ToLower(resource.Id) {
+					t.Errorf("resource %s is keyed by %s, want the lower-cased ID", resource.Id, id)
+				}
+				names = append(names, resource.Name)
+			}
+			sort.Strings(names)
+
+			if strings.Join(names, ",") != strings.Join(test.names, ",") {
+				t.Errorf("got %v, want %v", names, test.names)
+			}
+		})
+	}
+}
+
+func TestFetchVMsParsesFields(t *testing.T) {
+	client, _ := loadFixtureClient(t, fixtureSubscriptionId)
+
+	vms, err := client.FetchVMs()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	vm, ok := vms[strings.ToLower(fixtureVMId)]
+	if !ok {
+		t.Fatalf("vm-web-01 missing from %v", vms)
+	}
+	if vm.ResourceGroup != "rg-web" || vm.Location != "westeurope" || vm.Type != "Microsoft.Compute/virtualMachines" {
+		t.Errorf("unexpected fields: %+v", vm)
+	}
+	if vm.Tags["environment"] != "production" {
+		t.Errorf("got tags %v, want environment=production", vm.Tags)
+	}
+}
+
+func TestFetchResourcesKeepsRawJSON(t *testing.T) {
+	client, _ := loadFixtureClient(t, fixtureSubscriptionId)
+
+	accounts, err := client.FetchStorageAccounts()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for _, account := range accounts {
+		var raw struct {
+			Sku struct {
+				Name string `json:"name"`
+			} `json:"sku"`
+			MinimumTlsVersion string `json:"minimumTlsVersion"`
+		}
+		err = json.Unmarshal(account.Raw, &raw)
+		if err != nil {
+			t.Fatalf("could not parse the raw JSON of %s: %s", account.Name, err)
+		}
+		if raw.Sku.Name != "Standard_LRS" || raw.MinimumTlsVersion != "TLS1_0" {
+			t.Errorf("got raw JSON %s, want the properties az returned", account.Raw)
+		}
+	}
+}
+
+func TestFetchAlertRules(t *testing.T) {
+	client, _ := loadFixtureClient(t, fixtureSubscriptionId)
+
+	alertRules, err := client.FetchAlertRules()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(alertRules) != 1 {
+		t.Fatalf("got %d alert rules, want 1", len(alertRules))
+	}
+
+	rule := alertRules[0]
+	if rule.Name != "cpu-high" || len(rule.Scopes) != 2 || !rule.Criteria.Enabled {
+		t.Errorf("unexpected alert rule: %+v", rule)
+	}
+	if len(rule.Criteria.AllOf) != 1 || rule.Criteria.AllOf[0].MetricName != "Percentage CPU" || rule.Criteria.AllOf[0].Threshold != 80 {
+		t.Errorf("unexpected criteria: %+v", rule.Criteria)
+	}
+
+	vms, err := client.FetchVMs()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	AssignAlertRulesToResources(alertRules, vms)
+
+	// Scopes are matched case-insensitively, so both VMs get the rule.
+	for id, vm := range vms {
+		if len(vm.AlertRules) != 1 {
+			t.Errorf("%s has %d alert rules, want 1", id, len(vm.AlertRules))
+		}
+	}
+}
+
+func TestFetchAdvisorRecommendations(t *testing.T) {
+	client, _ := loadFixtureClient(t, fixtureSubscriptionId)
+
+	recommendations, err := client.FetchAdvisorRecommendations()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(recommendations["HighAvailability"]) != 2 || len(recommendations["Cost"]) != 1 {
+		t.Fatalf("unexpected grouping: %v", recommendations)
+	}
+
+	recommendation := recommendations["Cost"][0]
+	if recommendation.Description.Problem != "Use lifecycle management" || recommendation.AffectedResource != "stweb" ||
+		recommendation.ResourceType != "Microsoft.Storage/storageAccounts" || recommendation.Impact != "Low" {
+		t.Errorf("unexpected recommendation: %+v", recommendation)
+	}
+}
+
+func TestFetchVMBackups(t *testing.T) {
+	client, _ := loadFixtureClient(t, fixtureSubscriptionId)
+
+	vms, err := client.FetchVMs()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	failures := client.FetchVMBackups(vms)
+	if len(failures) != 0 {
+		t.Fatalf("unexpected failures: %v", failures)
+	}
+
+	for id, vm := range vms {
+		if id == strings.ToLower(fixtureVMId) {
+			if vm.BackupVault == nil || vm.BackupVault.Name != "vault-web" {
+				t.Errorf("got vault %+v for %s, want vault-web", vm.BackupVault, id)
+			}
+		} else if vm.BackupVault != nil {
+			t.Errorf("got vault %+v for %s, want none", vm.BackupVault, id)
+		}
+	}
+}
+
+func TestFetchVMBackupsReportsFailures(t *testing.T) {
+	client, runner := loadFixtureClient(t, fixtureSubscriptionId)
+	runner.AddError("az backup protection check-vm --vm "+fixtureVMId+" --subscription "+fixtureSubscriptionId, errors.New("vault unavailable"))
+
+	vms, err := client.FetchVMs()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	failures := client.FetchVMBackups(vms)
+	if len(failures) != 1 || failures[strings.ToLower(fixtureVMId)] == nil {
+		t.Errorf("got failures %v, want one for vm-web-01", failures)
+	}
+}
+
+func TestParseVaultId(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"", ""},
+		{"\n", ""},
+		{`"/subscriptions/x/vaults/v"`, "/subscriptions/x/vaults/v"},
+		{"/subscriptions/x/vaults/v\n", "/subscriptions/x/vaults/v"},
+	}
+
+	for _, test := range tests {
+		if got := parseVaultId([]byte(test.output)); got != test.want {
+			t.Errorf("parseVaultId(%q) = %q, want %q", test.output, got, test.want)
+		}
+	}
+}
+
+func TestAssessPatches(t *testing.T) {
+	client, _ := loadFixtureClient(t, fixtureSubscriptionId)
+
+	vm := Resource{Id: fixtureVMId, Name: "vm-web-01", ResourceGroup: "rg-web"}
+	patchResults := make(chan PatchResult, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	client.AssessPatches(&vm, patchResults, &wg)
+
+	result := <-patchResults
+	if result.Err != nil {
+		t.Fatalf("unexpected error: %s", result.Err)
+	}
+
+	assessment := vm.PatchAssessmentResult
+	if assessment.Status != "Succeeded" || assessment.CriticalAndSecurityPatchCount != 1 || len(assessment.AvailablePatches) != 2 {
+		t.Errorf("unexpected assessment: %+v", assessment)
+	}
+	if assessment.AvailablePatches[0].KbId != "5031364" || assessment.AvailablePatches[0].Classifications[0] != "Security" {
+		t.Errorf("unexpected patch: %+v", assessment.AvailablePatches[0])
+	}
+}
+
+func TestAssessPatchesMissingFixture(t *testing.T) {
+	client, _ := loadFixtureClient(t, fixtureSubscriptionId)
+
+	vm := Resource{Name: "vm-unknown", ResourceGroup: "rg-web"}
+	patchResults := make(chan PatchResult, 1)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	client.AssessPatches(&vm, patchResults, &wg)
+
+	if result := <-patchResults; result.Err == nil {
+		t.Error("expected an error for a VM without a fixture")
+	}
+}
+
+func TestDiscoverSubscriptions(t *testing.T) {
+	client, _ := loadFixtureClient(t, "")
+
+	tests := []struct {
+		name   string
+		filter SubscriptionFilter
+		want   []string
+	}{
+		{"enabled only", SubscriptionFilter{}, []string{"Prod-Web", "Dev-Sandbox"}},
+		{"include", SubscriptionFilter{Include: []string{"prod-*"}}, []string{"Prod-Web"}},
+		{"exclude", SubscriptionFilter{Exclude: []string{"*sandbox"}}, []string{"Prod-Web"}},
+		{"management group", SubscriptionFilter{ManagementGroup: "mg-prod"}, []string{"Prod-Web"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			subscriptions, err := DiscoverSubscriptions(client, test.filter)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			var names []string
+			for _, subscription := range subscriptions {
+				names = append(names, subscription.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(test.want, ",") {
+				t.Errorf("got %v, want %v", names, test.want)
+			}
+		})
+	}
+
+	_, err := DiscoverSubscriptions(client, SubscriptionFilter{TenantId: "tenant-c"})
+	if err == nil {
+		t.Error("expected an error when nothing matches")
+	}
+}
+
+func TestFetchManagementGroupSubscriptionIds(t *testing.T) {
+	client, _ := loadFixtureClient(t, "")
+
+	ids, err := client.FetchManagementGroupSubscriptionIds("mg-prod")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The second entry has no name, so its ID is taken from the path.
+	want := []string{fixtureSubscriptionId, "00000000-0000-0000-0000-000000000003"}
+	if strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+}
+
+func TestResolveSubscriptions(t *testing.T) {
+	client, _ := loadFixtureClient(t, "")
+
+	subscriptions, err := ResolveSubscriptions(client, []string{strings.ToUpper(fixtureSubscriptionId), "00000000-0000-0000-0000-000000000009"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if subscriptions[0].Name != "Prod-Web" || subscriptions[0].TenantId != "tenant-a" {
+		t.Errorf("unexpected subscription: %+v", subscriptions[0])
+	}
+	if subscriptions[1].Name != "" || subscriptions[1].DisplayName() != "00000000-0000-0000-0000-000000000009" {
+		t.Errorf("unknown subscription should keep its ID only: %+v", subscriptions[1])
+	}
+}
+
+func TestRunCommandAddsSubscription(t *testing.T) {
+	client, runner := loadFixtureClient(t, fixtureSubscriptionId)
+
+	_, err := client.FetchWebApps()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	calls := runner.Calls()
+	want := "az webapp list --subscription " + fixtureSubscriptionId
+	if len(calls) != 1 || calls[0] != want {
+		t.Errorf("got calls %v, want [%s]", calls, want)
+	}
+}
@@ -0,0 +1,26 @@
+package azure
+
+import "sync"
+
+// Collector gathers the resources of a single subscription. Client does this through the az CLI and ARMClient
+// through the Azure Resource Manager REST API; both return the same types.
+type Collector interface {
+	FetchVMs() (map[string]Resource, error)
+	FetchDeallocatedVMs() (map[string]Resource, error)
+	FetchAKSClusters() (map[string]Resource, error)
+	FetchMySQLServers() (map[string]Resource, error)
+	FetchFlexibleMySQLServers() (map[string]Resource, error)
+	FetchSQLServers() (map[string]Resource, error)
+	FetchStorageAccounts() (map[string]Resource, error)
+	FetchWebApps() (map[string]Resource, error)
+	FetchAlertRules() ([]AlertRule, error)
+	FetchVMBackups(vms map[string]Resource) map[string]error
+	FetchAdvisorRecommendations() (map[string][]AdvisorRecommendation, error)
+	AssessPatches(vm *Resource, patchResults chan<- PatchResult, wg *sync.WaitGroup)
+}
+
+// SubscriptionSource lists the subscriptions available for discovery.
+type SubscriptionSource interface {
+	FetchSubscriptions() ([]Subscription, error)
+	FetchManagementGroupSubscriptionIds(group string) ([]string, error)
+}
@@ -1,18 +1,87 @@
 package azure
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"os/exec"
-	"runtime"
+	"strings"
+	"time"
 )
 
-func Execute(command string) ([]byte, error) {
-	if runtime.GOOS == "windows" {
-		return exec.Command("powershell", command).Output()
+// Throttled commands are retried this many times, waiting twice as long before each attempt.
+const (
+	maxThrottleRetries = 3
+	throttleBackoff    = 2 * time.Second
+)
+
+// Runner executes the az CLI with the given arguments and returns its standard output. Arguments are passed to
+// the process as-is; no shell is involved, so they never need quoting.
+type Runner interface {
+	Run(args ...string) ([]byte, error)
+}
+
+// ExecRunner runs the az executable found on the PATH.
+type ExecRunner struct{}
+
+func (r ExecRunner) Run(args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("az", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if err == nil {
+		return stdout.Bytes(), nil
 	}
 
-	return exec.Command("bash", "-c", command).Output()
+	var exitError *exec.ExitError
+	if errors.As(err, &exitError) {
+		return stdout.Bytes(), NewCommandError(args, exitError.ExitCode(), stderr.String())
+	}
+
+	if errors.Is(err, exec.ErrNotFound) {
+		commandError := NewCommandError(args, -1, err.Error())
+		commandError.Kind = ErrorKindCLIMissing
+		return nil, commandError
+	}
+
+	return nil, err
 }
 
-func RunCommand(command string) ([]byte, error) {
-	return Execute(command)
+// CommandLine renders an argument list the way it would be typed, for logging and for keying recordings.
+func CommandLine(args []string) string {
+	return "az " + strings.Join(args, " ")
+}
+
+// Client collects resources from Azure using whichever Runner it was created with.
+type Client struct {
+	Runner Runner
+	// SubscriptionId is passed to every command with --subscription, so clients for different subscriptions can
+	// run side by side without touching the user's active az account. Commands use the active account when empty.
+	SubscriptionId string
+}
+
+func NewClient(runner Runner, subscriptionId string) *Client {
+	return &Client{Runner: runner, SubscriptionId: subscriptionId}
+}
+
+func (c *Client) RunCommand(args ...string) ([]byte, error) {
+	if c.SubscriptionId != "" {
+		args = append(args[:len(args):len(args)], "--subscription", c.SubscriptionId)
+	}
+
+	backoff := throttleBackoff
+	for attempt := 0; ; attempt++ {
+		output, err := c.Runner.Run(args...)
+
+		var commandError *CommandError
+		if err == nil || attempt == maxThrottleRetries || !errors.As(err, &commandError) || !commandError.Retryable() {
+			return output, err
+		}
+
+		fmt.Println(fmt.Sprintf("Throttled while running %s, retrying in %s...", CommandLine(args), backoff))
+		time.Sleep(backoff)
+		backoff *= 2
+	}
 }
@@ -0,0 +1,103 @@
+package azure
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type ErrorKind string
+
+const (
+	ErrorKindUnknown          ErrorKind = "unknown"
+	ErrorKindCLIMissing       ErrorKind = "az cli missing"
+	ErrorKindAuth             ErrorKind = "auth"
+	ErrorKindNotFound         ErrorKind = "not found"
+	ErrorKindThrottled        ErrorKind = "throttled"
+	ErrorKindExtensionMissing ErrorKind = "extension missing"
+)
+
+// CommandError describes a failed az invocation, keeping the stderr output that Output() would otherwise discard.
+type CommandError struct {
+	Command  string
+	ExitCode int
+	Stderr   string
+	Kind     ErrorKind
+}
+
+// Patterns are checked in order, so the more specific classifications come first.
+var errorKindPatterns = []struct {
+	kind     ErrorKind
+	patterns []string
+}{
+	{ErrorKindExtensionMissing, []string{"is misspelled or not recognized by the system", "az extension add", "requires the extension", "extension is not installed"}},
+	{ErrorKindThrottled, []string{"toomanyrequests", "too many requests", "throttl", "status code 429", "retry after"}},
+	{ErrorKindAuth, []string{"az login", "aadsts", "authorizationfailed", "does not have authorization", "invalidauthenticationtoken", "expiredauthenticationtoken", "token has expired", "forbidden", "unauthorized"}},
+	{ErrorKindNotFound, []string{"resourcenotfound", "resourcegroupnotfound", "subscriptionnotfound", "could not be found", "not found", "doesn't exist", "does not exist"}},
+}
+
+func classifyStderr(stderr string) ErrorKind {
+	lower := strings.ToLower(stderr)
+	for _, candidate := range errorKindPatterns {
+		for _, pattern := range candidate.patterns {
+			if strings.Contains(lower, pattern) {
+				return candidate.kind
+			}
+		}
+	}
+
+	return ErrorKindUnknown
+}
+
+func NewCommandError(args []string, exitCode int, stderr string) *CommandError {
+	return &CommandError{
+		Command:  CommandLine(args),
+		ExitCode: exitCode,
+		Stderr:   strings.TrimSpace(stderr),
+		Kind:     classifyStderr(stderr),
+	}
+}
+
+func (e *CommandError) Error() string {
+	message := e.Stderr
+	if message == "" {
+		message = "no error output"
+	}
+	// az prints a single ERROR line followed by suggestions, the first line is the interesting one.
+	message = strings.TrimSpace(strings.SplitN(message, "\n", 2)[0])
+
+	return fmt.Sprintf("%s failed (exit code %d, %s): %s", e.Command, e.ExitCode, e.Kind, message)
+}
+
+// Retryable reports whether running the same command again later is likely to succeed.
+func (e *CommandError) Retryable() bool {
+	return e.Kind == ErrorKindThrottled
+}
+
+// Hint suggests what the user can do about the failure.
+func (e *CommandError) Hint() string {
+	switch e.Kind {
+	case ErrorKindCLIMissing:
+		return "Install the Azure CLI and make sure az is on your PATH: https://learn.microsoft.com/cli/azure/install-azure-cli"
+	case ErrorKindAuth:
+		return "Run 'az login' (or refresh your credentials) and check that your account has Reader access to the subscription."
+	case ErrorKindNotFound:
+		return "Check that the subscription or resource exists and that you are logged into the right tenant."
+	case ErrorKindThrottled:
+		return "Azure is throttling requests. Wait a few minutes and try again."
+	case ErrorKindExtensionMissing:
+		return "A required az extension or command group is missing. Run 'az upgrade' or install it with 'az extension add --name <extension>'."
+	}
+
+	return ""
+}
+
+// Describe formats an error for the user, adding the hint when it came from the az CLI.
+func Describe(err error) string {
+	var commandError *CommandError
+	if errors.As(err, &commandError) && commandError.Hint() != "" {
+		return fmt.Sprintf("%s\n%s", err.Error(), commandError.Hint())
+	}
+
+	return err.Error()
+}
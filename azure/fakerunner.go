@@ -0,0 +1,96 @@
+package azure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FixtureIndexFile maps az commands to the files holding their canned output.
+const FixtureIndexFile = "commands.json"
+
+// FakeRunner answers az commands with canned output instead of calling Azure. It is safe for concurrent use,
+// since the backup and patch checks issue commands from several goroutines.
+type FakeRunner struct {
+	mu        sync.Mutex
+	responses map[string][]byte
+	errors    map[string]error
+	calls     []string
+}
+
+func NewFakeRunner() *FakeRunner {
+	return &FakeRunner{
+		responses: make(map[string][]byte),
+		errors:    make(map[string]error),
+	}
+}
+
+// LoadFakeRunner reads a fixture directory. The directory must contain a commands.json file mapping each command
+// line (as rendered by CommandLine) to the file containing its output, e.g. {"az aks list": "aks.json"}.
+func LoadFakeRunner(dir string) (*FakeRunner, error) {
+	index, err := os.ReadFile(filepath.Join(dir, FixtureIndexFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var files map[string]string
+	err = json.Unmarshal(index, &files)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("could not parse %s: %s", FixtureIndexFile, err.Error()))
+	}
+
+	runner := NewFakeRunner()
+	for command, file := range files {
+		output, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		runner.Add(command, output)
+	}
+
+	return runner, nil
+}
+
+func (f *FakeRunner) Add(command string, output []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.responses[command] = output
+}
+
+func (f *FakeRunner) AddError(command string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.errors[command] = err
+}
+
+// Calls returns every command the runner has been asked to run, in order.
+func (f *FakeRunner) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]string(nil), f.calls...)
+}
+
+func (f *FakeRunner) Run(args ...string) ([]byte, error) {
+	command := CommandLine(args)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, command)
+
+	if err, ok := f.errors[command]; ok {
+		return nil, err
+	}
+
+	if output, ok := f.responses[command]; ok {
+		return output, nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("no fixture for command: %s", command))
+}
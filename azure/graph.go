@@ -0,0 +1,222 @@
+package azure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const graphPageSize = 1000
+
+// inventoryTypes are the resource types the checker reports on. The inventory is limited to them, like the list
+// commands it replaces, so a subscription full of other resources does not page through rows that are thrown away.
+var inventoryTypes = []string{
+	"Microsoft.Compute/virtualMachines",
+	"Microsoft.ContainerService/managedClusters",
+	"Microsoft.DBforMySQL/servers",
+	"Microsoft.DBforMySQL/flexibleServers",
+	"Microsoft.Sql/servers",
+	"Microsoft.Storage/storageAccounts",
+	"Microsoft.Web/sites",
+}
+
+// inventoryQuery returns every resource of the inventoryTypes, with the VM power state flattened so running and
+// deallocated machines can be told apart.
+var inventoryQuery = fmt.Sprintf(`Resources
+| where type in~ ('%s')
+| project id, name, type, kind, resourceGroup, location, subscriptionId, tags, sku, properties, powerState = tostring(properties.extended.instanceView.powerState.code)
+| order by id asc`, strings.ToLower(strings.Join(inventoryTypes, "', '")))
+
+// GraphQuerier runs Azure Resource Graph queries across subscriptions, returning every row of every page.
+type GraphQuerier interface {
+	QueryResourceGraph(query string, subscriptionIds []string) ([]json.RawMessage, error)
+}
+
+type graphPage struct {
+	Data []json.RawMessage `json:"data"`
+	// az returns the continuation token as skip_token, the REST API as $skipToken.
+	SkipToken     string `json:"skip_token"`
+	RESTSkipToken string `json:"$skipToken"`
+}
+
+func (p graphPage) nextToken() string {
+	if p.SkipToken != "" {
+		return p.SkipToken
+	}
+
+	return p.RESTSkipToken
+}
+
+func (c *Client) QueryResourceGraph(query string, subscriptionIds []string) ([]json.RawMessage, error) {
+	var result []json.RawMessage
+	skipToken := ""
+	for {
+		args := []string{"graph", "query", "-q", query, "--first", fmt.Sprint(graphPageSize), "--subscriptions"}
+		args = append(args, subscriptionIds...)
+		if skipToken != "" {
+			args = append(args, "--skip-token", skipToken)
+		}
+
+		output, err := c.RunCommand(args...)
+		if err != nil {
+			return nil, err
+		}
+
+		var page graphPage
+		err = json.Unmarshal(output, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, page.Data...)
+		skipToken = page.nextToken()
+		if skipToken == "" {
+			return result, nil
+		}
+	}
+}
+
+func (c *ARMClient) QueryResourceGraph(query string, subscriptionIds []string) ([]json.RawMessage, error) {
+	var result []json.RawMessage
+	skipToken := ""
+	for {
+		options := map[string]interface{}{"$top": graphPageSize, "resultFormat": "objectArray"}
+		if skipToken != "" {
+			options["$skipToken"] = skipToken
+		}
+
+		_, body, err := c.do(http.MethodPost, c.url("/providers/Microsoft.ResourceGraph/resources", "2021-03-01", nil), map[string]interface{}{
+			"subscriptions": subscriptionIds,
+			"query":         query,
+			"options":       options,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var page graphPage
+		err = json.Unmarshal(body, &page)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, page.Data...)
+		skipToken = page.nextToken()
+		if skipToken == "" {
+			return result, nil
+		}
+	}
+}
+
+type inventoryResource struct {
+	Resource
+	Kind           string `json:"kind"`
+	SubscriptionId string `json:"subscriptionId"`
+	PowerState     string `json:"powerState"`
+}
+
+// Inventory holds the resources of several subscriptions, collected with a few Resource Graph queries instead of
+// one list command per resource type and subscription.
+type Inventory struct {
+	resources map[string][]inventoryResource // keyed by lower case subscription ID
+}
+
+func FetchInventory(querier GraphQuerier, subscriptionIds []string) (*Inventory, error) {
+	if len(subscriptionIds) == 0 {
+		return nil, errors.New("no subscriptions to take an inventory of")
+	}
+
+	fmt.Println(fmt.Sprintf("Fetching resource inventory for %d subscriptions from Resource Graph...", len(subscriptionIds)))
+	rows, err := querier.QueryResourceGraph(inventoryQuery, subscriptionIds)
+	if err != nil {
+		return nil, err
+	}
+
+	inventory := &Inventory{resources: make(map[string][]inventoryResource)}
+	for _, row := range rows {
+		var resource inventoryResource
+		err = json.Unmarshal(row, &resource)
+		if err != nil {
+			return nil, err
+		}
+		resource.Raw = row
+
+		subscriptionId := strings.ToLower(resource.SubscriptionId)
+		inventory.resources[subscriptionId] = append(inventory.resources[subscriptionId], resource)
+	}
+	fmt.Println(fmt.Sprintf("Found %d resources.", len(rows)))
+
+	return inventory, nil
+}
+
+// Resources returns the resources of one type in a subscription, optionally narrowed down further by include.
+func (i *Inventory) Resources(subscriptionId string, resourceType string, include func(kind string, powerState string) bool) map[string]Resource {
+	result := make(map[string]Resource)
+	for _, resource := range i.resources[strings.ToLower(subscriptionId)] {
+		if !strings.EqualFold(resource.Type, resourceType) {
+			continue
+		}
+		if include != nil && !include(resource.Kind, resource.PowerState) {
+			continue
+		}
+
+		result[strings.ToLower(resource.Id)] = resource.Resource
+	}
+
+	return result
+}
+
+// GraphCollector answers the resource list fetchers from an Inventory and leaves everything that is not in
+// Resource Graph (alert rules, backups, patches and recommendations) to the wrapped Collector.
+type GraphCollector struct {
+	Collector
+	Inventory      *Inventory
+	SubscriptionId string
+}
+
+func (g GraphCollector) list(resourceType string, name string, include func(kind string, powerState string) bool) (map[string]Resource, error) {
+	fmt.Println(fmt.Sprintf("Fetching %s from inventory...", name))
+
+	return g.Inventory.Resources(g.SubscriptionId, resourceType, include), nil
+}
+
+func (g GraphCollector) FetchVMs() (map[string]Resource, error) {
+	return g.list("Microsoft.Compute/virtualMachines", "virtual machines", func(_ string, powerState string) bool {
+		return powerState == "PowerState/running"
+	})
+}
+
+func (g GraphCollector) FetchDeallocatedVMs() (map[string]Resource, error) {
+	return g.list("Microsoft.Compute/virtualMachines", "deallocated virtual machines", func(_ string, powerState string) bool {
+		return powerState != "PowerState/running"
+	})
+}
+
+func (g GraphCollector) FetchAKSClusters() (map[string]Resource, error) {
+	return g.list("Microsoft.ContainerService/managedClusters", "AKS clusters", nil)
+}
+
+func (g GraphCollector) FetchMySQLServers() (map[string]Resource, error) {
+	return g.list("Microsoft.DBforMySQL/servers", "mysql servers", nil)
+}
+
+func (g GraphCollector) FetchFlexibleMySQLServers() (map[string]Resource, error) {
+	return g.list("Microsoft.DBforMySQL/flexibleServers", "flexible mysql servers", nil)
+}
+
+func (g GraphCollector) FetchSQLServers() (map[string]Resource, error) {
+	return g.list("Microsoft.Sql/servers", "sql servers", nil)
+}
+
+func (g GraphCollector) FetchStorageAccounts() (map[string]Resource, error) {
+	return g.list("Microsoft.Storage/storageAccounts", "storage accounts", nil)
+}
+
+func (g GraphCollector) FetchWebApps() (map[string]Resource, error) {
+	// az webapp list leaves out function apps, which share the Microsoft.Web/sites type.
+	return g.list("Microsoft.Web/sites", "web apps", func(kind string, _ string) bool {
+		return !strings.Contains(strings.ToLower(kind), "functionapp")
+	})
+}
@@ -0,0 +1,106 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeQuerier answers a Resource Graph query with the rows whose type the query asks for, like Resource Graph would.
+type fakeQuerier struct {
+	rows  []string
+	query string
+}
+
+func (f *fakeQuerier) QueryResourceGraph(query string, _ []string) ([]json.RawMessage, error) {
+	f.query = query
+
+	var result []json.RawMessage
+	for _, row := range f.rows {
+		var resource inventoryResource
+		err := json.Unmarshal([]byte(row), &resource)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(query, "'"+strings.ToLower(resource.Type)+"'") {
+			result = append(result, json.RawMessage(row))
+		}
+	}
+
+	return result, nil
+}
+
+func graphRow(resourceType string, name string, extra string) string {
+	return fmt.Sprintf(`{"id": "/subscriptions/%s/resourceGroups/rg-web/providers/%s/%s", "name": "%s", "type": "%s", "subscriptionId": "%s"%s}`,
+		fixtureSubscriptionId, resourceType, name, name, strings.ToLower(resourceType), fixtureSubscriptionId, extra)
+}
+
+func TestInventoryScope(t *testing.T) {
+	querier := &fakeQuerier{rows: []string{
+		graphRow("Microsoft.Compute/virtualMachines", "vm-web-01", `, "powerState": "PowerState/running"`),
+		graphRow("Microsoft.Compute/virtualMachines", "vm-old", `, "powerState": "PowerState/deallocated"`),
+		graphRow("Microsoft.ContainerService/managedClusters", "aks-web", ""),
+		graphRow("Microsoft.DBforMySQL/servers", "mysql-web", ""),
+		graphRow("Microsoft.DBforMySQL/flexibleServers", "mysqlflex-web", ""),
+		graphRow("Microsoft.Sql/servers", "sql-web", `, "properties": {"minimalTlsVersion": "1.2"}`),
+		graphRow("Microsoft.Storage/storageAccounts", "stweb", ""),
+		graphRow("Microsoft.Web/sites", "app-web", `, "kind": "app"`),
+		graphRow("Microsoft.Web/sites", "func-web", `, "kind": "functionapp"`),
+		// The checker does not report on these, so the query leaves them out.
+		graphRow("Microsoft.KeyVault/vaults", "kv-web", ""),
+		graphRow("Microsoft.Network/virtualNetworks", "vnet-web", ""),
+		graphRow("Microsoft.Compute/disks", "vm-web-01-osdisk", ""),
+	}}
+
+	inventory, err := FetchInventory(querier, []string{fixtureSubscriptionId})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(querier.query, "| where type in~ (") {
+		t.Errorf("the query does not limit the inventory to the checked types: %s", querier.query)
+	}
+
+	collector := GraphCollector{Inventory: inventory, SubscriptionId: fixtureSubscriptionId}
+	tests := []struct {
+		name  string
+		fetch func() (map[string]Resource, error)
+		names []string
+	}{
+		{"vms", collector.FetchVMs, []string{"vm-web-01"}},
+		{"deallocated vms", collector.FetchDeallocatedVMs, []string{"vm-old"}},
+		{"aks", collector.FetchAKSClusters, []string{"aks-web"}},
+		{"mysql", collector.FetchMySQLServers, []string{"mysql-web"}},
+		{"flexible mysql", collector.FetchFlexibleMySQLServers, []string{"mysqlflex-web"}},
+		{"sql", collector.FetchSQLServers, []string{"sql-web"}},
+		{"storage", collector.FetchStorageAccounts, []string{"stweb"}},
+		{"webapps", collector.FetchWebApps, []string{"app-web"}},
+	}
+
+	total := 0
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resources, err := test.fetch()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			var names []string
+			for _, resource := range resources {
+				names = append(names, resource.Name)
+				if len(resource.Raw) == 0 {
+					t.Errorf("%s has no raw JSON", resource.Name)
+				}
+			}
+			if strings.Join(names, ",") != strings.Join(test.names, ",") {
+				t.Errorf("got %v, want %v", names, test.names)
+			}
+			total += len(resources)
+		})
+	}
+
+	// Everything the query returns is reported on, apart from the function app az webapp list leaves out too.
+	if fetched := len(inventory.resources[fixtureSubscriptionId]); fetched != total+1 {
+		t.Errorf("the inventory holds %d resources, want the %d reported on and the function app", fetched, total)
+	}
+}
@@ -18,9 +18,9 @@ type AdvisorRecommendation struct {
 	Category         string           `json:"category"`
 }
 
-func FetchAdvisorRecommendations() (map[string][]AdvisorRecommendation, error) {
+func (c *Client) FetchAdvisorRecommendations() (map[string][]AdvisorRecommendation, error) {
 	fmt.Println("Fetching advisor recommendations...")
-	output, err := RunCommand("az advisor recommendation list")
+	output, err := c.RunCommand("advisor", "recommendation", "list")
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,93 @@
+package azure
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var nonFilenameCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+
+// RecordingRunner passes commands through to another Runner and saves the output of every successful command to
+// a fixture directory that LoadFakeRunner can replay later.
+type RecordingRunner struct {
+	Inner Runner
+	Dir   string
+
+	mu    sync.Mutex
+	files map[string]string
+}
+
+// NewRecordingRunner records into dir. The commands already recorded there are kept, so several runners can record
+// into the same directory one after the other without losing each other's index entries.
+func NewRecordingRunner(inner Runner, dir string) (*RecordingRunner, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string)
+	index, err := os.ReadFile(filepath.Join(dir, FixtureIndexFile))
+	if err == nil {
+		err = json.Unmarshal(index, &files)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("could not parse %s in %s: %s", FixtureIndexFile, dir, err.Error()))
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return &RecordingRunner{
+		Inner: inner,
+		Dir:   dir,
+		files: files,
+	}, nil
+}
+
+// fixtureFilename builds a readable, unique filename for a command.
+func fixtureFilename(command string) string {
+	slug := strings.Trim(nonFilenameCharacters.ReplaceAllString(strings.ToLower(command), "-"), "-")
+	if len(slug) > 60 {
+		slug = slug[:60]
+	}
+
+	sum := sha1.Sum([]byte(command))
+
+	return fmt.Sprintf("%s-%x.json", slug, sum[:4])
+}
+
+func (r *RecordingRunner) Run(args ...string) ([]byte, error) {
+	command := CommandLine(args)
+	output, err := r.Inner.Run(args...)
+	if err != nil {
+		return output, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	filename := fixtureFilename(command)
+	writeErr := os.WriteFile(filepath.Join(r.Dir, filename), output, 0644)
+	if writeErr != nil {
+		fmt.Println(fmt.Sprintf("Could not record output of %q: %s", command, writeErr.Error()))
+		return output, nil
+	}
+	r.files[command] = filename
+
+	// The index is rewritten after every command so that an interrupted run still leaves a usable recording.
+	index, writeErr := json.MarshalIndent(r.files, "", "  ")
+	if writeErr == nil {
+		writeErr = os.WriteFile(filepath.Join(r.Dir, FixtureIndexFile), index, 0644)
+	}
+	if writeErr != nil {
+		fmt.Println(fmt.Sprintf("Could not update recording index: %s", writeErr.Error()))
+	}
+
+	return output, nil
+}
@@ -0,0 +1,73 @@
+package azure
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordingRunnerReplays(t *testing.T) {
+	dir := t.TempDir()
+	inner := NewFakeRunner()
+	inner.Add("az account list --all", []byte(`[{"id": "a"}]`))
+	inner.Add("az graph query -q Resources", []byte(`{"data": []}`))
+
+	// Discovery and the inventory used to record with runners of their own, the second one dropping the index
+	// entries of the first.
+	for _, args := range [][]string{{"account", "list", "--all"}, {"graph", "query", "-q", "Resources"}} {
+		recorder, err := NewRecordingRunner(inner, dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		_, err = recorder.Run(args...)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	// Failed commands are not recorded.
+	recorder, err := NewRecordingRunner(inner, dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	_, err = recorder.Run("aks", "list")
+	if err == nil {
+		t.Fatal("expected the error of the inner runner")
+	}
+
+	replay, err := LoadFakeRunner(dir)
+	if err != nil {
+		t.Fatalf("could not load the recording: %s", err)
+	}
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"account", "list", "--all"}, `[{"id": "a"}]`},
+		{[]string{"graph", "query", "-q", "Resources"}, `{"data": []}`},
+	}
+	for _, test := range tests {
+		output, err := replay.Run(test.args...)
+		if err != nil {
+			t.Errorf("%s was not recorded: %s", CommandLine(test.args), err)
+		} else if string(output) != test.want {
+			t.Errorf("%s replayed %q, want %q", CommandLine(test.args), output, test.want)
+		}
+	}
+	if _, err := replay.Run("aks", "list"); err == nil {
+		t.Error("a failed command was recorded")
+	}
+}
+
+func TestRecordingRunnerRejectsBrokenIndex(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, FixtureIndexFile), []byte("{"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewRecordingRunner(NewFakeRunner(), dir)
+	if err == nil {
+		t.Error("expected an error for an index that cannot be parsed")
+	}
+}
@@ -7,31 +7,47 @@ import (
 )
 
 type Resource struct {
-	Id                    string `json:"id"`
-	Type                  string `json:"type"`
-	Name                  string `json:"name"`
-	ResourceGroup         string `json:"resourceGroup"`
-	AlertRules            []AlertRule
-	BackupVault           *Resource             // For VMs only, I'll separate this later.
-	PatchAssessmentResult PatchAssessmentResult // For VMs only
+	Id                    string                `json:"id"`
+	Type                  string                `json:"type"`
+	Name                  string                `json:"name"`
+	ResourceGroup         string                `json:"resourceGroup"`
+	Location              string                `json:"location"`
+	Tags                  map[string]string     `json:"tags,omitempty"`
+	AlertRules            []AlertRule           `json:"alertRules,omitempty"`
+	BackupVault           *Resource             `json:"backupVault,omitempty"` // For VMs only, I'll separate this later.
+	PatchAssessmentResult PatchAssessmentResult `json:"patchAssessmentResult"` // For VMs only
+	// Raw is the resource as Azure returned it, so custom rules can test properties the fields above leave out.
+	Raw json.RawMessage `json:"raw,omitempty"`
 }
 
-func getResourceList(command string) ([]Resource, error) {
-	output, err := RunCommand(command)
+func (c *Client) getResourceList(args []string) ([]Resource, error) {
+	output, err := c.RunCommand(args...)
 
 	if err != nil {
 		return nil, err
 	}
 
-	var vms []Resource
-	err = json.Unmarshal(output, &vms)
+	var items []json.RawMessage
+	err = json.Unmarshal(output, &items)
+	if err != nil {
+		return nil, err
+	}
+
+	vms := make([]Resource, len(items))
+	for i, item := range items {
+		err = json.Unmarshal(item, &vms[i])
+		if err != nil {
+			return nil, err
+		}
+		vms[i].Raw = item
+	}
 
-	return vms, err
+	return vms, nil
 }
 
-func getResourceMap(command string, name string) (map[string]Resource, error) {
+func (c *Client) getResourceMap(args []string, name string) (map[string]Resource, error) {
 	fmt.Println(fmt.Sprintf("Fetching %s...", name))
-	vms, err := getResourceList(command)
+	vms, err := c.getResourceList(args)
 
 	if err != nil {
 		return nil, err
@@ -45,64 +61,67 @@ func getResourceMap(command string, name string) (map[string]Resource, error) {
 	return result, nil
 }
 
-func FetchVMs() (map[string]Resource, error) {
-	command := "az vm list -d --query \"[?powerState=='VM running']\""
+func (c *Client) FetchVMs() (map[string]Resource, error) {
+	args := []string{"vm", "list", "-d", "--query", "[?powerState=='VM running']"}
 	resourceName := "virtual machines"
 
-	return getResourceMap(command, resourceName)
+	return c.getResourceMap(args, resourceName)
 }
 
-func FetchDeallocatedVMs() (map[string]Resource, error) {
-	command := "az vm list -d --query \"[?powerState!='VM running']\""
+func (c *Client) FetchDeallocatedVMs() (map[string]Resource, error) {
+	args := []string{"vm", "list", "-d", "--query", "[?powerState!='VM running']"}
 	resourceName := "deallocated virtual machines"
 
-	return getResourceMap(command, resourceName)
+	return c.getResourceMap(args, resourceName)
 }
 
-func FetchAKSClusters() (map[string]Resource, error) {
-	command := "az aks list"
+func (c *Client) FetchAKSClusters() (map[string]Resource, error) {
+	args := []string{"aks", "list"}
 	resourceName := "AKS clusters"
 
-	return getResourceMap(command, resourceName)
+	return c.getResourceMap(args, resourceName)
 }
 
-func FetchMySQLServers() (map[string]Resource, error) {
-	command := "az mysql server list"
+func (c *Client) FetchMySQLServers() (map[string]Resource, error) {
+	args := []string{"mysql", "server", "list"}
 	resourceName := "mysql servers"
 
-	return getResourceMap(command, resourceName)
+	return c.getResourceMap(args, resourceName)
 }
 
-func FetchFlexibleMySQLServers() (map[string]Resource, error) {
-	command := "az mysql flexible-server list"
+func (c *Client) FetchFlexibleMySQLServers() (map[string]Resource, error) {
+	args := []string{"mysql", "flexible-server", "list"}
 	resourceName := "flexible mysql servers"
 
-	return getResourceMap(command, resourceName)
+	return c.getResourceMap(args, resourceName)
 }
 
-func FetchSQLServers() (map[string]Resource, error) {
-	command := "az sql server list"
+func (c *Client) FetchSQLServers() (map[string]Resource, error) {
+	args := []string{"sql", "server", "list"}
 	resourceName := "sql servers"
 
-	return getResourceMap(command, resourceName)
+	return c.getResourceMap(args, resourceName)
 }
 
-func FetchStorageAccounts() (map[string]Resource, error) {
-	command := "az storage account list"
+func (c *Client) FetchStorageAccounts() (map[string]Resource, error) {
+	args := []string{"storage", "account", "list"}
 	resourceName := "storage accounts"
 
-	return getResourceMap(command, resourceName)
+	return c.getResourceMap(args, resourceName)
 }
 
-func FetchWebApps() (map[string]Resource, error) {
-	command := "az webapp list"
+func (c *Client) FetchWebApps() (map[string]Resource, error) {
+	args := []string{"webapp", "list"}
 	resourceName := "web apps"
 
-	return getResourceMap(command, resourceName)
+	return c.getResourceMap(args, resourceName)
 }
 
-func FetchResourceDetails(resourceId string) ([]byte, error) {
-	command := fmt.Sprintf("az resource show --ids %s", resourceId)
+func (c *Client) FetchResourceDetails(resourceId string) ([]byte, error) {
+	err := ValidateResourceId(resourceId)
+	if err != nil {
+		return nil, err
+	}
 
-	return RunCommand(command)
+	return c.RunCommand("resource", "show", "--ids", resourceId)
 }
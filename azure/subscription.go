@@ -1,14 +1,187 @@
 package azure
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"path"
+	"strings"
 )
 
-func SetSubscription(subscriptionId string) error {
-	_, err := Execute(fmt.Sprintf("az account set --subscription %s", subscriptionId))
-	if err != nil {
-		return err
+type Subscription struct {
+	Id       string `json:"id"`
+	Name     string `json:"name"`
+	TenantId string `json:"tenantId"`
+	State    string `json:"state"`
+}
+
+// DisplayName is the subscription name when it is known, otherwise the ID.
+func (s Subscription) DisplayName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+
+	return s.Id
+}
+
+// SubscriptionFilter narrows down the subscriptions returned by DiscoverSubscriptions. Include and Exclude hold
+// glob patterns (as understood by path.Match) that are matched case-insensitively against the subscription name
+// and ID.
+type SubscriptionFilter struct {
+	TenantId        string
+	ManagementGroup string
+	Include         []string
+	Exclude         []string
+}
+
+func ValidatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		_, err := path.Match(strings.ToLower(pattern), "")
+		if err != nil {
+			return errors.New(fmt.Sprintf("invalid pattern %q: %s", pattern, err.Error()))
+		}
 	}
 
 	return nil
 }
+
+func matchesAny(patterns []string, subscription Subscription) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		for _, value := range []string{subscription.Name, subscription.Id} {
+			if matched, _ := path.Match(pattern, strings.ToLower(value)); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (f SubscriptionFilter) Matches(subscription Subscription) bool {
+	if f.TenantId != "" && !strings.EqualFold(f.TenantId, subscription.TenantId) {
+		return false
+	}
+
+	if len(f.Include) > 0 && !matchesAny(f.Include, subscription) {
+		return false
+	}
+
+	return !matchesAny(f.Exclude, subscription)
+}
+
+// FetchSubscriptions lists every subscription the logged in account can see.
+func (c *Client) FetchSubscriptions() ([]Subscription, error) {
+	output, err := c.RunCommand("account", "list", "--all")
+	if err != nil {
+		return nil, err
+	}
+
+	var subscriptions []Subscription
+	err = json.Unmarshal(output, &subscriptions)
+
+	return subscriptions, err
+}
+
+type managementGroupSubscription struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// FetchManagementGroupSubscriptionIds lists the IDs of the subscriptions placed directly under a management group.
+func (c *Client) FetchManagementGroupSubscriptionIds(group string) ([]string, error) {
+	err := ValidateResourceName(group)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := c.RunCommand("account", "management-group", "subscription", "show-sub-under-mg", "--name", group)
+	if err != nil {
+		return nil, err
+	}
+
+	var subscriptions []managementGroupSubscription
+	err = json.Unmarshal(output, &subscriptions)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, subscription := range subscriptions {
+		// The name is the subscription ID, the ID is its path below the management group.
+		id := subscription.Name
+		if id == "" {
+			id = path.Base(subscription.Id)
+		}
+		result = append(result, strings.ToLower(id))
+	}
+
+	return result, nil
+}
+
+// DiscoverSubscriptions finds the enabled subscriptions that match the filter.
+func DiscoverSubscriptions(source SubscriptionSource, filter SubscriptionFilter) ([]Subscription, error) {
+	fmt.Println("Discovering subscriptions...")
+	subscriptions, err := source.FetchSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+
+	var inGroup map[string]bool
+	if filter.ManagementGroup != "" {
+		ids, err := source.FetchManagementGroupSubscriptionIds(filter.ManagementGroup)
+		if err != nil {
+			return nil, err
+		}
+
+		inGroup = make(map[string]bool)
+		for _, id := range ids {
+			inGroup[id] = true
+		}
+	}
+
+	var result []Subscription
+	for _, subscription := range subscriptions {
+		if subscription.State != "" && subscription.State != "Enabled" {
+			continue
+		}
+		if inGroup != nil && !inGroup[strings.ToLower(subscription.Id)] {
+			continue
+		}
+		if filter.Matches(subscription) {
+			result = append(result, subscription)
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, errors.New("no enabled subscriptions matched the discovery filters")
+	}
+
+	return result, nil
+}
+
+// ResolveSubscriptions looks up the names of the given subscription IDs. IDs the account cannot see are returned
+// without a name, so an unknown ID still gets scanned and reported as a problem there.
+func ResolveSubscriptions(source SubscriptionSource, subscriptionIds []string) ([]Subscription, error) {
+	result := make([]Subscription, len(subscriptionIds))
+	for i, id := range subscriptionIds {
+		result[i] = Subscription{Id: id}
+	}
+
+	subscriptions, err := source.FetchSubscriptions()
+	if err != nil {
+		return result, err
+	}
+
+	for i := range result {
+		for _, subscription := range subscriptions {
+			if strings.EqualFold(subscription.Id, result[i].Id) {
+				result[i].Name = subscription.Name
+				result[i].TenantId = subscription.TenantId
+				result[i].State = subscription.State
+			}
+		}
+	}
+
+	return result, nil
+}
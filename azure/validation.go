@@ -0,0 +1,37 @@
+package azure
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var subscriptionIdPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+var resourceIdPattern = regexp.MustCompile(`^/subscriptions/[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}(/[^/\s]+)*$`)
+
+func ValidateSubscriptionId(subscriptionId string) error {
+	if !subscriptionIdPattern.MatchString(subscriptionId) {
+		return errors.New(fmt.Sprintf("%q is not a valid subscription ID, expected a GUID such as 00000000-0000-0000-0000-000000000000", subscriptionId))
+	}
+
+	return nil
+}
+
+func ValidateResourceId(resourceId string) error {
+	if !resourceIdPattern.MatchString(resourceId) {
+		return errors.New(fmt.Sprintf("%q is not a valid Azure resource ID", resourceId))
+	}
+
+	return nil
+}
+
+// ValidateResourceName rejects names that az would mistake for an option.
+func ValidateResourceName(name string) error {
+	if name == "" || strings.HasPrefix(name, "-") || strings.ContainsAny(name, "\r\n") {
+		return errors.New(fmt.Sprintf("%q is not a valid Azure resource name", name))
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package azure
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -41,39 +42,59 @@ type PatchResult struct {
 	Err error
 }
 
-func AssessPatches(vm *Resource, patchResults chan<- PatchResult, wg *sync.WaitGroup) {
-	// TODO: Make this nicelier. It's not great but I'm in a rush.
-	complete := make(chan PatchResult, 1)
-	go func() {
-		fmt.Println(fmt.Sprintf("Assessing patches for VM: %s...", vm.Name))
-		output, err := RunCommand(fmt.Sprintf("az vm assess-patches -n %s -g %s", vm.Name, vm.ResourceGroup))
+// patchAssessmentTimeout is how long a patch assessment may take before it is given up on.
+const patchAssessmentTimeout = 5 * time.Minute
 
-		if err != nil {
-			fmt.Println(fmt.Sprintf("complete: %s", vm.Name))
-			complete <- PatchResult{vm, err}
-			return
-		}
+type patchAssessment struct {
+	result PatchAssessmentResult
+	err    error
+}
 
-		var patchAssessmentResult PatchAssessmentResult
-		err = json.Unmarshal(output, &patchAssessmentResult)
-		vm.PatchAssessmentResult = patchAssessmentResult
+// assessWithTimeout runs a patch assessment for a VM and reports the outcome on patchResults, giving up after
+// five minutes. Every backend shares this so they all time out the same way. The context passed to assess is
+// cancelled when it is given up on, so it can stop working on it.
+func assessWithTimeout(vm *Resource, patchResults chan<- PatchResult, wg *sync.WaitGroup, assess func(ctx context.Context) (PatchAssessmentResult, error)) {
+	defer wg.Done()
 
-		// Don't necessarily crash on this, just alert the user to it.
-		if err != nil {
-			fmt.Println(fmt.Sprintf("complete: %s", vm.Name))
-			complete <- PatchResult{vm, err}
-			return
-		}
+	ctx, cancel := context.WithTimeout(context.Background(), patchAssessmentTimeout)
+	defer cancel()
 
+	complete := make(chan patchAssessment, 1)
+	go func() {
+		fmt.Println(fmt.Sprintf("Assessing patches for VM: %s...", vm.Name))
+		result, err := assess(ctx)
 		fmt.Println(fmt.Sprintf("complete: %s", vm.Name))
-		complete <- PatchResult{vm, nil}
+		complete <- patchAssessment{result, err}
 	}()
+
 	select {
-	case res := <-complete:
-		patchResults <- res
-	case <-time.After(5 * time.Minute):
+	case assessment := <-complete:
+		vm.PatchAssessmentResult = assessment.result
+		// Don't necessarily crash on errors, just alert the user to them.
+		patchResults <- PatchResult{vm, assessment.err}
+	case <-ctx.Done():
 		patchResults <- PatchResult{vm, errors.New(fmt.Sprintf("Timeout after 5 minutes while checking patches for VM: %s", vm.Name))}
 	}
+}
+
+func (c *Client) AssessPatches(vm *Resource, patchResults chan<- PatchResult, wg *sync.WaitGroup) {
+	assessWithTimeout(vm, patchResults, wg, func(_ context.Context) (PatchAssessmentResult, error) {
+		var patchAssessmentResult PatchAssessmentResult
+		err := ValidateResourceName(vm.Name)
+		if err == nil {
+			err = ValidateResourceName(vm.ResourceGroup)
+		}
+		if err != nil {
+			return patchAssessmentResult, err
+		}
+
+		output, err := c.RunCommand("vm", "assess-patches", "-n", vm.Name, "-g", vm.ResourceGroup)
+		if err != nil {
+			return patchAssessmentResult, err
+		}
+
+		err = json.Unmarshal(output, &patchAssessmentResult)
 
-	wg.Done()
+		return patchAssessmentResult, err
+	})
 }
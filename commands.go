@@ -0,0 +1,345 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+
+	"github.com/jayps/azure-checker-go/config"
+	"github.com/jayps/azure-checker-go/diff"
+	"github.com/jayps/azure-checker-go/scan"
+)
+
+// version is set at build time with -ldflags "-X main.version=1.2.3".
+var version = "dev"
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	// Assigned here rather than in the declaration because the help command refers back to the list.
+	commands = []command{
+		{"scan", "collect the selected subscriptions and render the reports", runScan},
+		{"report", "render the reports from saved scan data", runReport},
+		{"diff", "compare two saved scans of a subscription", runDiff},
+		{"list-checks", "show the resource types and checks that can be run", runListChecks},
+		{"validate-config", "check a config file or profile for errors", runValidateConfig},
+		{"profile", "manage saved client profiles", runProfileCommand},
+		{"version", "print the version", runVersion},
+		{"help", "show help for a command", runHelp},
+	}
+}
+
+func printUsage() {
+	fmt.Println("Usage: azure-checker <command> [flags]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	for _, c := range commands {
+		fmt.Println(fmt.Sprintf("  %-16s %s", c.name, c.summary))
+	}
+	fmt.Println("")
+	fmt.Println("Run \"azure-checker help <command>\" or \"azure-checker <command> -h\" for the flags of a command.")
+	fmt.Println("Without a command, the flags are passed to scan.")
+}
+
+// newCommandFlags creates the flag set of a simple command, so -h prints its usage, description and flags if it
+// has any.
+func newCommandFlags(name string, usage string, description string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), fmt.Sprintf("Usage: azure-checker %s", usage))
+		fmt.Fprintln(flags.Output(), "")
+		fmt.Fprintln(flags.Output(), description)
+		hasFlags := false
+		flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintln(flags.Output(), "")
+			flags.PrintDefaults()
+		}
+	}
+
+	return flags
+}
+
+// runCommand dispatches to a subcommand. Flags without a command run a scan, as the tool did before it had
+// subcommands, and "run" is kept as another name for scan.
+func runCommand(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runScan(args)
+	}
+
+	name := args[0]
+	if name == "run" {
+		name = "scan"
+	}
+
+	for _, c := range commands {
+		if c.name == name {
+			return c.run(args[1:])
+		}
+	}
+
+	printUsage()
+	return errors.New(fmt.Sprintf("unknown command %q", args[0]))
+}
+
+func runHelp(args []string) error {
+	if len(args) == 0 {
+		printUsage()
+		return nil
+	}
+
+	if args[0] == "help" {
+		printUsage()
+		return nil
+	}
+
+	return runCommand([]string{args[0], "-h"})
+}
+
+func runReport(args []string) error {
+	opts, files, err := parseReportOptions(args)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(opts.OutputDir, 0755)
+	if err != nil {
+		return errors.New(fmt.Sprintf("could not create output directory: %s", err.Error()))
+	}
+
+	var previous *scan.Snapshot
+	if opts.Previous != "" {
+		p, err := scan.LoadSnapshot(opts.Previous)
+		if err != nil {
+			return err
+		}
+		previous = &p
+	}
+
+	var reports []subscriptionReport
+	seen := make(map[string]string)
+	for _, file := range files {
+		snapshot, err := scan.LoadSnapshot(file)
+		if err != nil {
+			return err
+		}
+
+		if previous != nil && !strings.EqualFold(previous.Result.SubscriptionId, snapshot.Result.SubscriptionId) {
+			return errors.New(fmt.Sprintf("--previous is a scan of %s, not of %s", previous.Result.SubscriptionId, snapshot.Result.SubscriptionId))
+		}
+
+		subscriptionId := strings.ToLower(snapshot.Result.SubscriptionId)
+		if other, found := seen[subscriptionId]; found && opts.Consolidate {
+			return errors.New(fmt.Sprintf("%s and %s are both scans of %s, a consolidated report covers each subscription once", other, file, snapshot.Result.SubscriptionId))
+		}
+		seen[subscriptionId] = file
+
+		reportOpts := opts
+		reportOpts.ClientName = orDefault(opts.ClientName, snapshot.ClientName)
+		fmt.Println(fmt.Sprintf("Rendering the %s scan of %s for %s", snapshot.StartedAt.Format("2006-01-02"), snapshot.Result.SubscriptionId, reportOpts.ClientName))
+		store := historyStore(opts)
+		report := newSubscriptionReport(reportOpts, snapshot, compareWithPrevious(store, filepath.Dir(file), previous, snapshot, opts.Rules, opts.Environments), trendUntil(reportOpts, store, snapshot))
+		if opts.Consolidate {
+			reports = append(reports, report)
+			continue
+		}
+		writeReports(reportOpts, outputFilename(reportOpts, snapshot.Result.SubscriptionId, snapshot.StartedAt), []subscriptionReport{report})
+	}
+
+	if len(reports) > 0 {
+		reportOpts := opts
+		reportOpts.ClientName = orDefault(opts.ClientName, reports[0].snapshot.ClientName)
+		writeReports(reportOpts, consolidatedFilename(reportOpts, reports[0].snapshot.StartedAt), reports)
+	}
+
+	return nil
+}
+
+func runDiff(args []string) error {
+	flags := newCommandFlags("diff", "diff [--config <file>] [--rules <files>] <before.json> <after.json>",
+		"Lists the resources that were added or removed and the findings that were fixed or regressed between two\nsaved scans of the same subscription.")
+	configFile := flags.String("config", "", fmt.Sprintf("YAML or JSON config file with the rules and environments (default %q if it exists)", config.DefaultFilename))
+	rulesFiles := flags.String("rules", "", "comma separated list of custom rules files to evaluate as well")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return errors.New("diff needs exactly two scan files")
+	}
+
+	before, err := scan.LoadSnapshot(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	after, err := scan.LoadSnapshot(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(before.Result.SubscriptionId, after.Result.SubscriptionId) {
+		return errors.New(fmt.Sprintf("the scans are of different subscriptions: %s and %s", before.Result.SubscriptionId, after.Result.SubscriptionId))
+	}
+
+	cfg, err := loadConfig("", firstNonEmpty(*configFile, envConfig))
+	if err != nil {
+		return err
+	}
+
+	ruleSet, err := loadRules(*rulesFiles, cfg)
+	if err != nil {
+		return err
+	}
+
+	changes := diff.Compare(before, after, ruleSet, cfg.Environments)
+	fmt.Println(fmt.Sprintf("Changes in %s between %s and %s:",
+		orDefault(after.Result.SubscriptionName, after.Result.SubscriptionId),
+		before.StartedAt.Format("2006-01-02 15:04"),
+		after.StartedAt.Format("2006-01-02 15:04"),
+	))
+
+	if changes.Empty() {
+		fmt.Println("  No resources or findings changed.")
+	}
+	for _, change := range changes.Resources {
+		fmt.Println(fmt.Sprintf("  %-10s %-28s %s", change.Change, change.ResourceType, change.Resource.Name))
+	}
+	for _, change := range changes.Findings {
+		fmt.Println(fmt.Sprintf("  %-10s %-28s %s: %s", change.Change, change.Finding.RuleId, change.Finding.ResourceName, change.Finding.Message))
+	}
+
+	fmt.Println(fmt.Sprintf("Collection problems: %d before, %d after.", changes.ProblemsBefore, changes.ProblemsAfter))
+
+	return nil
+}
+
+func runListChecks(args []string) error {
+	flags := newCommandFlags("list-checks", "list-checks [--rules <files>]",
+		"Shows the resource types, checks and rules. Resource types and checks can be switched off under resourceTypes or checks in the config file.")
+	rulesFiles := flags.String("rules", "", "comma separated list of custom rules files to list as well")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	ruleSet, err := loadRules(*rulesFiles, config.Config{})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Resource types:")
+	for _, name := range scan.ResourceTypes {
+		fmt.Println(fmt.Sprintf("  %-28s %s", name, scan.Descriptions[name]))
+	}
+	fmt.Println("")
+	fmt.Println("Checks:")
+	for _, name := range scan.Checks {
+		fmt.Println(fmt.Sprintf("  %-28s %s", name, scan.Descriptions[name]))
+	}
+	fmt.Println("")
+	fmt.Println("Rules:")
+	for _, rule := range ruleSet {
+		fmt.Println(fmt.Sprintf("  %-16s %-8s %-16s %s", rule.Id, rule.Severity, rule.Check, rule.Title))
+	}
+
+	return nil
+}
+
+func runValidateConfig(args []string) error {
+	flags := flag.NewFlagSet("validate-config", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: azure-checker validate-config [--profile <name>] [file]")
+		fmt.Fprintln(flags.Output(), "")
+		fmt.Fprintln(flags.Output(), fmt.Sprintf("Checks a config file (default %q) or a saved profile for errors.", config.DefaultFilename))
+		fmt.Fprintln(flags.Output(), "")
+		flags.PrintDefaults()
+	}
+	profile := flags.String("profile", "", "name of a saved client profile to check")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return errors.New(fmt.Sprintf("unexpected arguments: %s", strings.Join(flags.Args()[1:], " ")))
+	}
+
+	path := flags.Arg(0)
+	if *profile == "" && path == "" {
+		path = config.DefaultFilename
+	}
+
+	var cfg config.Config
+	if *profile != "" {
+		if path != "" {
+			return errors.New("--profile cannot be combined with a config file")
+		}
+		p, err := config.LoadProfile(*profile)
+		if err != nil {
+			return err
+		}
+		cfg = p.Config
+		path = fmt.Sprintf("profile %s", *profile)
+	} else {
+		cfg, err = config.Load(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, _, err = cfg.LoadRules()
+	if err != nil {
+		return err
+	}
+
+	disabled := cfg.Settings().Disabled
+	fmt.Println(fmt.Sprintf("%s is valid.", path))
+	if cfg.Client != "" {
+		fmt.Println(fmt.Sprintf("  Client: %s", cfg.Client))
+	}
+	if len(cfg.Subscriptions) > 0 {
+		fmt.Println(fmt.Sprintf("  Subscriptions: %s", strings.Join(cfg.Subscriptions, ", ")))
+	}
+	if cfg.Discovery.Enabled || cfg.Discovery.ManagementGroup != "" {
+		fmt.Println("  Subscriptions: discovered")
+	}
+	for _, name := range append(append([]string{}, scan.ResourceTypes...), scan.Checks...) {
+		if disabled[name] {
+			fmt.Println(fmt.Sprintf("  Disabled: %s", name))
+		}
+	}
+
+	return nil
+}
+
+func runVersion(args []string) error {
+	flags := newCommandFlags("version", "version", "Prints the version of azure-checker.")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(fmt.Sprintf("azure-checker %s", version))
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				fmt.Println(fmt.Sprintf("revision %s", setting.Value))
+			}
+		}
+		fmt.Println(fmt.Sprintf("built with %s", info.GoVersion))
+	}
+
+	return nil
+}
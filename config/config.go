@@ -0,0 +1,255 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jayps/azure-checker-go/azure"
+	"github.com/jayps/azure-checker-go/rules"
+	"github.com/jayps/azure-checker-go/scan"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFilename is picked up from the working directory when no config file is given.
+const DefaultFilename = "azure-checker.yaml"
+
+// Formats are the supported output formats.
+var Formats = []string{"pdf", "xlsx", "docx", "html", "json", "csv"}
+
+// DefaultFormats are the reports written when no formats are given. The machine-readable formats are opt-in.
+var DefaultFormats = []string{"pdf", "xlsx"}
+
+// NormalizeFormats lower-cases output formats, e.g. from --formats or output.formats, and checks they are supported.
+func NormalizeFormats(formats []string) ([]string, error) {
+	var result []string
+	for _, format := range formats {
+		format = strings.ToLower(strings.TrimSpace(format))
+		supported := false
+		for _, f := range Formats {
+			if format == f {
+				supported = true
+			}
+		}
+		if !supported {
+			return nil, errors.New(fmt.Sprintf("unsupported output format %q, expected one of: %s", format, strings.Join(Formats, ", ")))
+		}
+		result = append(result, format)
+	}
+
+	return result, nil
+}
+
+type Discovery struct {
+	Enabled         bool     `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Tenant          string   `yaml:"tenant,omitempty" json:"tenant,omitempty"`
+	ManagementGroup string   `yaml:"managementGroup,omitempty" json:"managementGroup,omitempty"`
+	Include         []string `yaml:"include,omitempty" json:"include,omitempty"`
+	Exclude         []string `yaml:"exclude,omitempty" json:"exclude,omitempty"`
+}
+
+type Output struct {
+	Dir         string   `yaml:"dir,omitempty" json:"dir,omitempty"`
+	Formats     []string `yaml:"formats,omitempty" json:"formats,omitempty"`
+	Consolidate bool     `yaml:"consolidate,omitempty" json:"consolidate,omitempty"` // one report for all subscriptions
+}
+
+// Branding is shown on the cover of the PDF report. Logo is the path to a PNG or JPEG image, relative to the
+// config file.
+type Branding struct {
+	Title string `yaml:"title,omitempty" json:"title,omitempty"`
+	Logo  string `yaml:"logo,omitempty" json:"logo,omitempty"`
+}
+
+// Config is the contents of an azure-checker.yaml file. Anything left out falls back to the command line flags,
+// environment variables and defaults.
+type Config struct {
+	Client        string             `yaml:"client,omitempty" json:"client,omitempty"`
+	Subscriptions []string           `yaml:"subscriptions,omitempty" json:"subscriptions,omitempty"`
+	Discovery     Discovery          `yaml:"discovery,omitempty" json:"discovery,omitempty"`
+	ResourceTypes map[string]bool    `yaml:"resourceTypes,omitempty" json:"resourceTypes,omitempty"`
+	Checks        map[string]bool    `yaml:"checks,omitempty" json:"checks,omitempty"`
+	Output        Output             `yaml:"output,omitempty" json:"output,omitempty"`
+	Branding      Branding           `yaml:"branding,omitempty" json:"branding,omitempty"`
+	Rules         []string           `yaml:"rules,omitempty" json:"rules,omitempty"`     // rules files, relative to the config file
+	Waivers       []string           `yaml:"waivers,omitempty" json:"waivers,omitempty"` // waiver files, relative to the config file
+	Environments  rules.Environments `yaml:"environments,omitempty" json:"environments,omitempty"`
+}
+
+// Load reads a YAML or JSON (by .json extension) config file. Unknown keys are an error, so a misspelt setting is
+// not silently ignored.
+func Load(path string) (Config, error) {
+	var result Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return result, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&result)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&result)
+	}
+	// An empty file is a valid config that changes nothing.
+	if err != nil && err != io.EOF {
+		return result, errors.New(fmt.Sprintf("invalid config file %s: %s", path, err.Error()))
+	}
+
+	result.Output.Formats, err = NormalizeFormats(result.Output.Formats)
+	if err != nil {
+		return result, errors.New(fmt.Sprintf("invalid config file %s: output.formats: %s", path, err.Error()))
+	}
+
+	if result.Branding.Logo != "" && !filepath.IsAbs(result.Branding.Logo) {
+		result.Branding.Logo = filepath.Join(filepath.Dir(path), result.Branding.Logo)
+	}
+	for _, files := range [][]string{result.Rules, result.Waivers} {
+		for i, file := range files {
+			if !filepath.IsAbs(file) {
+				files[i] = filepath.Join(filepath.Dir(path), file)
+			}
+		}
+	}
+
+	err = result.Validate()
+	if err != nil {
+		return result, errors.New(fmt.Sprintf("invalid config file %s: %s", path, err.Error()))
+	}
+
+	return result, nil
+}
+
+func validateNames(section string, values map[string]bool, known []string) error {
+	for name := range values {
+		found := false
+		for _, k := range known {
+			if name == k {
+				found = true
+			}
+		}
+		if !found {
+			return errors.New(fmt.Sprintf("unknown %s %q, expected one of: %s", section, name, strings.Join(known, ", ")))
+		}
+	}
+
+	return nil
+}
+
+func (c Config) Validate() error {
+	for _, subscriptionId := range c.Subscriptions {
+		err := azure.ValidateSubscriptionId(subscriptionId)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(c.Subscriptions) > 0 && (c.Discovery.Enabled || c.Discovery.ManagementGroup != "") {
+		return errors.New("subscriptions cannot be combined with discovery")
+	}
+
+	err := azure.ValidatePatterns(append(c.Discovery.Include, c.Discovery.Exclude...))
+	if err != nil {
+		return err
+	}
+
+	err = validateNames("resource type", c.ResourceTypes, scan.ResourceTypes)
+	if err != nil {
+		return err
+	}
+
+	err = validateNames("check", c.Checks, scan.Checks)
+	if err != nil {
+		return err
+	}
+
+	_, err = NormalizeFormats(c.Output.Formats)
+	if err != nil {
+		return errors.New(fmt.Sprintf("output.formats: %s", err.Error()))
+	}
+
+	if c.Branding.Logo != "" {
+		_, err = c.Branding.LogoDataURI()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.Environments.Validate()
+	if err != nil {
+		return errors.New(fmt.Sprintf("environments: %s", err.Error()))
+	}
+
+	return nil
+}
+
+// LoadRules reads the built-in rules and the custom rules and waivers files of the config, and checks that the
+// waivers are for known rules. Validate leaves the files out, so they are only read by whoever uses them.
+func (c Config) LoadRules() ([]rules.Rule, []rules.Waiver, error) {
+	ruleSet, err := rules.Load(c.Rules)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	waivers, err := rules.LoadWaivers(c.Waivers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ruleSet, waivers, rules.CheckWaivers(waivers, ruleSet)
+}
+
+// Settings turns the resource types and checks that were switched off into scan settings.
+func (c Config) Settings() scan.Settings {
+	result := scan.Settings{Disabled: make(map[string]bool)}
+	for _, values := range []map[string]bool{c.ResourceTypes, c.Checks} {
+		for name, enabled := range values {
+			if !enabled {
+				result.Disabled[name] = true
+			}
+		}
+	}
+
+	return result
+}
+
+// LogoDataURI reads the logo so it can be embedded in the report.
+func (b Branding) LogoDataURI() (string, error) {
+	data, err := os.ReadFile(b.Logo)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("could not read logo: %s", err.Error()))
+	}
+
+	contentType := http.DetectContentType(data)
+	if contentType != "image/png" && contentType != "image/jpeg" {
+		return "", errors.New(fmt.Sprintf("logo %s must be a PNG or JPEG image, got %s", b.Logo, contentType))
+	}
+
+	return fmt.Sprintf("data:%s;base64,%s", contentType, base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// Marshal writes the config back out as YAML, leaving out everything that is not set.
+func (c Config) Marshal() ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	err := encoder.Encode(c)
+	if err != nil {
+		return nil, err
+	}
+
+	err = encoder.Close()
+
+	return buffer.Bytes(), err
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jayps/azure-checker-go/rules"
+	"github.com/jayps/azure-checker-go/scan"
+)
+
+func TestLoad(t *testing.T) {
+	config, err := Load(filepath.Join("testdata", "azure-checker.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if config.Client != "Acme Corp" || !config.Discovery.Enabled || len(config.Discovery.Include) != 1 || !config.Output.Consolidate {
+		t.Errorf("unexpected config: %+v", config)
+	}
+	if strings.Join(config.Output.Formats, ",") != "pdf,xlsx,json" {
+		t.Errorf("got formats %v, want them lower-cased and trimmed", config.Output.Formats)
+	}
+
+	// Files are relative to the config file, the output directory to the working directory.
+	if config.Branding.Logo != filepath.Join("testdata", "acme.png") {
+		t.Errorf("got logo %s", config.Branding.Logo)
+	}
+	if config.Rules[0] != filepath.Join("testdata", "acme-rules.yaml") || config.Waivers[0] != filepath.Join("testdata", "acme-waivers.yaml") {
+		t.Errorf("got rules %v and waivers %v", config.Rules, config.Waivers)
+	}
+	if config.Output.Dir != "./reports" {
+		t.Errorf("got output dir %s", config.Output.Dir)
+	}
+
+	settings := config.Settings()
+	if len(settings.Disabled) != 2 || !settings.Disabled[scan.MySQLServers] || !settings.Disabled[scan.CheckPatches] {
+		t.Errorf("got disabled %v, want the MySQL servers and the patches", settings.Disabled)
+	}
+
+	if got := config.Environments.Classify(map[string]string{"environment": "production"}); got != rules.EnvironmentProduction {
+		t.Errorf("got environment %q", got)
+	}
+
+	ruleSet, waivers, err := config.LoadRules()
+	if err != nil {
+		t.Fatalf("could not load the rules: %s", err)
+	}
+	if len(ruleSet) != len(rules.Builtin)+1 || len(waivers) != 1 {
+		t.Errorf("got %d rules and %d waivers, want the built-in rules, one custom rule and one waiver", len(ruleSet), len(waivers))
+	}
+
+	logo, err := config.Branding.LogoDataURI()
+	if err != nil || !strings.HasPrefix(logo, "data:image/png;base64,") {
+		t.Errorf("got logo %q, %v", logo, err)
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	config, err := Load(filepath.Join("testdata", "azure-checker.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if config.Client != "Acme Corp" || len(config.Subscriptions) != 1 || config.Environments.Default != rules.EnvironmentNonProduction {
+		t.Errorf("unexpected config: %+v", config)
+	}
+}
+
+func TestLoadEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFilename)
+	err := os.WriteFile(path, nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := Load(path)
+	if err != nil {
+		t.Fatalf("an empty config file is valid, got: %s", err)
+	}
+	if len(config.Settings().Disabled) != 0 {
+		t.Errorf("got disabled %v, want nothing switched off", config.Settings().Disabled)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	notAnImage, err := filepath.Abs(filepath.Join("testdata", "not-an-image.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		filename string
+		contents string
+		message  string
+	}{
+		{"unknown key", DefaultFilename, "clients: Acme", "field clients not found"},
+		{"unknown JSON key", "azure-checker.json", `{"clients": "Acme"}`, `unknown field "clients"`},
+		{"not YAML", DefaultFilename, "client: [Acme", "invalid config file"},
+		{"invalid subscription", DefaultFilename, "subscriptions: [prod]", `"prod" is not a valid subscription ID`},
+		{"subscriptions and discovery", DefaultFilename, "subscriptions: [00000000-0000-0000-0000-000000000001]\ndiscovery: {enabled: true}", "subscriptions cannot be combined with discovery"},
+		{"subscriptions and management group", DefaultFilename, "subscriptions: [00000000-0000-0000-0000-000000000001]\ndiscovery: {managementGroup: mg-prod}", "subscriptions cannot be combined with discovery"},
+		{"invalid discovery pattern", DefaultFilename, "discovery: {enabled: true, exclude: ['[sandbox']}", `invalid pattern "[sandbox"`},
+		{"unknown resource type", DefaultFilename, "resourceTypes: {virtualMachine: false}", `unknown resource type "virtualMachine"`},
+		{"unknown check", DefaultFilename, "checks: {patch: false}", `unknown check "patch"`},
+		{"unsupported format", DefaultFilename, "output: {formats: [pdf, doc]}", `output.formats: unsupported output format "doc"`},
+		{"missing logo", DefaultFilename, "branding: {logo: missing.png}", "could not read logo"},
+		{"logo is not an image", DefaultFilename, "branding: {logo: '" + notAnImage + "'}", "must be a PNG or JPEG image"},
+		{"invalid tag selector", DefaultFilename, "environments: {production: ['=prod']}", `environments: tag selector "=prod" has no tag name`},
+		{"unknown default environment", DefaultFilename, "environments: {default: staging}", `environments: unknown default environment "staging"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), test.filename)
+			err := os.WriteFile(path, []byte(test.contents), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = Load(path)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.HasPrefix(err.Error(), "invalid config file "+path) || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("got %q, want it to name the file and contain %q", err.Error(), test.message)
+			}
+		})
+	}
+}
+
+func TestLoadRulesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		message string
+	}{
+		{"missing rules file", Config{Rules: []string{filepath.Join("testdata", "missing.yaml")}}, "missing.yaml"},
+		{"waiver for an unknown rule", Config{Waivers: []string{filepath.Join("testdata", "acme-waivers.yaml")}}, "is for an unknown rule"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := test.config.LoadRules()
+			if err == nil || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("got %v, want an error containing %q", err, test.message)
+			}
+		})
+	}
+}
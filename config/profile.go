@@ -0,0 +1,154 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const envProfileDir = "AZURE_CHECKER_PROFILE_DIR"
+
+const profileExtension = ".yaml"
+
+var profileNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Profile is a config file kept under a short name in the profile directory, so a client that is checked every
+// month can be scanned with just the name.
+type Profile struct {
+	Name   string
+	Config Config
+}
+
+func ValidateProfileName(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return errors.New(fmt.Sprintf("invalid profile name %q, use lower case letters, digits, '-' and '_'", name))
+	}
+
+	return nil
+}
+
+// ProfileDir is $AZURE_CHECKER_PROFILE_DIR, or azure-checker/profiles in the user's config directory.
+func ProfileDir() (string, error) {
+	if dir := os.Getenv(envProfileDir); dir != "" {
+		return dir, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "azure-checker", "profiles"), nil
+}
+
+func ProfilePath(name string) (string, error) {
+	err := ValidateProfileName(name)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := ProfileDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, name+profileExtension), nil
+}
+
+func LoadProfile(name string) (Profile, error) {
+	path, err := ProfilePath(name)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	if _, err = os.Stat(path); os.IsNotExist(err) {
+		return Profile{}, errors.New(fmt.Sprintf("profile %q does not exist, create it with: profile add %s", name, name))
+	}
+
+	c, err := Load(path)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	return Profile{Name: name, Config: c}, nil
+}
+
+// SaveProfile validates and writes a profile. An existing profile is only replaced when overwrite is set.
+func SaveProfile(profile Profile, overwrite bool) (string, error) {
+	err := profile.Config.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	_, _, err = profile.Config.LoadRules()
+	if err != nil {
+		return "", err
+	}
+
+	path, err := ProfilePath(profile.Name)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = os.Stat(path); err == nil && !overwrite {
+		return "", errors.New(fmt.Sprintf("profile %q already exists, use --force to replace it", profile.Name))
+	}
+
+	data, err := profile.Config.Marshal()
+	if err != nil {
+		return "", err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return "", err
+	}
+
+	return path, os.WriteFile(path, data, 0644)
+}
+
+func RemoveProfile(name string) error {
+	path, err := ProfilePath(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return errors.New(fmt.Sprintf("profile %q does not exist", name))
+	}
+
+	return err
+}
+
+// ListProfiles returns the names of the saved profiles in alphabetical order.
+func ListProfiles() ([]string, error) {
+	dir, err := ProfileDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), profileExtension)
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), profileExtension) || ValidateProfileName(name) != nil {
+			continue
+		}
+		result = append(result, name)
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	t.Setenv(envProfileDir, t.TempDir())
+
+	profile := Profile{Name: "acme", Config: Config{
+		Client:        "Acme Corp",
+		Subscriptions: []string{"00000000-0000-0000-0000-000000000001"},
+		Output:        Output{Formats: []string{"pdf"}},
+	}}
+	_, err := SaveProfile(profile, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	_, err = SaveProfile(profile, false)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("got %v, want an error for the existing profile", err)
+	}
+	_, err = SaveProfile(profile, true)
+	if err != nil {
+		t.Errorf("could not overwrite the profile: %s", err)
+	}
+
+	loaded, err := LoadProfile("acme")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if loaded.Config.Client != "Acme Corp" || len(loaded.Config.Subscriptions) != 1 {
+		t.Errorf("got %+v, want the saved config", loaded.Config)
+	}
+
+	names, err := ListProfiles()
+	if err != nil || strings.Join(names, ",") != "acme" {
+		t.Errorf("got %v, %v, want the acme profile", names, err)
+	}
+
+	err = RemoveProfile("acme")
+	if err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	_, err = LoadProfile("acme")
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("got %v, want an error for the removed profile", err)
+	}
+}
+
+func TestSaveProfileErrors(t *testing.T) {
+	t.Setenv(envProfileDir, t.TempDir())
+
+	tests := []struct {
+		name    string
+		profile Profile
+		message string
+	}{
+		{"invalid name", Profile{Name: "Acme Corp"}, "invalid profile name"},
+		{"invalid config", Profile{Name: "acme", Config: Config{Checks: map[string]bool{"patch": false}}}, `unknown check "patch"`},
+		{"missing rules file", Profile{Name: "acme", Config: Config{Rules: []string{"missing.yaml"}}}, "missing.yaml"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := SaveProfile(test.profile, false)
+			if err == nil || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("got %v, want an error containing %q", err, test.message)
+			}
+		})
+	}
+}
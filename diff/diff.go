@@ -0,0 +1,162 @@
+package diff
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jayps/azure-checker-go/azure"
+	"github.com/jayps/azure-checker-go/rules"
+	"github.com/jayps/azure-checker-go/scan"
+)
+
+// How a resource or finding changed between two scans. Added and Removed follow the resource: a finding on a new
+// resource is Added, one on a deleted resource is Removed. Findings on resources present in both scans are Fixed
+// when they disappear and Regressed when they appear.
+const (
+	Added     = "added"
+	Removed   = "removed"
+	Fixed     = "fixed"
+	Regressed = "regressed"
+)
+
+var changeOrder = map[string]int{Regressed: 0, Added: 1, Fixed: 2, Removed: 3}
+
+// ResourceChange is a resource that appeared or disappeared between two scans of a subscription.
+type ResourceChange struct {
+	ResourceType string
+	Change       string
+	Resource     azure.Resource
+}
+
+type FindingChange struct {
+	Change  string
+	Finding rules.Finding
+}
+
+type Changes struct {
+	Before         time.Time
+	After          time.Time
+	Resources      []ResourceChange
+	Findings       []FindingChange
+	ProblemsBefore int
+	ProblemsAfter  int
+}
+
+// Count returns the number of resource and finding changes of a kind.
+func (c Changes) Count(change string) int {
+	count := 0
+	for _, r := range c.Resources {
+		if r.Change == change {
+			count++
+		}
+	}
+	for _, f := range c.Findings {
+		if f.Change == change {
+			count++
+		}
+	}
+
+	return count
+}
+
+func (c Changes) Empty() bool {
+	return len(c.Resources) == 0 && len(c.Findings) == 0
+}
+
+func findingsByKey(findings []rules.Finding) map[string]rules.Finding {
+	result := make(map[string]rules.Finding)
+	for _, finding := range findings {
+		result[finding.Key] = finding
+	}
+
+	return result
+}
+
+// Compare classifies the resources and findings that changed between two scans of a subscription. Findings of a
+// check that did not run for the resource in both scans are not compared, they would otherwise all show up as fixed
+// or regressed. Both scans are classified with the current environments, so a retagged resource does not show up as
+// changed.
+func Compare(before scan.Snapshot, after scan.Snapshot, ruleSet []rules.Rule, environments rules.Environments) Changes {
+	result := Changes{
+		Before:         before.StartedAt,
+		After:          after.StartedAt,
+		ProblemsBefore: len(before.Result.Problems),
+		ProblemsAfter:  len(after.Result.Problems),
+	}
+
+	added := make(map[string]bool)
+	removed := make(map[string]bool)
+	beforeResources := before.Result.Resources()
+	afterResources := after.Result.Resources()
+	for _, resourceType := range scan.ResourceTypes {
+		for id, resource := range afterResources[resourceType] {
+			if _, found := beforeResources[resourceType][id]; !found {
+				added[id] = true
+				result.Resources = append(result.Resources, ResourceChange{ResourceType: resourceType, Change: Added, Resource: resource})
+			}
+		}
+		for id, resource := range beforeResources[resourceType] {
+			if _, found := afterResources[resourceType][id]; !found {
+				removed[id] = true
+				result.Resources = append(result.Resources, ResourceChange{ResourceType: resourceType, Change: Removed, Resource: resource})
+			}
+		}
+	}
+
+	// A check can also fail for a single resource, e.g. a VM whose patch assessment timed out.
+	comparable := func(check string, resourceId string) bool {
+		return before.RanFor(check, resourceId) && after.RanFor(check, resourceId)
+	}
+
+	beforeFindings := findingsByKey(rules.Evaluate(ruleSet, before, environments))
+	afterFindings := findingsByKey(rules.Evaluate(ruleSet, after, environments))
+	for key, finding := range afterFindings {
+		if _, found := beforeFindings[key]; found || !comparable(finding.Check, finding.ResourceId) {
+			continue
+		}
+
+		change := Regressed
+		if added[finding.ResourceId] {
+			change = Added
+		}
+		result.Findings = append(result.Findings, FindingChange{Change: change, Finding: finding})
+	}
+	for key, finding := range beforeFindings {
+		if _, found := afterFindings[key]; found || !comparable(finding.Check, finding.ResourceId) {
+			continue
+		}
+
+		change := Fixed
+		if removed[finding.ResourceId] {
+			change = Removed
+		}
+		result.Findings = append(result.Findings, FindingChange{Change: change, Finding: finding})
+	}
+
+	order := make(map[string]int)
+	for i, resourceType := range scan.ResourceTypes {
+		order[resourceType] = i
+	}
+	sort.SliceStable(result.Resources, func(i, j int) bool {
+		a, b := result.Resources[i], result.Resources[j]
+		if a.ResourceType != b.ResourceType {
+			return order[a.ResourceType] < order[b.ResourceType]
+		}
+
+		return strings.ToLower(a.Resource.Name) < strings.ToLower(b.Resource.Name)
+	})
+	sort.SliceStable(result.Findings, func(i, j int) bool {
+		a, b := result.Findings[i], result.Findings[j]
+		if a.Change != b.Change {
+			return changeOrder[a.Change] < changeOrder[b.Change]
+		}
+		if a.Finding.RuleId != b.Finding.RuleId {
+			return a.Finding.RuleId < b.Finding.RuleId
+		}
+
+		return a.Finding.Key < b.Finding.Key
+	})
+
+	return result
+}
@@ -0,0 +1,172 @@
+package diff
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jayps/azure-checker-go/azure"
+	"github.com/jayps/azure-checker-go/rules"
+	"github.com/jayps/azure-checker-go/scan"
+)
+
+const vmId = "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/rg-web/providers/Microsoft.Compute/virtualMachines/vm-web-01"
+
+// unprotectedVM is a VM with no backups and an outstanding patch, so it has a BACKUP-001 and a PATCH-001 finding.
+const unprotectedVM = `{
+	"id": "` + vmId + `",
+	"name": "vm-web-01",
+	"resourceGroup": "rg-web",
+	"alertRules": [{"name": "cpu"}],
+	"patchAssessmentResult": {"availablePatches": [{"patchId": "p-1", "name": "2023-11 Cumulative Update", "classifications": ["Security"]}]}
+}`
+
+// protectedVM is the same VM backed up and fully patched.
+const protectedVM = `{
+	"id": "` + vmId + `",
+	"name": "vm-web-01",
+	"resourceGroup": "rg-web",
+	"alertRules": [{"name": "cpu"}],
+	"backupVault": {"id": "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/rg-backup/providers/Microsoft.RecoveryServices/vaults/vault-web", "name": "vault-web"}
+}`
+
+func snapshotOf(t *testing.T, startedAt time.Time, vm string, problems ...scan.Problem) scan.Snapshot {
+	t.Helper()
+
+	var resource azure.Resource
+	err := json.Unmarshal([]byte(vm), &resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return scan.Snapshot{
+		StartedAt: startedAt,
+		Result: scan.Result{
+			SubscriptionId:  "00000000-0000-0000-0000-000000000001",
+			VirtualMachines: map[string]azure.Resource{strings.ToLower(resource.Id): resource},
+			Problems:        problems,
+		},
+	}
+}
+
+func withDisabled(snapshot scan.Snapshot, check string) scan.Snapshot {
+	snapshot.Settings.Disabled = map[string]bool{check: true}
+
+	return snapshot
+}
+
+// failed records that the backup and patch checks could not be completed for the VM.
+var failed = []scan.Problem{
+	{Check: "backups for VM vm-web-01", ResourceId: vmId, Message: "vault unavailable"},
+	{Check: "patches for VM vm-web-01", ResourceId: vmId, Message: "Timeout after 5 minutes while checking patches for VM: vm-web-01"},
+}
+
+func changesByRule(changes Changes) map[string]string {
+	result := make(map[string]string)
+	for _, change := range changes.Findings {
+		result[change.Finding.RuleId] = change.Change
+	}
+
+	return result
+}
+
+func TestCompare(t *testing.T) {
+	before := time.Date(2024, 1, 31, 8, 0, 0, 0, time.UTC)
+	after := before.AddDate(0, 1, 0)
+
+	tests := []struct {
+		name   string
+		before scan.Snapshot
+		after  scan.Snapshot
+		want   map[string]string
+	}{
+		{
+			name:   "fixed",
+			before: snapshotOf(t, before, unprotectedVM),
+			after:  snapshotOf(t, after, protectedVM),
+			want:   map[string]string{rules.RuleNotBackedUp: Fixed, rules.RuleOutstandingPatch: Fixed},
+		},
+		{
+			name:   "regressed",
+			before: snapshotOf(t, before, protectedVM),
+			after:  snapshotOf(t, after, unprotectedVM),
+			want:   map[string]string{rules.RuleNotBackedUp: Regressed, rules.RuleOutstandingPatch: Regressed},
+		},
+		{
+			name:   "unchanged",
+			before: snapshotOf(t, before, unprotectedVM),
+			after:  snapshotOf(t, after, unprotectedVM),
+			want:   map[string]string{},
+		},
+		{
+			name:   "checks failed for the VM in the later scan",
+			before: snapshotOf(t, before, unprotectedVM),
+			after:  snapshotOf(t, after, unprotectedVM, failed...),
+			want:   map[string]string{},
+		},
+		{
+			name:   "checks failed for the VM in the earlier scan",
+			before: snapshotOf(t, before, unprotectedVM, failed...),
+			after:  snapshotOf(t, after, unprotectedVM),
+			want:   map[string]string{},
+		},
+		{
+			name:   "only the patch check failed",
+			before: snapshotOf(t, before, unprotectedVM),
+			after:  snapshotOf(t, after, protectedVM, failed[1]),
+			want:   map[string]string{rules.RuleNotBackedUp: Fixed},
+		},
+		{
+			name:   "backup check switched off",
+			before: snapshotOf(t, before, unprotectedVM),
+			after:  withDisabled(snapshotOf(t, after, protectedVM), scan.CheckBackups),
+			want:   map[string]string{rules.RuleOutstandingPatch: Fixed},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			changes := Compare(test.before, test.after, rules.Builtin, rules.Environments{})
+
+			got := changesByRule(changes)
+			if len(got) != len(test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+			for rule, change := range test.want {
+				if got[rule] != change {
+					t.Errorf("%s: got %q, want %q", rule, got[rule], change)
+				}
+			}
+			if len(changes.Resources) != 0 {
+				t.Errorf("got resource changes %v, want none", changes.Resources)
+			}
+		})
+	}
+}
+
+func TestCompareResources(t *testing.T) {
+	before := snapshotOf(t, time.Date(2024, 1, 31, 8, 0, 0, 0, time.UTC), unprotectedVM)
+	after := snapshotOf(t, time.Date(2024, 2, 29, 8, 0, 0, 0, time.UTC), unprotectedVM)
+	before.Result.VirtualMachines = map[string]azure.Resource{}
+
+	changes := Compare(before, after, rules.Builtin, rules.Environments{})
+
+	if len(changes.Resources) != 1 || changes.Resources[0].Change != Added {
+		t.Fatalf("got %v, want the VM added", changes.Resources)
+	}
+	// The findings of a new resource are added, not regressed.
+	for _, change := range changes.Findings {
+		if change.Change != Added {
+			t.Errorf("%s is %s, want %s", change.Finding.RuleId, change.Change, Added)
+		}
+	}
+	if changes.Count(Added) != 3 {
+		t.Errorf("got %d added, want the VM and its two findings", changes.Count(Added))
+	}
+
+	changes = Compare(after, before, rules.Builtin, rules.Environments{})
+	if changes.Count(Removed) != 3 || changes.Count(Fixed) != 0 {
+		t.Errorf("got %d removed and %d fixed, want the VM and its two findings removed", changes.Count(Removed), changes.Count(Fixed))
+	}
+}
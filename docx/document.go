@@ -0,0 +1,335 @@
+package docx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"strings"
+)
+
+// Text colours, the same as the classes of the PDF report.
+const (
+	colorDanger = "FF0000"
+	colorWarn   = "FFA500"
+	colorOk     = "008000"
+)
+
+// emuPerPixel converts pixels at 96 DPI to the English Metric Units drawings are measured in.
+const emuPerPixel = 9525
+
+// run is a piece of text with the same formatting.
+type run struct {
+	text  string
+	bold  bool
+	small bool
+	color string
+}
+
+func text(value string) run {
+	return run{text: value}
+}
+
+func bold(value string) run {
+	return run{text: value, bold: true}
+}
+
+func small(value string) run {
+	return run{text: value, small: true}
+}
+
+func colored(value string, color string) run {
+	return run{text: value, color: color}
+}
+
+func escape(value string) string {
+	var buffer bytes.Buffer
+	_ = xml.EscapeText(&buffer, []byte(value))
+
+	return buffer.String()
+}
+
+func (r run) xml() string {
+	properties := ""
+	if r.bold {
+		properties += "<w:b/>"
+	}
+	if r.color != "" {
+		properties += fmt.Sprintf(`<w:color w:val="%s"/>`, r.color)
+	}
+	if r.small {
+		properties += `<w:sz w:val="16"/>`
+	}
+	if properties != "" {
+		properties = fmt.Sprintf("<w:rPr>%s</w:rPr>", properties)
+	}
+
+	// Line breaks in the text become breaks in the run, Word ignores them in w:t.
+	output := ""
+	for i, line := range strings.Split(r.text, "\n") {
+		if i > 0 {
+			output += "<w:br/>"
+		}
+		output += fmt.Sprintf(`<w:t xml:space="preserve">%s</w:t>`, escape(line))
+	}
+
+	return fmt.Sprintf("<w:r>%s%s</w:r>", properties, output)
+}
+
+// media is an image embedded in the document.
+type media struct {
+	id   string
+	name string
+	data []byte
+}
+
+// document builds a WordprocessingML package. Headings use the built-in Heading styles, so Word can build a table
+// of contents from them and the reader can restyle the whole report at once.
+type document struct {
+	body  strings.Builder
+	media []media
+}
+
+func paragraphXML(style string, pageBreak bool, runs []run) string {
+	properties := ""
+	if style != "" {
+		properties += fmt.Sprintf(`<w:pStyle w:val="%s"/>`, style)
+	}
+	if pageBreak {
+		properties += "<w:pageBreakBefore/>"
+	}
+	if properties != "" {
+		properties = fmt.Sprintf("<w:pPr>%s</w:pPr>", properties)
+	}
+
+	output := "<w:p>" + properties
+	for _, r := range runs {
+		output += r.xml()
+	}
+
+	return output + "</w:p>"
+}
+
+func (d *document) paragraph(runs ...run) {
+	d.body.WriteString(paragraphXML("", false, runs))
+}
+
+func (d *document) styled(style string, runs ...run) {
+	d.body.WriteString(paragraphXML(style, false, runs))
+}
+
+// heading adds a Heading 1 to 4 paragraph, optionally starting a new page.
+func (d *document) heading(level int, pageBreak bool, value string) {
+	if level > 4 {
+		level = 4
+	}
+	d.body.WriteString(paragraphXML(fmt.Sprintf("Heading%d", level), pageBreak, []run{text(value)}))
+}
+
+// tableOfContents adds a TOC field over the given number of heading levels. Word fills it in when the document is opened
+// (see updateFields in the settings) or when the field is updated, so it can be regenerated after editing.
+func (d *document) tableOfContents(levels int) {
+	d.body.WriteString(`<w:sdt><w:sdtPr><w:docPartObj><w:docPartGallery w:val="Table of Contents"/><w:docPartUnique/></w:docPartObj></w:sdtPr><w:sdtContent>`)
+	d.body.WriteString(paragraphXML("TOCHeading", true, []run{text("Contents")}))
+	d.body.WriteString(`<w:p><w:r><w:fldChar w:fldCharType="begin" w:dirty="true"/></w:r>`)
+	d.body.WriteString(fmt.Sprintf(`<w:r><w:instrText xml:space="preserve"> TOC \o "1-%d" \h \z \u </w:instrText></w:r>`, levels))
+	d.body.WriteString(`<w:r><w:fldChar w:fldCharType="separate"/></w:r>`)
+	d.body.WriteString(text("Right-click here and choose Update Field to build the table of contents.").xml())
+	d.body.WriteString(`<w:r><w:fldChar w:fldCharType="end"/></w:r></w:p>`)
+	d.body.WriteString(`</w:sdtContent></w:sdt>`)
+}
+
+// table adds a table with a header row that is repeated on every page it spans.
+func (d *document) table(headers []string, rows [][]run) {
+	width := 9000 / len(headers)
+	output := `<w:tbl><w:tblPr><w:tblStyle w:val="TableGrid"/><w:tblW w:w="5000" w:type="pct"/></w:tblPr><w:tblGrid>`
+	for range headers {
+		output += fmt.Sprintf(`<w:gridCol w:w="%d"/>`, width)
+	}
+	output += "</w:tblGrid>"
+
+	output += `<w:tr><w:trPr><w:tblHeader/></w:trPr>`
+	for _, header := range headers {
+		output += `<w:tc><w:tcPr><w:shd w:val="clear" w:color="auto" w:fill="EEEEEE"/></w:tcPr>`
+		output += paragraphXML("", false, []run{bold(header)})
+		output += "</w:tc>"
+	}
+	output += "</w:tr>"
+
+	for _, row := range rows {
+		output += "<w:tr>"
+		for _, cell := range row {
+			output += "<w:tc>" + paragraphXML("", false, []run{cell}) + "</w:tc>"
+		}
+		output += "</w:tr>"
+	}
+	output += "</w:tbl>"
+
+	// A paragraph keeps two tables in a row from being merged into one.
+	d.body.WriteString(output + "<w:p/>")
+}
+
+// image adds a centred PNG or JPEG from a data URI, scaled to the given height in pixels.
+func (d *document) image(dataURI string, height int) error {
+	parts := strings.SplitN(strings.TrimPrefix(dataURI, "data:"), ";base64,", 2)
+	if len(parts) != 2 {
+		return errors.New("the image is not a base64 data URI")
+	}
+	data, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return err
+	}
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	id := fmt.Sprintf("rIdImage%d", len(d.media)+1)
+	name := fmt.Sprintf("image%d.%s", len(d.media)+1, format)
+	d.media = append(d.media, media{id: id, name: name, data: data})
+
+	cx := config.Width * height / config.Height * emuPerPixel
+	cy := height * emuPerPixel
+	number := len(d.media)
+	d.body.WriteString(fmt.Sprintf(`<w:p><w:pPr><w:jc w:val="center"/></w:pPr><w:r><w:drawing><wp:inline distT="0" distB="0" distL="0" distR="0">`+
+		`<wp:extent cx="%d" cy="%d"/><wp:docPr id="%d" name="%s"/>`+
+		`<a:graphic xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture">`+
+		`<pic:pic xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture"><pic:nvPicPr><pic:cNvPr id="%d" name="%s"/><pic:cNvPicPr/></pic:nvPicPr>`+
+		`<pic:blipFill><a:blip r:embed="%s"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>`+
+		`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="%d" cy="%d"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr></pic:pic>`+
+		`</a:graphicData></a:graphic></wp:inline></w:drawing></w:r></w:p>`,
+		cx, cy, number, name, number, name, id, cx, cy))
+
+	return nil
+}
+
+const contentTypesXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Default Extension="png" ContentType="image/png"/>
+<Default Extension="jpeg" ContentType="image/jpeg"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>
+<Override PartName="/word/settings.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.settings+xml"/>
+<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>
+</Types>`
+
+const packageRelationshipsXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties" Target="docProps/core.xml"/>
+</Relationships>`
+
+const coreXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<dc:title>%s</dc:title>
+<dc:subject>%s</dc:subject>
+</cp:coreProperties>`
+
+// updateFields makes Word offer to update the table of contents when the document is opened.
+const settingsXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:settings xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:updateFields w:val="true"/>
+</w:settings>`
+
+// headingStyle is a built-in heading style. The outline level is what puts it in the table of contents.
+const headingStyle = `<w:style w:type="paragraph" w:styleId="Heading%[1]d"><w:name w:val="heading %[1]d"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:uiPriority w:val="9"/><w:qFormat/>
+<w:pPr><w:keepNext/><w:keepLines/><w:spacing w:before="%[2]d" w:after="120"/><w:outlineLvl w:val="%[3]d"/></w:pPr><w:rPr><w:b/><w:color w:val="404040"/><w:sz w:val="%[4]d"/></w:rPr></w:style>
+`
+
+func stylesXML() string {
+	output := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:docDefaults><w:rPrDefault><w:rPr><w:rFonts w:ascii="Calibri" w:hAnsi="Calibri" w:cs="Calibri"/><w:color w:val="666666"/><w:sz w:val="22"/></w:rPr></w:rPrDefault>
+<w:pPrDefault><w:pPr><w:spacing w:after="120" w:line="264" w:lineRule="auto"/></w:pPr></w:pPrDefault></w:docDefaults>
+<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/><w:qFormat/></w:style>
+<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>
+<w:pPr><w:spacing w:before="480" w:after="240"/><w:jc w:val="center"/></w:pPr><w:rPr><w:b/><w:color w:val="404040"/><w:sz w:val="56"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Subtitle"><w:name w:val="Subtitle"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/><w:qFormat/>
+<w:pPr><w:jc w:val="center"/></w:pPr><w:rPr><w:b/><w:sz w:val="36"/></w:rPr></w:style>
+<w:style w:type="paragraph" w:styleId="Cover"><w:name w:val="Cover"/><w:basedOn w:val="Normal"/><w:qFormat/><w:pPr><w:jc w:val="center"/></w:pPr></w:style>
+<w:style w:type="paragraph" w:styleId="Score"><w:name w:val="Score"/><w:basedOn w:val="Normal"/><w:rPr><w:b/><w:sz w:val="96"/></w:rPr></w:style>
+`
+	output += fmt.Sprintf(headingStyle, 1, 480, 0, 40)
+	output += fmt.Sprintf(headingStyle, 2, 360, 1, 30)
+	output += fmt.Sprintf(headingStyle, 3, 240, 2, 24)
+	output += fmt.Sprintf(headingStyle, 4, 240, 3, 22)
+	output += `<w:style w:type="paragraph" w:styleId="TOCHeading"><w:name w:val="TOC Heading"/><w:basedOn w:val="Heading1"/><w:next w:val="Normal"/><w:uiPriority w:val="39"/><w:unhideWhenUsed/><w:qFormat/><w:pPr><w:outlineLvl w:val="9"/></w:pPr></w:style>
+<w:style w:type="table" w:default="1" w:styleId="TableNormal"><w:name w:val="Normal Table"/><w:tblPr><w:tblCellMar><w:top w:w="0" w:type="dxa"/><w:left w:w="108" w:type="dxa"/><w:bottom w:w="0" w:type="dxa"/><w:right w:w="108" w:type="dxa"/></w:tblCellMar></w:tblPr></w:style>
+<w:style w:type="table" w:styleId="TableGrid"><w:name w:val="Table Grid"/><w:basedOn w:val="TableNormal"/><w:pPr><w:spacing w:after="0" w:line="240" w:lineRule="auto"/></w:pPr>
+<w:tblPr><w:tblBorders><w:top w:val="single" w:sz="4" w:space="0" w:color="BFBFBF"/><w:left w:val="single" w:sz="4" w:space="0" w:color="BFBFBF"/><w:bottom w:val="single" w:sz="4" w:space="0" w:color="BFBFBF"/><w:right w:val="single" w:sz="4" w:space="0" w:color="BFBFBF"/><w:insideH w:val="single" w:sz="4" w:space="0" w:color="BFBFBF"/><w:insideV w:val="single" w:sz="4" w:space="0" w:color="BFBFBF"/></w:tblBorders></w:tblPr></w:style>
+</w:styles>`
+
+	return output
+}
+
+func (d *document) documentXML() string {
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships" xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing">
+<w:body>` + d.body.String() + `<w:sectPr><w:pgSz w:w="11906" w:h="16838"/><w:pgMar w:top="1134" w:right="1134" w:bottom="1134" w:left="1134" w:header="708" w:footer="708" w:gutter="0"/></w:sectPr>
+</w:body>
+</w:document>`
+}
+
+func (d *document) relationshipsXML() string {
+	output := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rIdStyles" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+<Relationship Id="rIdSettings" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/settings" Target="settings.xml"/>
+`
+	for _, m := range d.media {
+		output += fmt.Sprintf(`<Relationship Id="%s" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="media/%s"/>
+`, m.id, m.name)
+	}
+
+	return output + "</Relationships>"
+}
+
+// save writes the document as a .docx package.
+func (d *document) save(path string, title string, subject string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	parts := []struct {
+		name string
+		data []byte
+	}{
+		{"[Content_Types].xml", []byte(contentTypesXML)},
+		{"_rels/.rels", []byte(packageRelationshipsXML)},
+		{"docProps/core.xml", []byte(fmt.Sprintf(coreXML, escape(title), escape(subject)))},
+		{"word/document.xml", []byte(d.documentXML())},
+		{"word/_rels/document.xml.rels", []byte(d.relationshipsXML())},
+		{"word/styles.xml", []byte(stylesXML())},
+		{"word/settings.xml", []byte(settingsXML)},
+	}
+	for _, m := range d.media {
+		parts = append(parts, struct {
+			name string
+			data []byte
+		}{fmt.Sprintf("word/media/%s", m.name), m.data})
+	}
+
+	archive := zip.NewWriter(file)
+	for _, part := range parts {
+		writer, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(part.data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
@@ -0,0 +1,634 @@
+package docx
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jayps/azure-checker-go/azure"
+	"github.com/jayps/azure-checker-go/diff"
+	"github.com/jayps/azure-checker-go/history"
+	"github.com/jayps/azure-checker-go/rules"
+	"github.com/jayps/azure-checker-go/scan"
+	"github.com/jayps/azure-checker-go/scorecard"
+)
+
+// Subscription is what the document reports about one subscription.
+type Subscription struct {
+	Result    scan.Result
+	Settings  scan.Settings
+	Findings  []rules.Finding
+	Scorecard scorecard.Scorecard
+	Changes   *diff.Changes // nil when there is no earlier scan to compare with
+	Trend     []history.Point
+}
+
+func (s Subscription) name() string {
+	if s.Result.SubscriptionName != "" {
+		return s.Result.SubscriptionName
+	}
+
+	return s.Result.SubscriptionId
+}
+
+// Generator writes the report as a Word document with the sections of the PDF report, so it can be annotated
+// before it is sent to the client. Several subscriptions make a consolidated report with a chapter per subscription.
+type Generator struct {
+	Title          string
+	Logo           string // data URI of the cover image, none when empty
+	ClientName     string
+	Date           time.Time
+	OutputFilename string
+	Subscriptions  []Subscription
+	Environments   rules.Environments
+}
+
+var statusColors = map[string]string{
+	scorecard.Green: colorOk,
+	scorecard.Amber: colorWarn,
+	scorecard.Red:   colorDanger,
+}
+
+var severityColors = map[string]string{
+	rules.SeverityHigh:   colorDanger,
+	rules.SeverityMedium: colorWarn,
+}
+
+var changeColors = map[string]string{
+	diff.Regressed: colorDanger,
+	diff.Added:     colorWarn,
+	diff.Fixed:     colorOk,
+}
+
+var changeLabels = map[string]string{
+	diff.Regressed: "Regressed",
+	diff.Added:     "New",
+	diff.Fixed:     "Fixed",
+	diff.Removed:   "Removed",
+}
+
+func formatDate(t time.Time) string {
+	return fmt.Sprintf("%d-%d-%d", t.Year(), t.Month(), t.Day())
+}
+
+func orDefault(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+
+	return value
+}
+
+// subscriptionWriter writes the sections of one subscription. level is the heading level of its sections, one
+// deeper in a consolidated report where the subscription is the chapter.
+type subscriptionWriter struct {
+	*document
+	Subscription
+	level        int
+	environments rules.Environments
+}
+
+// section adds the heading of a section. Like in the PDF every section starts on a new page.
+func (s *subscriptionWriter) section(title string) {
+	s.heading(s.level, true, title)
+}
+
+func (g Generator) generateCover(d *document) {
+	if g.Logo != "" {
+		// The logo is decoration, the report is still useful without it.
+		err := d.image(g.Logo, 150)
+		if err != nil {
+			d.styled("Cover", small(fmt.Sprintf("The logo could not be embedded: %s", err.Error())))
+		}
+	}
+	d.styled("Title", text(g.Title))
+	d.styled("Subtitle", text(g.ClientName))
+	if len(g.Subscriptions) == 1 {
+		subscription := g.Subscriptions[0]
+		d.styled("Cover", bold(subscription.Result.SubscriptionName))
+		d.styled("Cover", bold(fmt.Sprintf("Subscription ID: %s", subscription.Result.SubscriptionId)))
+	} else {
+		d.styled("Cover", bold(fmt.Sprintf("%d subscriptions", len(g.Subscriptions))))
+		for _, subscription := range g.Subscriptions {
+			d.styled("Cover", text(subscription.name()))
+		}
+	}
+	d.styled("Cover", text(fmt.Sprintf("Document Date: %s", formatDate(g.Date))))
+}
+
+func scorecardRow(card scorecard.Scorecard) []run {
+	row := []run{text(orDefault(card.SubscriptionName, card.SubscriptionId))}
+	for _, metric := range card.Metrics() {
+		row = append(row, colored(metric.Value, statusColors[metric.Status]))
+	}
+	status := bold(orDefault(card.Status, "n/a"))
+	status.color = statusColors[card.Status]
+
+	return append(row, status)
+}
+
+func generateScorecardTable(d *document, scorecards []scorecard.Scorecard) {
+	headers := []string{"Subscription"}
+	for _, metric := range scorecards[0].Metrics() {
+		headers = append(headers, metric.Name)
+	}
+	headers = append(headers, "Status")
+
+	var rows [][]run
+	for _, card := range scorecards {
+		rows = append(rows, scorecardRow(card))
+	}
+	d.table(headers, rows)
+}
+
+// generateSummary writes the executive summary. A consolidated report leads with the totals of all its
+// subscriptions.
+func (g Generator) generateSummary(d *document) {
+	var scorecards []scorecard.Scorecard
+	var findings []rules.Finding
+	changeCounts := make(map[string]int)
+	for _, subscription := range g.Subscriptions {
+		scorecards = append(scorecards, subscription.Scorecard)
+		findings = append(findings, subscription.Findings...)
+		if subscription.Changes != nil {
+			for _, change := range []string{diff.Regressed, diff.Added, diff.Fixed} {
+				changeCounts[change] += subscription.Changes.Count(change)
+			}
+		}
+	}
+
+	headline := scorecards[0]
+	if len(scorecards) > 1 {
+		headline = scorecard.Combine("All subscriptions", scorecards)
+		scorecards = append(scorecards, headline)
+	}
+
+	d.heading(1, true, "Executive Summary")
+	if headline.Score != nil {
+		d.styled("Score", colored(fmt.Sprintf("%.0f", *headline.Score), statusColors[headline.Status]))
+		status := bold(headline.Status)
+		status.color = statusColors[headline.Status]
+		d.paragraph(bold("Overall score out of 100: "), status)
+	}
+	generateScorecardTable(d, scorecards)
+
+	open := make(map[string]int)
+	accepted := 0
+	for _, finding := range findings {
+		if finding.Accepted() {
+			accepted++
+		} else {
+			open[finding.Severity]++
+		}
+	}
+	counts := []run{bold("Open findings: ")}
+	for i, severity := range rules.Severities {
+		if i > 0 {
+			counts = append(counts, text(", "))
+		}
+		counts = append(counts, colored(fmt.Sprintf("%d %s", open[severity], severity), severityColors[severity]))
+	}
+	d.paragraph(append(counts, text(fmt.Sprintf(". %d accepted risks.", accepted)))...)
+
+	if changeCounts[diff.Regressed]+changeCounts[diff.Added]+changeCounts[diff.Fixed] > 0 {
+		d.paragraph(bold("Since the last review: "),
+			colored(fmt.Sprintf("%d regressed", changeCounts[diff.Regressed]), colorDanger), text(", "),
+			colored(fmt.Sprintf("%d added", changeCounts[diff.Added]), colorWarn), text(", "),
+			colored(fmt.Sprintf("%d fixed", changeCounts[diff.Fixed]), colorOk), text("."))
+	}
+
+	d.paragraph(small(scorecard.Explanation()))
+}
+
+func (s *subscriptionWriter) generateProblems() {
+	if len(s.Result.Problems) == 0 {
+		return
+	}
+
+	s.section("Collection Problems")
+	s.paragraph(text("The following checks could not be completed. The results in this report are incomplete for these items."))
+	var rows [][]run
+	for _, problem := range s.Result.Problems {
+		rows = append(rows, []run{bold(problem.Check), colored(string(problem.Kind), colorDanger), small(problem.Message), text(problem.Hint)})
+	}
+	s.table([]string{"Check", "Reason", "Message", "Action to be performed"}, rows)
+}
+
+func (s *subscriptionWriter) generateChanges() {
+	if s.Changes == nil {
+		return
+	}
+
+	changes := *s.Changes
+	s.section("Changes since last review")
+	s.paragraph(text(fmt.Sprintf("Compared with the scan of %s.", formatDate(changes.Before))))
+	if changes.Empty() {
+		s.paragraph(text("No resources or findings changed since the last review."))
+		return
+	}
+
+	s.paragraph(colored(fmt.Sprintf("%d regressed", changes.Count(diff.Regressed)), colorDanger), text(", "),
+		colored(fmt.Sprintf("%d added", changes.Count(diff.Added)), colorWarn), text(", "),
+		colored(fmt.Sprintf("%d fixed", changes.Count(diff.Fixed)), colorOk), text(", "),
+		text(fmt.Sprintf("%d removed.", changes.Count(diff.Removed))))
+
+	if len(changes.Resources) > 0 {
+		s.heading(s.level+1, false, "Resources")
+		var rows [][]run
+		for _, change := range changes.Resources {
+			rows = append(rows, []run{colored(change.Change, changeColors[change.Change]), text(scan.Descriptions[change.ResourceType]), text(change.Resource.Name)})
+		}
+		s.table([]string{"Change", "Resource type", "Resource"}, rows)
+	}
+
+	if len(changes.Findings) > 0 {
+		s.heading(s.level+1, false, "Findings")
+		var rows [][]run
+		for _, change := range changes.Findings {
+			label := bold(changeLabels[change.Change])
+			label.color = changeColors[change.Change]
+			rows = append(rows, []run{label, text(change.Finding.Message), text(change.Finding.ResourceName)})
+		}
+		s.table([]string{"Change", "Finding", "Resource"}, rows)
+	}
+
+	if changes.ProblemsBefore != changes.ProblemsAfter {
+		s.paragraph(text(fmt.Sprintf("Checks that could not be completed: %d last time, %d now.", changes.ProblemsBefore, changes.ProblemsAfter)))
+	}
+}
+
+func formatPercentage(value *float64) string {
+	if value == nil {
+		return "n/a"
+	}
+
+	return fmt.Sprintf("%.0f%%", *value)
+}
+
+// generateTrends lists the metrics of every review. The PDF draws them as charts, a table is easier to edit.
+func (s *subscriptionWriter) generateTrends() {
+	// A single scan is not a trend.
+	if len(s.Trend) < 2 {
+		return
+	}
+
+	categories := history.Categories(s.Trend)
+	s.section("Trends")
+	s.paragraph(text(fmt.Sprintf("How this subscription has changed over the last %d reviews.", len(s.Trend))))
+	var rows [][]run
+	for _, point := range s.Trend {
+		patches := "n/a"
+		if point.CriticalPatches != nil {
+			patches = fmt.Sprintf("%d", *point.CriticalPatches)
+		}
+		row := []run{text(formatDate(point.Time)), text(formatPercentage(point.AlertCoverage)), text(formatPercentage(point.BackupCoverage)), text(patches)}
+		for _, category := range categories {
+			count := "n/a"
+			if point.Recommendations != nil {
+				count = fmt.Sprintf("%d", point.Recommendations[category])
+			}
+			row = append(row, text(count))
+		}
+		rows = append(rows, row)
+	}
+	s.table(append([]string{"Date", "Resources with alert rules", "VMs backed up", "Critical and security patches"}, categories...), rows)
+}
+
+// generateFinding writes a finding with the action to be performed about it, or the waiver that accepts it.
+func (s *subscriptionWriter) generateFinding(finding rules.Finding) {
+	details := small(fmt.Sprintf(" (%s, %s)", finding.RuleId, finding.Severity))
+	if finding.Accepted() {
+		s.paragraph(colored("Accepted risk: ", colorOk), text(finding.Message), details)
+		s.paragraph(bold("Justification: "), text(finding.Waiver.Justification))
+		s.paragraph(small(fmt.Sprintf("Approved by %s until %s.", finding.Waiver.Approver, finding.Waiver.Expires)))
+		return
+	}
+
+	s.paragraph(colored(finding.Message, severityColors[finding.Severity]), details)
+	s.paragraph(bold("Action to be performed: "), text(finding.Remediation))
+	if finding.Status() == rules.StatusWaiverExpired {
+		s.paragraph(colored(fmt.Sprintf("The waiver for this finding expired on %s. ", finding.Waiver.Expires), colorDanger),
+			small(fmt.Sprintf("It was accepted by %s: %s", finding.Waiver.Approver, finding.Waiver.Justification)))
+	}
+}
+
+// generateFindings summarises the findings of every rule by severity.
+func (s *subscriptionWriter) generateFindings() {
+	if len(s.Findings) == 0 {
+		return
+	}
+
+	var ruleIds []string
+	var accepted, expired []rules.Finding
+	titles := make(map[string]string)
+	counts := make(map[string]map[string]int)
+	for _, finding := range s.Findings {
+		if counts[finding.RuleId] == nil {
+			ruleIds = append(ruleIds, finding.RuleId)
+			titles[finding.RuleId] = finding.Title
+			counts[finding.RuleId] = make(map[string]int)
+		}
+		switch finding.Status() {
+		case rules.StatusAccepted:
+			counts[finding.RuleId][rules.StatusAccepted]++
+			accepted = append(accepted, finding)
+		case rules.StatusWaiverExpired:
+			counts[finding.RuleId][finding.Severity]++
+			expired = append(expired, finding)
+		default:
+			counts[finding.RuleId][finding.Severity]++
+		}
+	}
+	sort.Strings(ruleIds)
+
+	s.section("Findings")
+	s.paragraph(text(fmt.Sprintf("%d findings across all checks. The sections that follow describe each of them.", len(s.Findings))))
+	if s.environments.Configured() {
+		environments := make(map[string]int)
+		for _, finding := range s.Findings {
+			environments[finding.Environment]++
+		}
+		var parts []string
+		for _, environment := range rules.EnvironmentNames {
+			parts = append(parts, fmt.Sprintf("%d %s", environments[environment], environment))
+		}
+		s.paragraph(text(fmt.Sprintf("By environment: %s.", strings.Join(parts, ", "))))
+	}
+
+	columns := append(append([]string{}, rules.Severities...), rules.StatusAccepted)
+	var rows [][]run
+	for _, ruleId := range ruleIds {
+		row := []run{text(ruleId), text(titles[ruleId])}
+		for _, column := range columns {
+			row = append(row, text(fmt.Sprintf("%d", counts[ruleId][column])))
+		}
+		rows = append(rows, row)
+	}
+	s.table(append([]string{"Rule", "Description"}, columns...), rows)
+
+	if len(expired) > 0 {
+		s.heading(s.level+1, false, "Expired waivers")
+		s.paragraph(text("These findings were accepted as a risk, but the waiver has expired. They are reported again until the waiver is renewed or the finding is resolved."))
+		var rows [][]run
+		for _, finding := range expired {
+			rows = append(rows, []run{bold(finding.ResourceName), text(finding.Message), colored(finding.Waiver.Expires, colorDanger), text(finding.Waiver.Approver), text(finding.Waiver.Justification)})
+		}
+		s.table([]string{"Resource", "Finding", "Expired on", "Accepted by", "Justification"}, rows)
+	}
+
+	if len(accepted) > 0 {
+		s.heading(s.level+1, false, "Accepted risks")
+		var rows [][]run
+		for _, finding := range accepted {
+			rows = append(rows, []run{bold(finding.ResourceName), text(finding.Message), text(finding.RuleId), text(finding.Severity), text(finding.Waiver.Justification), text(finding.Waiver.Approver), text(finding.Waiver.Expires)})
+		}
+		s.table([]string{"Resource", "Finding", "Rule", "Severity", "Justification", "Approved by", "Until"}, rows)
+	}
+}
+
+func sortedByName(resources map[string]azure.Resource) []azure.Resource {
+	sorted := make([]azure.Resource, 0, len(resources))
+	for _, resource := range resources {
+		sorted = append(sorted, resource)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return strings.ToLower(sorted[i].Name) < strings.ToLower(sorted[j].Name)
+	})
+
+	return sorted
+}
+
+// groupByEnvironment sorts resources by name and groups them by environment. Without an environment mapping all
+// resources are in a single group without a name.
+func (s *subscriptionWriter) groupByEnvironment(resources map[string]azure.Resource) ([]string, map[string][]azure.Resource) {
+	groups := make(map[string][]azure.Resource)
+	for _, resource := range sortedByName(resources) {
+		environment := ""
+		if s.environments.Configured() {
+			environment = s.environments.Classify(resource.Tags)
+		}
+		groups[environment] = append(groups[environment], resource)
+	}
+
+	var names []string
+	for _, environment := range append([]string{""}, rules.EnvironmentNames...) {
+		if len(groups[environment]) > 0 {
+			names = append(names, environment)
+		}
+	}
+
+	return names, groups
+}
+
+// resourceLevel adds the heading of an environment group and returns the heading level of its resources.
+func (s *subscriptionWriter) resourceLevel(environment string) int {
+	if environment == "" {
+		return s.level + 1
+	}
+
+	s.heading(s.level+1, false, fmt.Sprintf("%s%s", strings.ToUpper(environment[:1]), environment[1:]))
+	return s.level + 2
+}
+
+func (s *subscriptionWriter) generateAlertRules(title string, resources map[string]azure.Resource) {
+	if len(resources) == 0 || !s.Settings.Enabled(scan.CheckAlertRules) {
+		return
+	}
+
+	s.section(fmt.Sprintf("Monitoring: %s", title))
+	environments, groups := s.groupByEnvironment(resources)
+	for _, environment := range environments {
+		level := s.resourceLevel(environment)
+		for _, resource := range groups[environment] {
+			s.heading(level, false, resource.Name)
+			findings := rules.ForResource(s.Findings, rules.RuleNoAlertRules, resource.Id)
+			if len(findings) > 0 {
+				s.generateFinding(findings[0])
+			} else if len(resource.AlertRules) == 0 && environment == rules.EnvironmentNonProduction {
+				s.paragraph(text("This is a non-production resource, it does not need alert rules."))
+			} else if len(resource.AlertRules) == 0 {
+				s.paragraph(text("Alert rules could not be checked for this resource."))
+			} else {
+				s.paragraph(text(fmt.Sprintf("This resource has %d alert rules configured:", len(resource.AlertRules))))
+				var rows [][]run
+				for _, rule := range resource.AlertRules {
+					for _, criterion := range rule.Criteria.AllOf {
+						rows = append(rows, []run{bold(rule.Name), text(fmt.Sprintf("%s %s %s %.2f", criterion.TimeAggregation, criterion.MetricName, criterion.Operator, criterion.Threshold))})
+					}
+				}
+				s.table([]string{"Rule", "Criteria"}, rows)
+				s.paragraph(bold("Action to be performed: "), text("Review alert rules and confirm that they are appropriate for this resource."))
+			}
+		}
+	}
+}
+
+// notChecked is shown for a resource a check could not be completed for, instead of a result.
+const notChecked = "This could not be checked, see Collection Problems."
+
+func (s *subscriptionWriter) generateBackups() {
+	if len(s.Result.VirtualMachines) == 0 || !s.Settings.Enabled(scan.CheckBackups) {
+		return
+	}
+
+	s.section("Virtual Machine Backups")
+	environments, groups := s.groupByEnvironment(s.Result.VirtualMachines)
+	for _, environment := range environments {
+		level := s.resourceLevel(environment)
+		for _, vm := range groups[environment] {
+			s.heading(level, false, vm.Name)
+			findings := rules.ForResource(s.Findings, rules.RuleNotBackedUp, vm.Id)
+			if s.Result.Failed(scan.CheckBackups, vm.Id) {
+				s.paragraph(text(notChecked))
+			} else if len(findings) > 0 {
+				s.generateFinding(findings[0])
+			} else if vm.BackupVault != nil {
+				s.paragraph(text(fmt.Sprintf("This virtual machine is backed up to %s.", vm.BackupVault.Name)))
+				s.paragraph(bold("Action to be performed: "), text("None"))
+			} else if environment == rules.EnvironmentNonProduction {
+				s.paragraph(text("This is a non-production machine, it does not need to be backed up."))
+			}
+		}
+	}
+}
+
+func (s *subscriptionWriter) generateDeallocatedVMs() {
+	if len(s.Result.VirtualMachinesDeallocated) == 0 {
+		return
+	}
+
+	s.section("Deallocated Virtual Machines")
+	var rows [][]run
+	for _, vm := range sortedByName(s.Result.VirtualMachinesDeallocated) {
+		rows = append(rows, []run{text(vm.Name), text(vm.ResourceGroup), text(vm.Location)})
+	}
+	s.table([]string{"Name", "Resource group", "Location"}, rows)
+}
+
+var impactColors = map[string]string{
+	"High":   colorDanger,
+	"Medium": colorWarn,
+}
+
+func (s *subscriptionWriter) generateRecommendations() {
+	if !s.Settings.Enabled(scan.CheckRecommendations) {
+		return
+	}
+
+	var categories []string
+	for category := range s.Result.Recommendations {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	for i, category := range categories {
+		// The categories follow each other like in the PDF, the first one starts the page.
+		s.heading(s.level, i == 0, fmt.Sprintf("Advisory Recommendations: %s", category))
+		recommendations := s.Result.Recommendations[category]
+		if len(recommendations) == 0 {
+			s.paragraph(text("No recommendations in this category. Looking good!"))
+			continue
+		}
+
+		var rows [][]run
+		for _, recommendation := range recommendations {
+			rows = append(rows, []run{
+				bold(recommendation.Description.Problem),
+				colored(recommendation.Impact, impactColors[recommendation.Impact]),
+				text(recommendation.ResourceType),
+				text(recommendation.AffectedResource),
+				text(recommendation.ResourceGroup),
+			})
+		}
+		s.table([]string{"Recommendation", "Impact", "Resource Type", "Affected Resource", "Resource Group"}, rows)
+	}
+}
+
+func (s *subscriptionWriter) generatePatches() {
+	if len(s.Result.VirtualMachines) == 0 || !s.Settings.Enabled(scan.CheckPatches) {
+		return
+	}
+
+	s.section("Virtual Machine Patches")
+	environments, groups := s.groupByEnvironment(s.Result.VirtualMachines)
+	for _, environment := range environments {
+		level := s.resourceLevel(environment)
+		for _, vm := range groups[environment] {
+			s.heading(level, false, vm.Name)
+			if s.Result.Failed(scan.CheckPatches, vm.Id) {
+				s.paragraph(text(notChecked))
+				continue
+			}
+			patches := vm.PatchAssessmentResult.AvailablePatches
+			s.paragraph(text(fmt.Sprintf("%d patches available.", len(patches))))
+			if len(patches) == 0 {
+				continue
+			}
+			var rows [][]run
+			for _, patch := range patches {
+				rows = append(rows, []run{bold(patch.Name), text(patch.PatchId), text(patch.KbId), text(patch.Version), text(patch.RebootBehavior)})
+			}
+			s.table([]string{"Patch Name", "Patch ID", "KB ID", "Version", "Reboot"}, rows)
+		}
+	}
+}
+
+// generateSections writes the sections of a subscription, everything after the cover and the summary.
+func (s *subscriptionWriter) generateSections() {
+	s.generateProblems()
+	s.generateChanges()
+	s.generateTrends()
+	s.generateFindings()
+	s.generateAlertRules("Virtual Machines", s.Result.VirtualMachines)
+	s.generateAlertRules("Azure Kubernetes Services", s.Result.AzureKubernetesServices)
+	s.generateAlertRules("MySQL Servers", s.Result.MySQLServers)
+	s.generateAlertRules("Flexible MySQL Servers", s.Result.FlexibleMySQLServers)
+	s.generateAlertRules("SQL Servers", s.Result.SqlServers)
+	s.generateAlertRules("Storage Accounts", s.Result.StorageAccounts)
+	s.generateAlertRules("Web Apps", s.Result.WebApps)
+	s.generateBackups()
+	s.generateDeallocatedVMs()
+	s.generateRecommendations()
+	s.generatePatches()
+}
+
+// GenerateDOCX writes the report to <OutputFilename>.docx.
+func (g Generator) GenerateDOCX() error {
+	if len(g.Subscriptions) == 0 {
+		return nil
+	}
+
+	// The table of contents lists the sections, and in a consolidated report the subscriptions above them.
+	consolidated := len(g.Subscriptions) > 1
+	levels := 1
+	if consolidated {
+		levels = 2
+	}
+
+	d := &document{}
+	g.generateCover(d)
+	d.tableOfContents(levels)
+	g.generateSummary(d)
+
+	for _, subscription := range g.Subscriptions {
+		s := subscriptionWriter{document: d, Subscription: subscription, level: 1, environments: g.Environments}
+		if consolidated {
+			d.heading(1, true, subscription.name())
+			d.paragraph(bold(fmt.Sprintf("Subscription ID: %s", subscription.Result.SubscriptionId)))
+			generateScorecardTable(d, []scorecard.Scorecard{subscription.Scorecard})
+			s.level = 2
+		}
+		s.generateSections()
+	}
+
+	filename := fmt.Sprintf("%s.docx", g.OutputFilename)
+	err := d.save(filename, g.Title, g.ClientName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(fmt.Sprintf("Saved Word document to %s", filename))
+
+	return nil
+}
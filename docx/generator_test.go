@@ -0,0 +1,152 @@
+package docx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jayps/azure-checker-go/history"
+	"github.com/jayps/azure-checker-go/rules"
+	"github.com/jayps/azure-checker-go/scan"
+	"github.com/jayps/azure-checker-go/scorecard"
+)
+
+var environments = rules.Environments{Production: []string{"environment=prod*"}}
+
+func loadSubscription(t *testing.T) Subscription {
+	t.Helper()
+
+	snapshot, err := scan.LoadSnapshot(filepath.Join("..", "scan", "testdata", "snapshot.json"))
+	if err != nil {
+		t.Fatalf("could not load the snapshot: %s", err)
+	}
+
+	findings := rules.Evaluate(rules.Builtin, snapshot, environments)
+	card := scorecard.Compute(snapshot, findings, environments)
+
+	return Subscription{
+		Result:    snapshot.Result,
+		Settings:  snapshot.Settings,
+		Findings:  findings,
+		Scorecard: card,
+		Trend:     []history.Point{history.PointOf(snapshot, card), history.PointOf(snapshot, card)},
+	}
+}
+
+// generate writes the document and returns the files in it.
+func generate(t *testing.T, subscriptions []Subscription) map[string]string {
+	t.Helper()
+
+	g := Generator{
+		Title:          "Managed Services Report",
+		ClientName:     "Acme <Holdings> & Sons",
+		OutputFilename: filepath.Join(t.TempDir(), "Acme"),
+		Subscriptions:  subscriptions,
+		Environments:   environments,
+	}
+	err := g.GenerateDOCX()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	archive, err := zip.OpenReader(g.OutputFilename + ".docx")
+	if err != nil {
+		t.Fatalf("the document is not a zip archive: %s", err)
+	}
+	defer archive.Close()
+
+	files := make(map[string]string)
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[file.Name] = string(data)
+	}
+
+	return files
+}
+
+// wellFormed reports whether every part of the document parses as XML.
+func wellFormed(t *testing.T, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		if !strings.HasSuffix(name, ".xml") && !strings.HasSuffix(name, ".rels") {
+			continue
+		}
+
+		decoder := xml.NewDecoder(strings.NewReader(content))
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("%s is not well-formed XML: %s", name, err)
+				break
+			}
+		}
+	}
+}
+
+func TestGenerateDOCX(t *testing.T) {
+	files := generate(t, []Subscription{loadSubscription(t)})
+
+	for _, name := range []string{"[Content_Types].xml", "word/document.xml", "word/styles.xml", "word/_rels/document.xml.rels"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("%s is missing from the document", name)
+		}
+	}
+	wellFormed(t, files)
+
+	document := files["word/document.xml"]
+	for _, want := range []string{"Acme &lt;Holdings&gt; &amp; Sons", "Executive Summary", "Collection Problems", "Findings", "vault1", "vm2", "KB1"} {
+		if !strings.Contains(document, want) {
+			t.Errorf("the document does not contain %q", want)
+		}
+	}
+}
+
+func TestGenerateDOCXConsolidated(t *testing.T) {
+	first := loadSubscription(t)
+	second := loadSubscription(t)
+	second.Result.SubscriptionName = "Dev-Payments"
+
+	files := generate(t, []Subscription{first, second})
+	wellFormed(t, files)
+
+	document := files["word/document.xml"]
+	for _, want := range []string{"Prod-Payments", "Dev-Payments"} {
+		if !strings.Contains(document, want) {
+			t.Errorf("the document does not have a chapter for %s", want)
+		}
+	}
+}
+
+func TestGenerateDOCXFailedChecks(t *testing.T) {
+	subscription := loadSubscription(t)
+	for _, vm := range subscription.Result.VirtualMachines {
+		subscription.Result.Problems = append(subscription.Result.Problems,
+			scan.Problem{Check: "backups for VM " + vm.Name, ResourceId: vm.Id},
+			scan.Problem{Check: "patches for VM " + vm.Name, ResourceId: vm.Id},
+		)
+	}
+
+	document := generate(t, []Subscription{subscription})["word/document.xml"]
+
+	if got := strings.Count(document, notChecked); got != 2*len(subscription.Result.VirtualMachines) {
+		t.Errorf("got %d notes that a check could not be completed, want %d", got, 2*len(subscription.Result.VirtualMachines))
+	}
+	if strings.Contains(document, "vault1") {
+		t.Error("the backup vault of a VM whose backups could not be checked is reported")
+	}
+}
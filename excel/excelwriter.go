@@ -1,9 +1,16 @@
 package excel
 
 import (
+	"encoding/json"
 	"fmt"
+	"math"
 
 	"github.com/jayps/azure-checker-go/azure"
+	"github.com/jayps/azure-checker-go/diff"
+	"github.com/jayps/azure-checker-go/history"
+	"github.com/jayps/azure-checker-go/rules"
+	"github.com/jayps/azure-checker-go/scan"
+	"github.com/jayps/azure-checker-go/scorecard"
 	"github.com/xuri/excelize/v2"
 )
 
@@ -59,56 +66,159 @@ func writeCell(f *excelize.File, sheet string, cellLocation string, text string)
 	return nil
 }
 
-func writeResourceAlerts(f *excelize.File, sheet string, resources map[string]azure.Resource) error {
-	lineIndex := 1
-	err := addHeading(f, sheet, fmt.Sprintf("A%d", lineIndex), fmt.Sprintf("%d resources found", len(resources)))
-	if err != nil {
-		return err
+// Subscription is what the workbook reports about one subscription.
+type Subscription struct {
+	Result    scan.Result
+	Settings  scan.Settings
+	Findings  []rules.Finding
+	Scorecard scorecard.Scorecard
+	Changes   *diff.Changes // nil when there is no earlier scan to compare with
+	Trend     []history.Point
+}
+
+func (s Subscription) name() string {
+	if s.Result.SubscriptionName != "" {
+		return s.Result.SubscriptionName
 	}
-	lineIndex++
 
-	for _, resource := range resources {
-		err = addBoldCell(f, sheet, fmt.Sprintf("A%d", lineIndex), resource.Name)
+	return s.Result.SubscriptionId
+}
+
+// rowCell returns the location of a cell. In a workbook of several subscriptions every table starts with a
+// Subscription column, so the other columns move one to the right.
+func rowCell(column rune, row int, consolidated bool) string {
+	if consolidated {
+		column++
+	}
+
+	return fmt.Sprintf("%c%d", column, row)
+}
+
+// writeSubscription fills in the Subscription column of a row, when there is one.
+func writeSubscription(f *excelize.File, sheet string, row int, consolidated bool, subscription Subscription) error {
+	if !consolidated {
+		return nil
+	}
+
+	return writeCell(f, sheet, fmt.Sprintf("A%d", row), subscription.name())
+}
+
+// writeHeaders writes a row of bold headers, starting with Subscription in a workbook of several subscriptions.
+func writeHeaders(f *excelize.File, sheet string, row int, consolidated bool, headers []string) error {
+	if consolidated {
+		headers = append([]string{"Subscription"}, headers...)
+	}
+	for i, header := range headers {
+		err := addBoldCell(f, sheet, fmt.Sprintf("%c%d", 'A'+i, row), header)
 		if err != nil {
 			return err
 		}
-		err = addBoldCell(f, sheet, fmt.Sprintf("B%d", lineIndex), fmt.Sprintf("%d alerts configured", len(resource.AlertRules)))
+	}
+
+	return nil
+}
+
+// writeRow writes a row of values, starting with the subscription in a workbook of several subscriptions.
+func writeRow(f *excelize.File, sheet string, row int, consolidated bool, subscription Subscription, values []string) error {
+	err := writeSubscription(f, sheet, row, consolidated, subscription)
+	if err != nil {
+		return err
+	}
+	for i, value := range values {
+		err = writeCell(f, sheet, rowCell('A'+rune(i), row, consolidated), value)
 		if err != nil {
 			return err
 		}
-		lineIndex++
+	}
+
+	return nil
+}
+
+// writeEnvironment writes the environment of a resource, when resources are classified at all.
+func writeEnvironment(f *excelize.File, sheet string, cellLocation string, environments rules.Environments, resource azure.Resource) error {
+	if !environments.Configured() {
+		return nil
+	}
+
+	return writeCell(f, sheet, cellLocation, environments.Classify(resource.Tags))
+}
 
-		if len(resource.AlertRules) > 0 {
-			err = addBoldCell(f, sheet, fmt.Sprintf("B%d", lineIndex), "Name")
+func writeResourceAlerts(f *excelize.File, sheet string, subscriptions []Subscription, resourcesOf func(scan.Result) map[string]azure.Resource, environments rules.Environments) error {
+	consolidated := len(subscriptions) > 1
+	count := 0
+	for _, subscription := range subscriptions {
+		count += len(resourcesOf(subscription.Result))
+	}
+
+	lineIndex := 1
+	err := addHeading(f, sheet, fmt.Sprintf("A%d", lineIndex), fmt.Sprintf("%d resources found", count))
+	if err != nil {
+		return err
+	}
+	lineIndex++
+
+	for _, subscription := range subscriptions {
+		for _, resource := range resourcesOf(subscription.Result) {
+			err = writeSubscription(f, sheet, lineIndex, consolidated, subscription)
 			if err != nil {
 				return err
 			}
-
-			err = addBoldCell(f, sheet, fmt.Sprintf("C%d", lineIndex), "Criteria")
+			err = addBoldCell(f, sheet, rowCell('A', lineIndex, consolidated), resource.Name)
+			if err != nil {
+				return err
+			}
+			err = addBoldCell(f, sheet, rowCell('B', lineIndex, consolidated), fmt.Sprintf("%d alerts configured", len(resource.AlertRules)))
+			if err != nil {
+				return err
+			}
+			for _, finding := range rules.ForResource(subscription.Findings, rules.RuleNoAlertRules, resource.Id) {
+				err = writeCell(f, sheet, rowCell('C', lineIndex, consolidated), findingSummary(finding))
+				if err != nil {
+					return err
+				}
+			}
+			err = writeEnvironment(f, sheet, rowCell('D', lineIndex, consolidated), environments, resource)
 			if err != nil {
 				return err
 			}
-
 			lineIndex++
 
-			for _, alertRule := range resource.AlertRules {
-				err = writeCell(f, sheet, fmt.Sprintf("B%d", lineIndex), alertRule.Name)
+			if len(resource.AlertRules) > 0 {
+				err = addBoldCell(f, sheet, rowCell('B', lineIndex, consolidated), "Name")
+				if err != nil {
+					return err
+				}
+
+				err = addBoldCell(f, sheet, rowCell('C', lineIndex, consolidated), "Criteria")
 				if err != nil {
 					return err
 				}
 
-				for _, criterion := range alertRule.Criteria.AllOf {
-					criterionOutput := fmt.Sprintf("%s %s %s %s", criterion.TimeAggregation,
-						criterion.MetricName,
-						criterion.Operator,
-						fmt.Sprintf("%.2f", criterion.Threshold),
-					)
-					err = writeCell(f, sheet, fmt.Sprintf("C%d", lineIndex), criterionOutput)
+				lineIndex++
+
+				for _, alertRule := range resource.AlertRules {
+					err = writeCell(f, sheet, rowCell('B', lineIndex, consolidated), alertRule.Name)
 					if err != nil {
 						return err
 					}
 
-					lineIndex++
+					for _, criterion := range alertRule.Criteria.AllOf {
+						criterionOutput := fmt.Sprintf("%s %s %s %s", criterion.TimeAggregation,
+							criterion.MetricName,
+							criterion.Operator,
+							fmt.Sprintf("%.2f", criterion.Threshold),
+						)
+						err = writeSubscription(f, sheet, lineIndex, consolidated, subscription)
+						if err != nil {
+							return err
+						}
+						err = writeCell(f, sheet, rowCell('C', lineIndex, consolidated), criterionOutput)
+						if err != nil {
+							return err
+						}
+
+						lineIndex++
+					}
 				}
 			}
 		}
@@ -117,262 +227,685 @@ func writeResourceAlerts(f *excelize.File, sheet string, resources map[string]az
 	return nil
 }
 
-func writeResourceBackups(f *excelize.File, vms map[string]azure.Resource) error {
+// notChecked is written for a resource a check could not be completed for, instead of a result.
+const notChecked = "Could not be checked, see Collection Problems"
+
+func writeResourceBackups(f *excelize.File, subscriptions []Subscription, environments rules.Environments) error {
+	consolidated := len(subscriptions) > 1
 	lineIndex := 1
-	for _, vm := range vms {
-		err := writeCell(f, "Backups", fmt.Sprintf("A%d", lineIndex), vm.Name)
-		if err != nil {
-			return err
+	for _, subscription := range subscriptions {
+		if !subscription.Settings.Enabled(scan.CheckBackups) {
+			continue
 		}
-
-		if vm.BackupVault != nil {
-			err = writeCell(f, "Backups", fmt.Sprintf("B%d", lineIndex), vm.BackupVault.Name)
+		for _, vm := range subscription.Result.VirtualMachines {
+			err := writeSubscription(f, "Backups", lineIndex, consolidated, subscription)
 			if err != nil {
 				return err
 			}
-
-		} else {
-			err = writeCell(f, "Backups", fmt.Sprintf("B%d", lineIndex), "Not backed up")
+			err = writeCell(f, "Backups", rowCell('A', lineIndex, consolidated), vm.Name)
 			if err != nil {
 				return err
 			}
 
+			if subscription.Result.Failed(scan.CheckBackups, vm.Id) {
+				err = writeCell(f, "Backups", rowCell('B', lineIndex, consolidated), notChecked)
+				if err != nil {
+					return err
+				}
+			} else if vm.BackupVault != nil {
+				err = writeCell(f, "Backups", rowCell('B', lineIndex, consolidated), vm.BackupVault.Name)
+				if err != nil {
+					return err
+				}
+
+			}
+			for _, finding := range rules.ForResource(subscription.Findings, rules.RuleNotBackedUp, vm.Id) {
+				err = writeCell(f, "Backups", rowCell('B', lineIndex, consolidated), findingSummary(finding))
+				if err != nil {
+					return err
+				}
+			}
+			err = writeEnvironment(f, "Backups", rowCell('C', lineIndex, consolidated), environments, vm)
+			if err != nil {
+				return err
+			}
+			lineIndex++
 		}
-		lineIndex++
 	}
 
 	return nil
 }
 
-func writeRecommendations(f *excelize.File, recommendations map[string][]azure.AdvisorRecommendation) error {
-	for category, categoryRecommendations := range recommendations {
-		sheetTitle := fmt.Sprintf("%s Recs", category)
-		f.NewSheet(sheetTitle)
-		index := 1
-
-		err := writeCell(f, sheetTitle, fmt.Sprintf("A%d", index), "Recommendation")
-		if err != nil {
-			return err
-		}
-		err = writeCell(f, sheetTitle, fmt.Sprintf("B%d", index), "Impact")
-		if err != nil {
-			return err
+func writeRecommendations(f *excelize.File, subscriptions []Subscription) error {
+	consolidated := len(subscriptions) > 1
+	indexes := make(map[string]int)
+	for _, subscription := range subscriptions {
+		if !subscription.Settings.Enabled(scan.CheckRecommendations) {
+			continue
 		}
+		for category, categoryRecommendations := range subscription.Result.Recommendations {
+			sheetTitle := fmt.Sprintf("%s Recs", category)
+			index, found := indexes[category]
+			if !found {
+				f.NewSheet(sheetTitle)
+				index = 1
+
+				err := writeHeaders(f, sheetTitle, index, consolidated, []string{"Recommendation", "Impact", "Resource type", "Affected resource", "Resource group"})
+				if err != nil {
+					return err
+				}
 
-		err = writeCell(f, sheetTitle, fmt.Sprintf("C%d", index), "Resource type")
-		if err != nil {
-			return err
+				index++
+			}
+
+			for i := 0; i < len(categoryRecommendations); i++ {
+				err := writeRow(f, sheetTitle, index+1, consolidated, subscription, []string{
+					categoryRecommendations[i].Description.Problem,
+					categoryRecommendations[i].Impact,
+					categoryRecommendations[i].ResourceType,
+					categoryRecommendations[i].AffectedResource,
+					categoryRecommendations[i].ResourceGroup,
+				})
+				if err != nil {
+					return err
+				}
+
+				index++
+			}
+			indexes[category] = index
 		}
+	}
 
-		err = writeCell(f, sheetTitle, fmt.Sprintf("D%d", index), "Affected resource")
-		if err != nil {
-			return err
+	return nil
+}
+
+func writeVMsDeallocated(f *excelize.File, sheetName string, subscriptions []Subscription) error {
+	consolidated := len(subscriptions) > 1
+	lineIndex := 1
+	for _, subscription := range subscriptions {
+		for _, vm := range subscription.Result.VirtualMachinesDeallocated {
+			err := writeRow(f, sheetName, lineIndex, consolidated, subscription, []string{vm.Name})
+			if err != nil {
+				return err
+			}
+			lineIndex++
 		}
+	}
+
+	return nil
+}
 
-		err = writeCell(f, sheetTitle, fmt.Sprintf("E%d", index), "Resource group")
+func writeVMsPatches(f *excelize.File, sheetName string, subscriptions []Subscription) error {
+	consolidated := len(subscriptions) > 1
+	lineIndex := 1
+	// Write header columns
+	headers := []string{"VM Name", "Patch Name", "Classification", "Patch ID", "KB ID", "Reboot", "Version"}
+	if consolidated {
+		headers = append([]string{"Subscription"}, headers...)
+	}
+	for i, header := range headers {
+		err := addHeading(f, sheetName, fmt.Sprintf("%c%d", 'A'+i, lineIndex), header)
 		if err != nil {
 			return err
 		}
-
-		index++
-
-		for i := 0; i < len(categoryRecommendations); i++ {
-			err = writeCell(f, sheetTitle, fmt.Sprintf("A%d", index+1), categoryRecommendations[i].Description.Problem)
+	}
+	lineIndex++
+	for _, subscription := range subscriptions {
+		if !subscription.Settings.Enabled(scan.CheckPatches) {
+			continue
+		}
+		for _, vm := range subscription.Result.VirtualMachines {
+			err := writeSubscription(f, sheetName, lineIndex, consolidated, subscription)
 			if err != nil {
 				return err
 			}
-
-			err = writeCell(f, sheetTitle, fmt.Sprintf("B%d", index+1), categoryRecommendations[i].Impact)
+			err = addBoldCell(f, sheetName, rowCell('A', lineIndex, consolidated), vm.Name)
 			if err != nil {
 				return err
 			}
 
-			err = writeCell(f, sheetTitle, fmt.Sprintf("C%d", index+1), categoryRecommendations[i].ResourceType)
+			if subscription.Result.Failed(scan.CheckPatches, vm.Id) {
+				err = writeCell(f, sheetName, rowCell('B', lineIndex, consolidated), notChecked)
+				if err != nil {
+					return err
+				}
+				lineIndex++
+			}
+			for _, patch := range vm.PatchAssessmentResult.AvailablePatches {
+				err = writeSubscription(f, sheetName, lineIndex, consolidated, subscription)
+				if err != nil {
+					return err
+				}
+				err = writeCell(f, sheetName, rowCell('B', lineIndex, consolidated), patch.Name)
+				if err != nil {
+					return err
+				}
+				// Azure leaves the classifications out for some patches.
+				classification := ""
+				if len(patch.Classifications) > 0 {
+					classification = patch.Classifications[0]
+				}
+				err = writeCell(f, sheetName, rowCell('C', lineIndex, consolidated), classification)
+				if err != nil {
+					return err
+				}
+				err = writeCell(f, sheetName, rowCell('D', lineIndex, consolidated), patch.PatchId)
+				if err != nil {
+					return err
+				}
+				err = writeCell(f, sheetName, rowCell('E', lineIndex, consolidated), patch.KbId)
+				if err != nil {
+					return err
+				}
+				err = writeCell(f, sheetName, rowCell('F', lineIndex, consolidated), patch.RebootBehavior)
+				if err != nil {
+					return err
+				}
+				err = writeCell(f, sheetName, rowCell('G', lineIndex, consolidated), patch.Version)
+				if err != nil {
+					return err
+				}
+				lineIndex++
+			}
+			lineIndex++
+		}
+	}
+
+	return nil
+}
+
+func writeProblems(f *excelize.File, sheetName string, subscriptions []Subscription) error {
+	consolidated := len(subscriptions) > 1
+	lineIndex := 1
+	err := writeHeaders(f, sheetName, lineIndex, consolidated, []string{"Check", "Reason", "Message", "Action to be performed"})
+	if err != nil {
+		return err
+	}
+	lineIndex++
+
+	for _, subscription := range subscriptions {
+		for _, problem := range subscription.Result.Problems {
+			err = writeRow(f, sheetName, lineIndex, consolidated, subscription, []string{problem.Check, string(problem.Kind), problem.Message, problem.Hint})
 			if err != nil {
 				return err
 			}
+			lineIndex++
+		}
+	}
+
+	return nil
+}
+
+// findingSummary describes a finding in a single cell, with the justification when the risk was accepted.
+func findingSummary(finding rules.Finding) string {
+	switch finding.Status() {
+	case rules.StatusAccepted:
+		return fmt.Sprintf("%s: %s", rules.StatusAccepted, finding.Waiver.Justification)
+	case rules.StatusWaiverExpired:
+		return fmt.Sprintf("%s (waiver expired on %s)", finding.Message, finding.Waiver.Expires)
+	}
 
-			err = writeCell(f, sheetTitle, fmt.Sprintf("D%d", index+1), categoryRecommendations[i].AffectedResource)
+	return finding.Message
+}
+
+func writeFindings(f *excelize.File, sheetName string, subscriptions []Subscription) error {
+	consolidated := len(subscriptions) > 1
+	lineIndex := 1
+	err := writeHeaders(f, sheetName, lineIndex, consolidated, []string{"Severity", "Rule", "Description", "Resource type", "Resource", "Environment", "Finding", "Action to be performed", "Status", "Justification", "Approver", "Waiver expires"})
+	if err != nil {
+		return err
+	}
+	lineIndex++
+
+	for _, subscription := range subscriptions {
+		for _, finding := range subscription.Findings {
+			values := []string{finding.Severity, finding.RuleId, finding.Title, finding.ResourceType, finding.ResourceName, finding.Environment, finding.Message, finding.Remediation, finding.Status()}
+			if finding.Waiver != nil {
+				values = append(values, finding.Waiver.Justification, finding.Waiver.Approver, finding.Waiver.Expires)
+			}
+			err = writeRow(f, sheetName, lineIndex, consolidated, subscription, values)
 			if err != nil {
 				return err
 			}
+			lineIndex++
+		}
+	}
+
+	return nil
+}
+
+var statusColors = map[string]string{
+	scorecard.Green: "#C6EFCE",
+	scorecard.Amber: "#FFEB9C",
+	scorecard.Red:   "#FFC7CE",
+}
+
+// addStatusCell writes a value filled with the colour of its RAG status.
+func addStatusCell(f *excelize.File, sheet string, cellLocation string, text string, status string) error {
+	if statusColors[status] == "" {
+		return writeCell(f, sheet, cellLocation, text)
+	}
+
+	style, err := f.NewStyle(&excelize.Style{
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{statusColors[status]}},
+	})
+	if err != nil {
+		return err
+	}
+
+	return addStyledCell(f, sheet, cellLocation, text, style)
+}
+
+// writeSummary writes the scorecard of each subscription, followed by the totals of all of them when there are
+// several.
+func writeSummary(f *excelize.File, sheetName string, subscriptions []Subscription) error {
+	err := addHeading(f, sheetName, "A1", "Summary")
+	if err != nil {
+		return err
+	}
+
+	var scorecards []scorecard.Scorecard
+	for _, subscription := range subscriptions {
+		scorecards = append(scorecards, subscription.Scorecard)
+	}
+	if len(subscriptions) > 1 {
+		scorecards = append(scorecards, scorecard.Combine("All subscriptions", scorecards))
+	}
+
+	lineIndex := 3
+	headers := []string{"Subscription", "Subscription ID"}
+	for _, metric := range scorecards[0].Metrics() {
+		headers = append(headers, metric.Name)
+	}
+	headers = append(headers, "Status")
+	for i, header := range headers {
+		err = addBoldCell(f, sheetName, fmt.Sprintf("%c%d", 'A'+i, lineIndex), header)
+		if err != nil {
+			return err
+		}
+	}
+	lineIndex++
 
-			err = writeCell(f, sheetTitle, fmt.Sprintf("E%d", index+1), categoryRecommendations[i].ResourceGroup)
+	for _, card := range scorecards {
+		metrics := append(card.Metrics(), scorecard.Metric{Value: card.Status, Status: card.Status})
+		err = writeCell(f, sheetName, fmt.Sprintf("A%d", lineIndex), card.SubscriptionName)
+		if err != nil {
+			return err
+		}
+		err = writeCell(f, sheetName, fmt.Sprintf("B%d", lineIndex), card.SubscriptionId)
+		if err != nil {
+			return err
+		}
+		for i, metric := range metrics {
+			err = addStatusCell(f, sheetName, fmt.Sprintf("%c%d", 'C'+i, lineIndex), metric.Value, metric.Status)
 			if err != nil {
 				return err
 			}
+		}
+		lineIndex++
+	}
+	lineIndex++
 
-			index++
+	err = addBoldCell(f, sheetName, fmt.Sprintf("A%d", lineIndex), "Open findings")
+	if err != nil {
+		return err
+	}
+	lineIndex++
+	open := make(map[string]int)
+	accepted := 0
+	for _, subscription := range subscriptions {
+		for _, finding := range subscription.Findings {
+			if finding.Accepted() {
+				accepted++
+			} else {
+				open[finding.Severity]++
+			}
+		}
+	}
+	for _, severity := range rules.Severities {
+		err = writeCell(f, sheetName, fmt.Sprintf("A%d", lineIndex), severity)
+		if err != nil {
+			return err
+		}
+		err = f.SetCellValue(sheetName, fmt.Sprintf("B%d", lineIndex), open[severity])
+		if err != nil {
+			return err
 		}
+		lineIndex++
+	}
+	err = writeCell(f, sheetName, fmt.Sprintf("A%d", lineIndex), rules.StatusAccepted)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return f.SetCellValue(sheetName, fmt.Sprintf("B%d", lineIndex), accepted)
 }
 
-func writeVMsDeallocated(f *excelize.File, sheetName string, vms map[string]azure.Resource) error {
+func writeChanges(f *excelize.File, sheetName string, subscriptions []Subscription) error {
+	consolidated := len(subscriptions) > 1
 	lineIndex := 1
-	for _, vm := range vms {
-		err := writeCell(f, sheetName, fmt.Sprintf("A%d", lineIndex), vm.Name)
+	for _, subscription := range subscriptions {
+		if subscription.Changes == nil {
+			continue
+		}
+		changes := *subscription.Changes
+		text := fmt.Sprintf("Compared with the scan of %d-%d-%d", changes.Before.Year(), changes.Before.Month(), changes.Before.Day())
+		if consolidated {
+			text = fmt.Sprintf("%s: %s", subscription.name(), text)
+		}
+		err := addBoldCell(f, sheetName, fmt.Sprintf("A%d", lineIndex), text)
 		if err != nil {
 			return err
 		}
 		lineIndex++
 	}
+	lineIndex++
+
+	err := writeHeaders(f, sheetName, lineIndex, consolidated, []string{"Change", "Rule", "Resource", "Description"})
+	if err != nil {
+		return err
+	}
+	lineIndex++
+
+	for _, subscription := range subscriptions {
+		if subscription.Changes == nil {
+			continue
+		}
+
+		var rows [][]string
+		for _, change := range subscription.Changes.Resources {
+			rows = append(rows, []string{change.Change, change.ResourceType, change.Resource.Name, fmt.Sprintf("Resource %s", change.Change)})
+		}
+		for _, change := range subscription.Changes.Findings {
+			rows = append(rows, []string{change.Change, change.Finding.RuleId, change.Finding.ResourceName, change.Finding.Message})
+		}
+
+		for _, row := range rows {
+			err = writeRow(f, sheetName, lineIndex, consolidated, subscription, row)
+			if err != nil {
+				return err
+			}
+			lineIndex++
+		}
+	}
 
 	return nil
 }
 
-func writeVMsPatches(f *excelize.File, sheetName string, vms map[string]azure.Resource) error {
-	lineIndex := 1
-	// Write header columns
-	err := addHeading(f, sheetName, fmt.Sprintf("A%d", lineIndex), "VM Name")
-	err = addHeading(f, sheetName, fmt.Sprintf("B%d", lineIndex), "Patch Name")
-	err = addHeading(f, sheetName, fmt.Sprintf("C%d", lineIndex), "Classification")
-	err = addHeading(f, sheetName, fmt.Sprintf("D%d", lineIndex), "Patch ID")
-	err = addHeading(f, sheetName, fmt.Sprintf("E%d", lineIndex), "KB ID")
-	err = addHeading(f, sheetName, fmt.Sprintf("F%d", lineIndex), "Reboot")
-	err = addHeading(f, sheetName, fmt.Sprintf("G%d", lineIndex), "Version")
+// addLineChart charts columns of the trend table against the dates in column A.
+func addLineChart(f *excelize.File, sheetName string, cell string, title string, columns []int, rows int) error {
+	var series []map[string]string
+	for _, column := range columns {
+		name, err := excelize.ColumnNumberToName(column)
+		if err != nil {
+			return err
+		}
+		series = append(series, map[string]string{
+			"name":       fmt.Sprintf("'%s'!$%s$1", sheetName, name),
+			"categories": fmt.Sprintf("'%s'!$A$2:$A$%d", sheetName, rows+1),
+			"values":     fmt.Sprintf("'%s'!$%s$2:$%s$%d", sheetName, name, name, rows+1),
+		})
+	}
+
+	format, err := json.Marshal(map[string]interface{}{
+		"type":      "line",
+		"series":    series,
+		"title":     map[string]string{"name": title},
+		"legend":    map[string]string{"position": "bottom"},
+		"dimension": map[string]int{"width": 640, "height": 280},
+	})
 	if err != nil {
 		return err
 	}
-	lineIndex++
-	for _, vm := range vms {
-		err := addBoldCell(f, sheetName, fmt.Sprintf("A%d", lineIndex), vm.Name)
+
+	return f.AddChart(sheetName, cell, string(format))
+}
+
+// writeTrend writes the trend of each subscription. The charts are only added for a single subscription, the
+// trends of several subscriptions are different series over different dates.
+func writeTrend(f *excelize.File, sheetName string, subscriptions []Subscription) error {
+	consolidated := len(subscriptions) > 1
+	var points []history.Point
+	for _, subscription := range subscriptions {
+		points = append(points, subscription.Trend...)
+	}
+	categories := history.Categories(points)
+	headers := append([]string{"Date", "Alert coverage (%)", "Backup coverage (%)", "Critical and security patches"}, categories...)
+	if consolidated {
+		headers = append([]string{"Subscription"}, headers...)
+	}
+	for i, header := range headers {
+		cell, err := excelize.CoordinatesToCellName(i+1, 1)
+		if err != nil {
+			return err
+		}
+		err = addBoldCell(f, sheetName, cell, header)
 		if err != nil {
 			return err
 		}
+	}
 
-		for _, patch := range vm.PatchAssessmentResult.AvailablePatches {
-			err = writeCell(f, sheetName, fmt.Sprintf("B%d", lineIndex), patch.Name)
-			if err != nil {
-				return err
+	row := 0
+	for _, subscription := range subscriptions {
+		// A single scan is not a trend.
+		if len(subscription.Trend) < 2 {
+			continue
+		}
+		for _, point := range subscription.Trend {
+			var values []interface{}
+			if consolidated {
+				values = append(values, subscription.name())
 			}
-			err = writeCell(f, sheetName, fmt.Sprintf("C%d", lineIndex), patch.Classifications[0])
-			if err != nil {
-				return err
+			values = append(values, fmt.Sprintf("%d-%d-%d", point.Time.Year(), point.Time.Month(), point.Time.Day()))
+			// Metrics that were not measured are left empty rather than written as zero.
+			if point.AlertCoverage != nil {
+				values = append(values, math.Round(*point.AlertCoverage*10)/10)
+			} else {
+				values = append(values, nil)
 			}
-			err = writeCell(f, sheetName, fmt.Sprintf("D%d", lineIndex), patch.PatchId)
-			if err != nil {
-				return err
+			if point.BackupCoverage != nil {
+				values = append(values, math.Round(*point.BackupCoverage*10)/10)
+			} else {
+				values = append(values, nil)
 			}
-			err = writeCell(f, sheetName, fmt.Sprintf("E%d", lineIndex), patch.KbId)
-			if err != nil {
-				return err
+			if point.CriticalPatches != nil {
+				values = append(values, *point.CriticalPatches)
+			} else {
+				values = append(values, nil)
 			}
-			err = writeCell(f, sheetName, fmt.Sprintf("F%d", lineIndex), patch.RebootBehavior)
-			if err != nil {
-				return err
+			for _, category := range categories {
+				if point.Recommendations != nil {
+					values = append(values, point.Recommendations[category])
+				} else {
+					values = append(values, nil)
+				}
 			}
-			err = writeCell(f, sheetName, fmt.Sprintf("G%d", lineIndex), patch.Version)
-			if err != nil {
-				return err
+
+			for column, value := range values {
+				if value == nil {
+					continue
+				}
+				cell, err := excelize.CoordinatesToCellName(column+1, row+2)
+				if err != nil {
+					return err
+				}
+				err = f.SetCellValue(sheetName, cell, value)
+				if err != nil {
+					return err
+				}
 			}
-			lineIndex++
+			row++
 		}
-		lineIndex++
 	}
 
-	return nil
+	if consolidated {
+		return nil
+	}
+
+	chartColumn, err := excelize.ColumnNumberToName(len(headers) + 2)
+	if err != nil {
+		return err
+	}
+
+	err = addLineChart(f, sheetName, fmt.Sprintf("%s1", chartColumn), "Monitoring and backup coverage (%)", []int{2, 3}, row)
+	if err != nil {
+		return err
+	}
+
+	err = addLineChart(f, sheetName, fmt.Sprintf("%s16", chartColumn), "Outstanding critical and security patches", []int{4}, row)
+	if err != nil {
+		return err
+	}
+
+	if len(categories) == 0 {
+		return nil
+	}
+
+	var categoryColumns []int
+	for i := range categories {
+		categoryColumns = append(categoryColumns, 5+i)
+	}
+
+	return addLineChart(f, sheetName, fmt.Sprintf("%s31", chartColumn), "Advisor recommendations by category", categoryColumns, row)
 }
 
-func OutputExcelDocument(
-	outputFilename string,
-	vms map[string]azure.Resource,
-	vmsDeallocated map[string]azure.Resource,
-	aksClusters map[string]azure.Resource,
-	mySQLServers map[string]azure.Resource,
-	flexibleMySQLServers map[string]azure.Resource,
-	sqlServers map[string]azure.Resource,
-	storageAccounts map[string]azure.Resource,
-	webApps map[string]azure.Resource,
-	recommendations map[string][]azure.AdvisorRecommendation,
-) error {
+// addResourceAlertsSheet writes the alert rules of a resource type to its own sheet, unless no subscription has
+// such resources with the alert rules check switched on.
+func addResourceAlertsSheet(f *excelize.File, sheetName string, subscriptions []Subscription, resourcesOf func(scan.Result) map[string]azure.Resource, environments rules.Environments) error {
+	var checked []Subscription
+	for _, subscription := range subscriptions {
+		if len(resourcesOf(subscription.Result)) > 0 && subscription.Settings.Enabled(scan.CheckAlertRules) {
+			checked = append(checked, subscription)
+		}
+	}
+	if len(checked) == 0 {
+		return nil
+	}
+
+	f.NewSheet(sheetName)
+	return writeResourceAlerts(f, sheetName, checked, resourcesOf, environments)
+}
+
+// OutputExcelDocument writes the workbook of one or more subscriptions. With several subscriptions the summary adds
+// their totals, and every table starts with a Subscription column.
+func OutputExcelDocument(outputFilename string, subscriptions []Subscription, environments rules.Environments) error {
 	f := excelize.NewFile()
 
-	if len(vms) > 0 {
-		f.SetSheetName("Sheet1", "VM Alerts")
-		err := writeResourceAlerts(f, "VM Alerts", vms)
-		if err != nil {
-			return err
+	anySubscription := func(included func(Subscription) bool) bool {
+		for _, subscription := range subscriptions {
+			if included(subscription) {
+				return true
+			}
 		}
+		return false
+	}
 
-		sheetName := "VM Patches"
+	if len(subscriptions) > 0 {
+		sheetName := "Summary"
 		f.NewSheet(sheetName)
-		err = writeVMsPatches(f, sheetName, vms)
+		err := writeSummary(f, sheetName, subscriptions)
 		if err != nil {
 			return err
 		}
-	} else {
-		f.DeleteSheet("Sheet1")
 	}
 
-	if len(vmsDeallocated) > 0 {
-		sheetName := "VM's Deallocated"
+	// The changes come next, they are what the monthly review is about.
+	if anySubscription(func(s Subscription) bool { return s.Changes != nil }) {
+		sheetName := "Changes"
 		f.NewSheet(sheetName)
-		err := writeVMsDeallocated(f, sheetName, vmsDeallocated)
+		err := writeChanges(f, sheetName, subscriptions)
 		if err != nil {
 			return err
 		}
 	}
 
-	if len(aksClusters) > 0 {
-		f.NewSheet("AKS Cluster Alerts")
-		err := writeResourceAlerts(f, "AKS Cluster Alerts", aksClusters)
+	// A single scan is not a trend.
+	if anySubscription(func(s Subscription) bool { return len(s.Trend) > 1 }) {
+		sheetName := "Trends"
+		f.NewSheet(sheetName)
+		err := writeTrend(f, sheetName, subscriptions)
 		if err != nil {
 			return err
 		}
 	}
 
-	if len(mySQLServers) > 0 {
-		f.NewSheet("MySQL Server Alerts")
-		err := writeResourceAlerts(f, "MySQL Server Alerts", mySQLServers)
+	if anySubscription(func(s Subscription) bool { return len(s.Findings) > 0 }) {
+		sheetName := "Findings"
+		f.NewSheet(sheetName)
+		err := writeFindings(f, sheetName, subscriptions)
 		if err != nil {
 			return err
 		}
 	}
 
-	if len(flexibleMySQLServers) > 0 {
-		f.NewSheet("Flexible MySQL Server Alerts")
-		err := writeResourceAlerts(f, "Flexible MySQL Server Alerts", flexibleMySQLServers)
+	err := addResourceAlertsSheet(f, "VM Alerts", subscriptions, func(r scan.Result) map[string]azure.Resource { return r.VirtualMachines }, environments)
+	if err != nil {
+		return err
+	}
+
+	if anySubscription(func(s Subscription) bool {
+		return len(s.Result.VirtualMachines) > 0 && s.Settings.Enabled(scan.CheckPatches)
+	}) {
+		sheetName := "VM Patches"
+		f.NewSheet(sheetName)
+		err = writeVMsPatches(f, sheetName, subscriptions)
 		if err != nil {
 			return err
 		}
 	}
 
-	if len(sqlServers) > 0 {
-		f.NewSheet("SQL Server Alerts")
-		err := writeResourceAlerts(f, "SQL Server Alerts", sqlServers)
+	if anySubscription(func(s Subscription) bool { return len(s.Result.VirtualMachinesDeallocated) > 0 }) {
+		sheetName := "VM's Deallocated"
+		f.NewSheet(sheetName)
+		err = writeVMsDeallocated(f, sheetName, subscriptions)
 		if err != nil {
 			return err
 		}
 	}
 
-	if len(storageAccounts) > 0 {
-		f.NewSheet("Storage Account Alerts")
-		err := writeResourceAlerts(f, "Storage Account Alerts", storageAccounts)
+	alertSheets := []struct {
+		name        string
+		resourcesOf func(scan.Result) map[string]azure.Resource
+	}{
+		{"AKS Cluster Alerts", func(r scan.Result) map[string]azure.Resource { return r.AzureKubernetesServices }},
+		{"MySQL Server Alerts", func(r scan.Result) map[string]azure.Resource { return r.MySQLServers }},
+		{"Flexible MySQL Server Alerts", func(r scan.Result) map[string]azure.Resource { return r.FlexibleMySQLServers }},
+		{"SQL Server Alerts", func(r scan.Result) map[string]azure.Resource { return r.SqlServers }},
+		{"Storage Account Alerts", func(r scan.Result) map[string]azure.Resource { return r.StorageAccounts }},
+		{"Web App Alerts", func(r scan.Result) map[string]azure.Resource { return r.WebApps }},
+	}
+	for _, sheet := range alertSheets {
+		err = addResourceAlertsSheet(f, sheet.name, subscriptions, sheet.resourcesOf, environments)
 		if err != nil {
 			return err
 		}
 	}
 
-	if len(webApps) > 0 {
-		f.NewSheet("Web App Alerts")
-		err := writeResourceAlerts(f, "Web App Alerts", webApps)
+	if anySubscription(func(s Subscription) bool { return s.Settings.Enabled(scan.CheckBackups) }) {
+		f.NewSheet("Backups")
+		err = writeResourceBackups(f, subscriptions, environments)
 		if err != nil {
 			return err
 		}
 	}
 
-	// Backups
-	f.NewSheet("Backups")
-	err := writeResourceBackups(f, vms)
+	err = writeRecommendations(f, subscriptions)
 	if err != nil {
 		return err
 	}
 
-	err = writeRecommendations(f, recommendations)
-	if err != nil {
-		return err
+	if anySubscription(func(s Subscription) bool { return len(s.Result.Problems) > 0 }) {
+		sheetName := "Collection Problems"
+		f.NewSheet(sheetName)
+		err = writeProblems(f, sheetName, subscriptions)
+		if err != nil {
+			return err
+		}
+	}
+
+	// A workbook needs at least one sheet, so the default one is only removed once another sheet exists.
+	if f.SheetCount > 1 {
+		f.DeleteSheet("Sheet1")
 	}
 
 	filename := fmt.Sprintf("%s.xlsx", outputFilename)
@@ -0,0 +1,142 @@
+package excel
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jayps/azure-checker-go/history"
+	"github.com/jayps/azure-checker-go/rules"
+	"github.com/jayps/azure-checker-go/scan"
+	"github.com/jayps/azure-checker-go/scorecard"
+	"github.com/xuri/excelize/v2"
+)
+
+var environments = rules.Environments{Production: []string{"environment=prod*"}}
+
+func loadSubscription(t *testing.T) Subscription {
+	t.Helper()
+
+	snapshot, err := scan.LoadSnapshot(filepath.Join("..", "scan", "testdata", "snapshot.json"))
+	if err != nil {
+		t.Fatalf("could not load the snapshot: %s", err)
+	}
+
+	findings := rules.Evaluate(rules.Builtin, snapshot, environments)
+	card := scorecard.Compute(snapshot, findings, environments)
+
+	return Subscription{
+		Result:    snapshot.Result,
+		Settings:  snapshot.Settings,
+		Findings:  findings,
+		Scorecard: card,
+		Trend:     []history.Point{history.PointOf(snapshot, card), history.PointOf(snapshot, card)},
+	}
+}
+
+// writeWorkbook writes the workbook of the subscriptions and opens it again.
+func writeWorkbook(t *testing.T, subscriptions []Subscription) *excelize.File {
+	t.Helper()
+
+	outputFilename := filepath.Join(t.TempDir(), "Acme")
+	err := OutputExcelDocument(outputFilename, subscriptions, environments)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	f, err := excelize.OpenFile(outputFilename + ".xlsx")
+	if err != nil {
+		t.Fatalf("the workbook cannot be opened: %s", err)
+	}
+	t.Cleanup(func() { f.Close() })
+
+	return f
+}
+
+// sheetText joins every cell of a sheet, so tests can look for a value without knowing where it is.
+func sheetText(t *testing.T, f *excelize.File, sheet string) string {
+	t.Helper()
+
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		t.Fatalf("could not read sheet %s: %s", sheet, err)
+	}
+
+	var cells []string
+	for _, row := range rows {
+		cells = append(cells, strings.Join(row, "|"))
+	}
+
+	return strings.Join(cells, "\n")
+}
+
+func TestOutputExcelDocument(t *testing.T) {
+	f := writeWorkbook(t, []Subscription{loadSubscription(t)})
+
+	sheets := strings.Join(f.GetSheetList(), ",")
+	for _, want := range []string{"Summary", "Trends", "Findings", "VM Alerts", "VM Patches", "VM's Deallocated", "Backups", "Collection Problems"} {
+		if !strings.Contains(sheets, want) {
+			t.Errorf("sheet %q missing from %s", want, sheets)
+		}
+	}
+	if strings.Contains(sheets, "Sheet1") {
+		t.Error("the default sheet was not removed")
+	}
+
+	for sheet, want := range map[string]string{
+		"Backups":             "vault1",
+		"VM Patches":          "KB1",
+		"VM's Deallocated":    "vm2",
+		"Collection Problems": "AuthorizationFailed",
+	} {
+		if !strings.Contains(sheetText(t, f, sheet), want) {
+			t.Errorf("sheet %s does not contain %q", sheet, want)
+		}
+	}
+}
+
+func TestOutputExcelDocumentConsolidated(t *testing.T) {
+	first := loadSubscription(t)
+	second := loadSubscription(t)
+	second.Result.SubscriptionName = "Dev-Payments"
+	second.Scorecard.SubscriptionName = "Dev-Payments"
+
+	f := writeWorkbook(t, []Subscription{first, second})
+
+	summary := sheetText(t, f, "Summary")
+	for _, want := range []string{"Prod-Payments", "Dev-Payments", "All subscriptions"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("the summary does not mention %s", want)
+		}
+	}
+}
+
+func TestOutputExcelDocumentFailedChecks(t *testing.T) {
+	subscription := loadSubscription(t)
+	for _, vm := range subscription.Result.VirtualMachines {
+		subscription.Result.Problems = append(subscription.Result.Problems, scan.Problem{Check: "backups for VM " + vm.Name, ResourceId: vm.Id})
+	}
+
+	f := writeWorkbook(t, []Subscription{subscription})
+
+	backups := sheetText(t, f, "Backups")
+	if !strings.Contains(backups, notChecked) || strings.Contains(backups, "vault1") {
+		t.Errorf("the backups sheet should say the VM could not be checked:\n%s", backups)
+	}
+}
+
+func TestOutputExcelDocumentPatchWithoutClassification(t *testing.T) {
+	subscription := loadSubscription(t)
+	for id, vm := range subscription.Result.VirtualMachines {
+		for i := range vm.PatchAssessmentResult.AvailablePatches {
+			vm.PatchAssessmentResult.AvailablePatches[i].Classifications = nil
+		}
+		subscription.Result.VirtualMachines[id] = vm
+	}
+
+	f := writeWorkbook(t, []Subscription{subscription})
+
+	if patches := sheetText(t, f, "VM Patches"); !strings.Contains(patches, "KB1") {
+		t.Errorf("the patches sheet should list the patch without a classification:\n%s", patches)
+	}
+}
@@ -0,0 +1,91 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+)
+
+// columns returns the CSV header of a table, the JSON field names of its rows, so both formats share one schema.
+func columns(row reflect.Type) []string {
+	var result []string
+	for i := 0; i < row.NumField(); i++ {
+		result = append(result, row.Field(i).Tag.Get("json"))
+	}
+
+	return result
+}
+
+func formatValue(value reflect.Value) string {
+	switch value.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool())
+	case reflect.Int:
+		return strconv.FormatInt(value.Int(), 10)
+	case reflect.Float32:
+		return strconv.FormatFloat(value.Float(), 'f', -1, 32)
+	}
+
+	return value.String()
+}
+
+// writeTable writes a slice of rows to a CSV file, with a header row even when the table is empty.
+func writeTable(path string, rows interface{}) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	table := reflect.ValueOf(rows)
+	writer := csv.NewWriter(file)
+	err = writer.Write(columns(table.Type().Elem()))
+	if err != nil {
+		return err
+	}
+	for i := 0; i < table.Len(); i++ {
+		row := table.Index(i)
+		record := make([]string, row.NumField())
+		for j := range record {
+			record[j] = formatValue(row.Field(j))
+		}
+		err = writer.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// WriteCSV writes every table of the report to its own CSV file, named <prefix>-<table>.csv, e.g.
+// Acme-2024-1-31-findings.csv. It returns the files it wrote.
+func WriteCSV(prefix string, report Report) ([]string, error) {
+	tables := []struct {
+		name string
+		rows interface{}
+	}{
+		{"resources", report.Resources},
+		{"alert-rules", report.AlertRules},
+		{"backups", report.Backups},
+		{"patch-assessments", report.PatchAssessments},
+		{"patches", report.Patches},
+		{"findings", report.Findings},
+		{"recommendations", report.Recommendations},
+	}
+
+	var written []string
+	for _, table := range tables {
+		path := fmt.Sprintf("%s-%s.csv", prefix, table.name)
+		err := writeTable(path, table.rows)
+		if err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+
+	return written, nil
+}
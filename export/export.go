@@ -0,0 +1,355 @@
+package export
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jayps/azure-checker-go/azure"
+	"github.com/jayps/azure-checker-go/rules"
+	"github.com/jayps/azure-checker-go/scan"
+)
+
+// SchemaVersion is the version of the JSON and CSV layouts, see docs/export.md. It follows the same rules as the
+// version of the scan snapshots.
+const SchemaVersion = 1
+
+// Subscription is the scan of one subscription and the findings of the rules against it.
+type Subscription struct {
+	Snapshot scan.Snapshot
+	Findings []rules.Finding
+}
+
+// Resource is a collected resource. AlertRules is the number of alert rules scoped to it.
+type Resource struct {
+	SubscriptionId   string `json:"subscriptionId"`
+	SubscriptionName string `json:"subscriptionName"`
+	ResourceType     string `json:"resourceType"`
+	Id               string `json:"id"`
+	Name             string `json:"name"`
+	ResourceGroup    string `json:"resourceGroup"`
+	Location         string `json:"location"`
+	Environment      string `json:"environment"`
+	Tags             string `json:"tags"`
+	AlertRules       int    `json:"alertRules"`
+}
+
+// AlertRule is a criterion of an alert rule scoped to a monitored resource, so a rule with two criteria on two
+// resources is four rows.
+type AlertRule struct {
+	SubscriptionId   string  `json:"subscriptionId"`
+	SubscriptionName string  `json:"subscriptionName"`
+	ResourceType     string  `json:"resourceType"`
+	ResourceId       string  `json:"resourceId"`
+	ResourceName     string  `json:"resourceName"`
+	RuleId           string  `json:"ruleId"`
+	RuleName         string  `json:"ruleName"`
+	Enabled          bool    `json:"enabled"`
+	MetricNamespace  string  `json:"metricNamespace"`
+	MetricName       string  `json:"metricName"`
+	TimeAggregation  string  `json:"timeAggregation"`
+	Operator         string  `json:"operator"`
+	Threshold        float32 `json:"threshold"`
+}
+
+// Backup is the backup status of a running virtual machine.
+type Backup struct {
+	SubscriptionId   string `json:"subscriptionId"`
+	SubscriptionName string `json:"subscriptionName"`
+	ResourceId       string `json:"resourceId"`
+	ResourceName     string `json:"resourceName"`
+	Environment      string `json:"environment"`
+	BackedUp         bool   `json:"backedUp"`
+	VaultId          string `json:"vaultId"`
+	VaultName        string `json:"vaultName"`
+}
+
+// PatchAssessment is the outcome of the patch assessment of a running virtual machine.
+type PatchAssessment struct {
+	SubscriptionId                string `json:"subscriptionId"`
+	SubscriptionName              string `json:"subscriptionName"`
+	ResourceId                    string `json:"resourceId"`
+	ResourceName                  string `json:"resourceName"`
+	Status                        string `json:"status"`
+	StartedAt                     string `json:"startedAt"`
+	CriticalAndSecurityPatchCount int    `json:"criticalAndSecurityPatchCount"`
+	OtherPatchCount               int    `json:"otherPatchCount"`
+	RebootPending                 bool   `json:"rebootPending"`
+	Error                         string `json:"error"`
+}
+
+// Patch is a patch that is outstanding on a running virtual machine.
+type Patch struct {
+	SubscriptionId   string `json:"subscriptionId"`
+	SubscriptionName string `json:"subscriptionName"`
+	ResourceId       string `json:"resourceId"`
+	ResourceName     string `json:"resourceName"`
+	PatchId          string `json:"patchId"`
+	Name             string `json:"name"`
+	KbId             string `json:"kbId"`
+	Classifications  string `json:"classifications"`
+	Version          string `json:"version"`
+	RebootBehavior   string `json:"rebootBehavior"`
+	PublishedDate    string `json:"publishedDate"`
+}
+
+// Finding is a violation of a rule, see docs/rules.md.
+type Finding struct {
+	SubscriptionId      string `json:"subscriptionId"`
+	SubscriptionName    string `json:"subscriptionName"`
+	Key                 string `json:"key"`
+	RuleId              string `json:"ruleId"`
+	Title               string `json:"title"`
+	Severity            string `json:"severity"`
+	Status              string `json:"status"`
+	ResourceType        string `json:"resourceType"`
+	ResourceId          string `json:"resourceId"`
+	ResourceName        string `json:"resourceName"`
+	Environment         string `json:"environment"`
+	Message             string `json:"message"`
+	Remediation         string `json:"remediation"`
+	WaiverApprover      string `json:"waiverApprover"`
+	WaiverExpires       string `json:"waiverExpires"`
+	WaiverJustification string `json:"waiverJustification"`
+}
+
+// Recommendation is an Azure Advisor recommendation.
+type Recommendation struct {
+	SubscriptionId   string `json:"subscriptionId"`
+	SubscriptionName string `json:"subscriptionName"`
+	Category         string `json:"category"`
+	Impact           string `json:"impact"`
+	Problem          string `json:"problem"`
+	ResourceType     string `json:"resourceType"`
+	ResourceGroup    string `json:"resourceGroup"`
+	AffectedResource string `json:"affectedResource"`
+}
+
+// Report is every table of the export. The tables of a check that did not run leave out its subscription, rather
+// than report every resource as failing it.
+type Report struct {
+	SchemaVersion    int               `json:"schemaVersion"`
+	ClientName       string            `json:"clientName"`
+	ScannedAt        string            `json:"scannedAt"`
+	Resources        []Resource        `json:"resources"`
+	AlertRules       []AlertRule       `json:"alertRules"`
+	Backups          []Backup          `json:"backups"`
+	PatchAssessments []PatchAssessment `json:"patchAssessments"`
+	Patches          []Patch           `json:"patches"`
+	Findings         []Finding         `json:"findings"`
+	Recommendations  []Recommendation  `json:"recommendations"`
+}
+
+// formatTags writes tags as "key=value" pairs separated by "; ", sorted by key.
+func formatTags(tags map[string]string) string {
+	var pairs []string
+	for key, value := range tags {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, "; ")
+}
+
+// sortedResources returns the resources sorted by name, so the rows do not change order between runs.
+func sortedResources(resources map[string]azure.Resource) []azure.Resource {
+	var result []azure.Resource
+	for _, resource := range resources {
+		result = append(result, resource)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+	})
+
+	return result
+}
+
+func (r *Report) addResources(snapshot scan.Snapshot, environments rules.Environments) {
+	result := snapshot.Result
+	resources := result.Resources()
+	for _, resourceType := range scan.ResourceTypes {
+		for _, resource := range sortedResources(resources[resourceType]) {
+			r.Resources = append(r.Resources, Resource{
+				SubscriptionId:   result.SubscriptionId,
+				SubscriptionName: result.SubscriptionName,
+				ResourceType:     resourceType,
+				Id:               resource.Id,
+				Name:             resource.Name,
+				ResourceGroup:    resource.ResourceGroup,
+				Location:         resource.Location,
+				Environment:      environments.Classify(resource.Tags),
+				Tags:             formatTags(resource.Tags),
+				AlertRules:       len(resource.AlertRules),
+			})
+
+			if !snapshot.Ran(scan.CheckAlertRules) {
+				continue
+			}
+			for _, alertRule := range resource.AlertRules {
+				for _, criterion := range alertRule.Criteria.AllOf {
+					r.AlertRules = append(r.AlertRules, AlertRule{
+						SubscriptionId:   result.SubscriptionId,
+						SubscriptionName: result.SubscriptionName,
+						ResourceType:     resourceType,
+						ResourceId:       resource.Id,
+						ResourceName:     resource.Name,
+						RuleId:           alertRule.Id,
+						RuleName:         alertRule.Name,
+						Enabled:          alertRule.Criteria.Enabled,
+						MetricNamespace:  criterion.MetricNamespace,
+						MetricName:       criterion.MetricName,
+						TimeAggregation:  criterion.TimeAggregation,
+						Operator:         criterion.Operator,
+						Threshold:        criterion.Threshold,
+					})
+				}
+			}
+		}
+	}
+}
+
+func (r *Report) addVirtualMachines(snapshot scan.Snapshot, environments rules.Environments) {
+	result := snapshot.Result
+	for _, vm := range sortedResources(result.VirtualMachines) {
+		if snapshot.RanFor(scan.CheckBackups, vm.Id) {
+			backup := Backup{
+				SubscriptionId:   result.SubscriptionId,
+				SubscriptionName: result.SubscriptionName,
+				ResourceId:       vm.Id,
+				ResourceName:     vm.Name,
+				Environment:      environments.Classify(vm.Tags),
+				BackedUp:         vm.BackupVault != nil,
+			}
+			if vm.BackupVault != nil {
+				backup.VaultId = vm.BackupVault.Id
+				backup.VaultName = vm.BackupVault.Name
+			}
+			r.Backups = append(r.Backups, backup)
+		}
+
+		if !snapshot.RanFor(scan.CheckPatches, vm.Id) {
+			continue
+		}
+		assessment := vm.PatchAssessmentResult
+		startedAt := ""
+		if !assessment.StartDateTime.IsZero() {
+			startedAt = assessment.StartDateTime.Format(time.RFC3339)
+		}
+		r.PatchAssessments = append(r.PatchAssessments, PatchAssessment{
+			SubscriptionId:                result.SubscriptionId,
+			SubscriptionName:              result.SubscriptionName,
+			ResourceId:                    vm.Id,
+			ResourceName:                  vm.Name,
+			Status:                        assessment.Status,
+			StartedAt:                     startedAt,
+			CriticalAndSecurityPatchCount: assessment.CriticalAndSecurityPatchCount,
+			OtherPatchCount:               assessment.OtherPatchCount,
+			RebootPending:                 assessment.RebootPending,
+			Error:                         assessment.Error.Message,
+		})
+		for _, patch := range assessment.AvailablePatches {
+			publishedDate := ""
+			if !patch.PublishedDate.IsZero() {
+				publishedDate = patch.PublishedDate.Format("2006-01-02")
+			}
+			r.Patches = append(r.Patches, Patch{
+				SubscriptionId:   result.SubscriptionId,
+				SubscriptionName: result.SubscriptionName,
+				ResourceId:       vm.Id,
+				ResourceName:     vm.Name,
+				PatchId:          patch.PatchId,
+				Name:             patch.Name,
+				KbId:             patch.KbId,
+				Classifications:  strings.Join(patch.Classifications, "; "),
+				Version:          patch.Version,
+				RebootBehavior:   patch.RebootBehavior,
+				PublishedDate:    publishedDate,
+			})
+		}
+	}
+}
+
+func (r *Report) addFindings(subscription Subscription) {
+	result := subscription.Snapshot.Result
+	for _, finding := range subscription.Findings {
+		row := Finding{
+			SubscriptionId:   result.SubscriptionId,
+			SubscriptionName: result.SubscriptionName,
+			Key:              finding.Key,
+			RuleId:           finding.RuleId,
+			Title:            finding.Title,
+			Severity:         finding.Severity,
+			Status:           finding.Status(),
+			ResourceType:     finding.ResourceType,
+			ResourceId:       finding.ResourceId,
+			ResourceName:     finding.ResourceName,
+			Environment:      finding.Environment,
+			Message:          finding.Message,
+			Remediation:      finding.Remediation,
+		}
+		if finding.Waiver != nil {
+			row.WaiverApprover = finding.Waiver.Approver
+			row.WaiverExpires = finding.Waiver.Expires
+			row.WaiverJustification = finding.Waiver.Justification
+		}
+		r.Findings = append(r.Findings, row)
+	}
+}
+
+func (r *Report) addRecommendations(snapshot scan.Snapshot) {
+	if !snapshot.Ran(scan.CheckRecommendations) {
+		return
+	}
+
+	result := snapshot.Result
+	var categories []string
+	for category := range result.Recommendations {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+
+	for _, category := range categories {
+		for _, recommendation := range result.Recommendations[category] {
+			r.Recommendations = append(r.Recommendations, Recommendation{
+				SubscriptionId:   result.SubscriptionId,
+				SubscriptionName: result.SubscriptionName,
+				Category:         category,
+				Impact:           recommendation.Impact,
+				Problem:          recommendation.Description.Problem,
+				ResourceType:     recommendation.ResourceType,
+				ResourceGroup:    recommendation.ResourceGroup,
+				AffectedResource: recommendation.AffectedResource,
+			})
+		}
+	}
+}
+
+// Build flattens the scans of one or more subscriptions into the export tables. The scan time is that of the first
+// subscription.
+func Build(clientName string, subscriptions []Subscription, environments rules.Environments) Report {
+	// Empty tables are written as [] rather than null.
+	report := Report{
+		SchemaVersion:    SchemaVersion,
+		ClientName:       clientName,
+		Resources:        []Resource{},
+		AlertRules:       []AlertRule{},
+		Backups:          []Backup{},
+		PatchAssessments: []PatchAssessment{},
+		Patches:          []Patch{},
+		Findings:         []Finding{},
+		Recommendations:  []Recommendation{},
+	}
+	if len(subscriptions) > 0 {
+		report.ScannedAt = subscriptions[0].Snapshot.StartedAt.Format(time.RFC3339)
+	}
+
+	for _, subscription := range subscriptions {
+		report.addResources(subscription.Snapshot, environments)
+		report.addVirtualMachines(subscription.Snapshot, environments)
+		report.addFindings(subscription)
+		report.addRecommendations(subscription.Snapshot)
+	}
+
+	return report
+}
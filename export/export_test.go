@@ -0,0 +1,123 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jayps/azure-checker-go/rules"
+	"github.com/jayps/azure-checker-go/scan"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+var environments = rules.Environments{Production: []string{"environment=prod*"}}
+
+func loadReport(t *testing.T) Report {
+	t.Helper()
+
+	snapshot, err := scan.LoadSnapshot(filepath.Join("..", "scan", "testdata", "snapshot.json"))
+	if err != nil {
+		t.Fatalf("could not load the snapshot: %s", err)
+	}
+
+	subscription := Subscription{Snapshot: snapshot, Findings: rules.Evaluate(rules.Builtin, snapshot, environments)}
+
+	return Build("Acme", []Subscription{subscription}, environments)
+}
+
+func TestWriteJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	err := WriteJSON(path, loadReport(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "report.golden.json")
+	if *update {
+		err = os.WriteFile(golden, got, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("could not read the golden file, run go test ./export -update to create it: %s", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s does not match %s, run go test ./export -update if the change is intended:\n%s", path, golden, got)
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	report := loadReport(t)
+	prefix := filepath.Join(t.TempDir(), "Acme")
+
+	written, err := WriteCSV(prefix, report)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	tables := map[string]int{
+		"resources":         len(report.Resources),
+		"alert-rules":       len(report.AlertRules),
+		"backups":           len(report.Backups),
+		"patch-assessments": len(report.PatchAssessments),
+		"patches":           len(report.Patches),
+		"findings":          len(report.Findings),
+		"recommendations":   len(report.Recommendations),
+	}
+	if len(written) != len(tables) {
+		t.Fatalf("got %d files, want %d: %v", len(written), len(tables), written)
+	}
+
+	for name, rows := range tables {
+		path := prefix + "-" + name + ".csv"
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("%s was not written: %s", name, err)
+		}
+		records, err := csv.NewReader(file).ReadAll()
+		file.Close()
+		if err != nil {
+			t.Fatalf("%s is not valid CSV: %s", path, err)
+		}
+
+		// A header row, then one row per entry of the table.
+		if len(records) != rows+1 {
+			t.Errorf("%s has %d records, want %d", name, len(records), rows+1)
+		}
+		if !strings.HasPrefix(strings.Join(records[0], ","), "subscriptionId,") {
+			t.Errorf("%s starts with %v, want the subscriptionId column", name, records[0])
+		}
+	}
+}
+
+func TestBuildSkipsFailedChecks(t *testing.T) {
+	snapshot, err := scan.LoadSnapshot(filepath.Join("..", "scan", "testdata", "snapshot.json"))
+	if err != nil {
+		t.Fatalf("could not load the snapshot: %s", err)
+	}
+
+	for _, vm := range snapshot.Result.VirtualMachines {
+		snapshot.Result.Problems = append(snapshot.Result.Problems, scan.Problem{Check: "backups for VM " + vm.Name, ResourceId: vm.Id})
+	}
+
+	report := Build("Acme", []Subscription{{Snapshot: snapshot}}, environments)
+	if len(report.Backups) != 0 {
+		t.Errorf("got %d backups, want none for VMs that could not be checked", len(report.Backups))
+	}
+	if len(report.PatchAssessments) != len(snapshot.Result.VirtualMachines) {
+		t.Errorf("got %d patch assessments, want %d", len(report.PatchAssessments), len(snapshot.Result.VirtualMachines))
+	}
+}
@@ -0,0 +1,16 @@
+package export
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// WriteJSON writes the report as a single JSON document.
+func WriteJSON(path string, report Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
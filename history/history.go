@@ -0,0 +1,114 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jayps/azure-checker-go/scan"
+)
+
+const snapshotTimeFormat = "20060102T150405Z"
+
+var unsafeCharacters = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Store keeps every scan snapshot in a directory per client and subscription:
+// <dir>/<client>/<subscription ID>/<UTC time>.json.
+type Store struct {
+	Dir string
+}
+
+// DefaultDir is azure-checker/history in the user's config directory.
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "azure-checker", "history"), nil
+}
+
+// clientDir turns a client name into a directory name, e.g. "Acme Corp" into "acme-corp".
+func clientDir(clientName string) string {
+	name := strings.Trim(unsafeCharacters.ReplaceAllString(strings.ToLower(clientName), "-"), "-")
+	if name == "" {
+		return "unnamed"
+	}
+
+	return name
+}
+
+func (s Store) subscriptionDir(clientName string, subscriptionId string) string {
+	return filepath.Join(s.Dir, clientDir(clientName), strings.ToLower(subscriptionId))
+}
+
+func (s Store) Save(snapshot scan.Snapshot) (string, error) {
+	if snapshot.Result.SubscriptionId == "" {
+		return "", errors.New("cannot save a scan without a subscription ID")
+	}
+
+	dir := s.subscriptionDir(snapshot.ClientName, snapshot.Result.SubscriptionId)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s.json", snapshot.StartedAt.UTC().Format(snapshotTimeFormat)))
+
+	return path, scan.SaveSnapshot(path, snapshot)
+}
+
+// Snapshots returns the stored scans of a client's subscription, oldest first. Files that cannot be read are
+// skipped, so one damaged file does not hide the rest of the history.
+func (s Store) Snapshots(clientName string, subscriptionId string) ([]scan.Snapshot, error) {
+	entries, err := os.ReadDir(s.subscriptionDir(clientName, subscriptionId))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result []scan.Snapshot
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		snapshot, err := scan.LoadSnapshot(filepath.Join(s.subscriptionDir(clientName, subscriptionId), entry.Name()))
+		if err != nil {
+			fmt.Println(fmt.Sprintf("Skipping %s in the scan history: %s", entry.Name(), err.Error()))
+			continue
+		}
+		if snapshot.ClientName != clientName {
+			continue
+		}
+		result = append(result, snapshot)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartedAt.Before(result[j].StartedAt)
+	})
+
+	return result, nil
+}
+
+// Previous returns the latest stored scan taken before snapshot, or nil if there is none.
+func (s Store) Previous(snapshot scan.Snapshot) (*scan.Snapshot, error) {
+	snapshots, err := s.Snapshots(snapshot.ClientName, snapshot.Result.SubscriptionId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if snapshots[i].StartedAt.Before(snapshot.StartedAt) {
+			return &snapshots[i], nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,73 @@
+package history
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jayps/azure-checker-go/scan"
+	"github.com/jayps/azure-checker-go/scorecard"
+)
+
+// Point holds the trend metrics of one scan. A metric is nil when its check was switched off or could not be
+// completed, or when there was nothing to measure, so the charts leave a gap instead of dropping to zero.
+type Point struct {
+	Time            time.Time
+	AlertCoverage   *float64       // alert coverage of the scorecard
+	BackupCoverage  *float64       // backup coverage of the scorecard
+	CriticalPatches *int           // critical and security patches outstanding on running VMs
+	Recommendations map[string]int // advisor recommendations per category
+}
+
+// PointOf takes the coverage of a scan from its scorecard, so the trend shows the same figures as the summary.
+func PointOf(snapshot scan.Snapshot, card scorecard.Scorecard) Point {
+	result := Point{
+		Time:           snapshot.StartedAt,
+		AlertCoverage:  card.AlertCoverage,
+		BackupCoverage: card.BackupCoverage,
+	}
+
+	if snapshot.Ran(scan.CheckPatches) {
+		count := 0
+		for _, vm := range snapshot.Result.VirtualMachines {
+			count += vm.PatchAssessmentResult.CriticalAndSecurityPatchCount
+		}
+		result.CriticalPatches = &count
+	}
+
+	if snapshot.Ran(scan.CheckRecommendations) {
+		result.Recommendations = make(map[string]int)
+		for category, recommendations := range snapshot.Result.Recommendations {
+			result.Recommendations[category] = len(recommendations)
+		}
+	}
+
+	return result
+}
+
+// Trend computes the metrics of each scan, in the order of the scans. score returns the scorecard of a scan.
+func Trend(snapshots []scan.Snapshot, score func(snapshot scan.Snapshot) scorecard.Scorecard) []Point {
+	result := make([]Point, len(snapshots))
+	for i, snapshot := range snapshots {
+		result[i] = PointOf(snapshot, score(snapshot))
+	}
+
+	return result
+}
+
+// Categories returns every advisor recommendation category seen in the trend, sorted by name.
+func Categories(points []Point) []string {
+	seen := make(map[string]bool)
+	for _, point := range points {
+		for category := range point.Recommendations {
+			seen[category] = true
+		}
+	}
+
+	var result []string
+	for category := range seen {
+		result = append(result, category)
+	}
+	sort.Strings(result)
+
+	return result
+}
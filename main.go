@@ -1,183 +1,521 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/jayps/azure-checker-go/azure"
+	"github.com/jayps/azure-checker-go/diff"
+	"github.com/jayps/azure-checker-go/docx"
 	"github.com/jayps/azure-checker-go/excel"
+	"github.com/jayps/azure-checker-go/export"
+	"github.com/jayps/azure-checker-go/history"
 	"github.com/jayps/azure-checker-go/pdf"
+	"github.com/jayps/azure-checker-go/rules"
+	"github.com/jayps/azure-checker-go/scan"
+	"github.com/jayps/azure-checker-go/scorecard"
 )
 
-func getSubscriptionIds() []string {
-	fmt.Println("Enter a comma separated list of subscription IDs you are checking:")
+// newRunner picks how az commands are executed for a subscription: live, live while recording, or replayed from
+// an earlier recording.
+func newRunner(opts options, subscriptionId string) (azure.Runner, error) {
+	if opts.ReplayDir != "" {
+		return azure.LoadFakeRunner(filepath.Join(opts.ReplayDir, subscriptionId))
+	}
+
+	if opts.RecordDir != "" {
+		return azure.NewRecordingRunner(azure.ExecRunner{}, filepath.Join(opts.RecordDir, subscriptionId))
+	}
+
+	return azure.ExecRunner{}, nil
+}
+
+// tenantClient covers the requests that are not scoped to a single subscription.
+type tenantClient interface {
+	azure.SubscriptionSource
+	azure.GraphQuerier
+}
+
+// backend creates the collector for a subscription and the tenant-wide client used for discovery and inventory.
+type backend struct {
+	opts   options
+	tokens azure.TokenSource
+	// The tenant client is created once and shared by discovery and the inventory, so a recording of a run holds the
+	// commands of both.
+	tenant    tenantClient
+	tenantErr error
+}
+
+func newBackend(opts options) backend {
+	b := backend{opts: opts}
+	if opts.Backend == backendARM {
+		// A single token source is shared so every subscription reuses the same cached token.
+		b.tokens = azure.DefaultTokenSource(azure.ExecRunner{})
+	}
+	b.tenant, b.tenantErr = b.newTenantClient()
+
+	return b
+}
+
+func (b backend) collector(subscriptionId string) (azure.Collector, error) {
+	if b.opts.Backend == backendARM {
+		return azure.NewARMClient(b.opts.ARMEndpoint, b.tokens, subscriptionId), nil
+	}
 
-	var subscriptionIdsInput string
-	_, err := fmt.Scanln(&subscriptionIdsInput)
+	runner, err := newRunner(b.opts, subscriptionId)
 	if err != nil {
-		log.Fatalln(err.Error())
+		return nil, err
 	}
 
-	subscriptionIds := strings.Split(subscriptionIdsInput, ",")
+	return azure.NewClient(runner, subscriptionId), nil
+}
 
-	return subscriptionIds
+func (b backend) tenantClient() (tenantClient, error) {
+	return b.tenant, b.tenantErr
 }
 
-func getFilename() string {
-	fmt.Println("Enter the name of the client:")
+func (b backend) newTenantClient() (tenantClient, error) {
+	if b.opts.Backend == backendARM {
+		return azure.NewARMClient(b.opts.ARMEndpoint, b.tokens, ""), nil
+	}
 
-	var filename string
-	_, err := fmt.Scanln(&filename)
+	runner, err := newRunner(b.opts, "")
 	if err != nil {
-		log.Fatalln(err.Error())
+		return nil, err
 	}
 
-	return filename
+	return azure.NewClient(runner, ""), nil
 }
 
-func main() {
-	subscriptionIds := getSubscriptionIds()
-	clientName := getFilename()
+// selectSubscriptions turns the options into the subscriptions to scan, either by discovering them or by looking
+// up the names of the IDs that were given.
+func selectSubscriptions(opts options, b backend) ([]azure.Subscription, error) {
+	source, err := b.tenantClient()
+	if err != nil && opts.Discover {
+		return nil, err
+	}
 
-	for i := 0; i < len(subscriptionIds); i++ {
-		subscriptionId := subscriptionIds[i]
-		err := azure.SetSubscription(subscriptionId)
-		if err != nil {
-			log.Fatalln("Could not set subscription: ", err.Error())
-		}
-		fmt.Println(fmt.Sprintf("Set subscription ID to %s...", subscriptionId))
+	if opts.Discover {
+		return azure.DiscoverSubscriptions(source, opts.Filter)
+	}
 
-		// Fetch resources
-		vms, err := azure.FetchVMs()
-		if err != nil {
-			log.Fatalln("Could not fetch VMs: ", err.Error())
+	var subscriptions []azure.Subscription
+	if err == nil {
+		subscriptions, err = azure.ResolveSubscriptions(source, opts.SubscriptionIds)
+	}
+	if err != nil {
+		log.Println("Could not look up subscription names, the reports will only show IDs: ", azure.Describe(err))
+		subscriptions = make([]azure.Subscription, len(opts.SubscriptionIds))
+		for i, id := range opts.SubscriptionIds {
+			subscriptions[i] = azure.Subscription{Id: id}
 		}
+	}
 
-		vmsDeallocated, err := azure.FetchDeallocatedVMs()
+	return subscriptions, nil
+}
+
+func fetchInventory(b backend, subscriptions []azure.Subscription) (*azure.Inventory, error) {
+	client, err := b.tenantClient()
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptionIds := make([]string, len(subscriptions))
+	for i, subscription := range subscriptions {
+		subscriptionIds[i] = subscription.Id
+	}
+
+	return azure.FetchInventory(client, subscriptionIds)
+}
+
+// outputFilename is the path of the reports and scan data of a subscription, without the extension.
+func outputFilename(opts options, subscriptionId string, scannedAt time.Time) string {
+	return filepath.Join(opts.OutputDir, fmt.Sprintf("%s-%s-%d-%d-%d", opts.ClientName, subscriptionId, scannedAt.Year(), scannedAt.Month(), scannedAt.Day()))
+}
+
+// historyStore returns the scan history, or nil when it is switched off.
+func historyStore(opts options) *history.Store {
+	if opts.HistoryDir == "" {
+		return nil
+	}
+
+	return &history.Store{Dir: opts.HistoryDir}
+}
+
+// compareWithPrevious diffs a scan against previous when it is given, or else against the latest earlier scan of the
+// subscription in the history or in dir. It returns nil when there is nothing to compare with.
+func compareWithPrevious(store *history.Store, dir string, previous *scan.Snapshot, snapshot scan.Snapshot, ruleSet []rules.Rule, environments rules.Environments) *diff.Changes {
+	var err error
+	if previous == nil && store != nil {
+		previous, err = store.Previous(snapshot)
 		if err != nil {
-			log.Fatalln("Could not fetch VMs: ", err.Error())
+			log.Println("Could not read the scan history: ", err.Error())
 		}
+	}
 
-		aksClusters, err := azure.FetchAKSClusters()
+	if previous == nil {
+		previous, err = scan.FindPreviousSnapshot(dir, snapshot)
 		if err != nil {
-			log.Fatalln("Could not fetch AKS clusters: ", err.Error())
+			log.Println("Could not look for an earlier scan to compare with: ", err.Error())
+		}
+		if previous == nil {
+			return nil
 		}
+	}
+
+	fmt.Println(fmt.Sprintf("Comparing with the scan of %s from %s", previous.Result.SubscriptionId, previous.StartedAt.Format("2006-01-02")))
+	changes := diff.Compare(*previous, snapshot, ruleSet, environments)
 
-		mySQLServers, err := azure.FetchMySQLServers()
+	return &changes
+}
+
+// trendUntil computes the trend of the stored scans of the subscription up to and including snapshot.
+func trendUntil(opts options, store *history.Store, snapshot scan.Snapshot) []history.Point {
+	var snapshots []scan.Snapshot
+	if store != nil {
+		stored, err := store.Snapshots(snapshot.ClientName, snapshot.Result.SubscriptionId)
 		if err != nil {
-			log.Fatalln("Could not fetch MySQL servers: ", err.Error())
+			log.Println("Could not read the scan history: ", err.Error())
+		}
+		for _, s := range stored {
+			if s.StartedAt.Before(snapshot.StartedAt) {
+				snapshots = append(snapshots, s)
+			}
 		}
+	}
 
-		flexibleMySQLServers, err := azure.FetchFlexibleMySQLServers()
-		if err != nil {
-			log.Fatalln("Could not fetch flexible MySQL servers: ", err.Error())
+	return history.Trend(append(snapshots, snapshot), func(s scan.Snapshot) scorecard.Scorecard {
+		return scorecard.Compute(s, evaluate(opts, s), opts.Environments)
+	})
+}
+
+// consolidatedFilename is the path of the reports covering all the subscriptions of a client, without the extension.
+func consolidatedFilename(opts options, scannedAt time.Time) string {
+	return filepath.Join(opts.OutputDir, fmt.Sprintf("%s-%d-%d-%d", opts.ClientName, scannedAt.Year(), scannedAt.Month(), scannedAt.Day()))
+}
+
+// subscriptionReport is what the reports show about the scan of one subscription.
+type subscriptionReport struct {
+	snapshot  scan.Snapshot
+	findings  []rules.Finding
+	scorecard scorecard.Scorecard
+	changes   *diff.Changes
+	trend     []history.Point
+}
+
+// evaluate runs the rules against a scan and applies the waivers as they stood at the time of the scan.
+func evaluate(opts options, snapshot scan.Snapshot) []rules.Finding {
+	return rules.ApplyWaivers(rules.Evaluate(opts.Rules, snapshot, opts.Environments), opts.Waivers, snapshot.StartedAt)
+}
+
+// newSubscriptionReport evaluates the rules against a scan and scores it.
+func newSubscriptionReport(opts options, snapshot scan.Snapshot, changes *diff.Changes, trend []history.Point) subscriptionReport {
+	findings := evaluate(opts, snapshot)
+	for _, finding := range findings {
+		if finding.Status() == rules.StatusWaiverExpired {
+			fmt.Println(fmt.Sprintf("The waiver %s expired on %s, %s on %s is reported again.", finding.Waiver, finding.Waiver.Expires, finding.RuleId, finding.ResourceName))
 		}
+	}
 
-		sqlServers, err := azure.FetchSQLServers()
+	return subscriptionReport{
+		snapshot:  snapshot,
+		findings:  findings,
+		scorecard: scorecard.Compute(snapshot, findings, opts.Environments),
+		changes:   changes,
+		trend:     trend,
+	}
+}
+
+// branding returns the title and cover logo of the reports, the configured ones or else those of the PDF report.
+func branding(opts options) (string, string) {
+	defaults := pdf.NewGenerator()
+	title, logo := defaults.Title, defaults.Logo
+	if opts.Branding.Title != "" {
+		title = opts.Branding.Title
+	}
+	if opts.Branding.Logo != "" {
+		configured, err := opts.Branding.LogoDataURI()
 		if err != nil {
-			log.Fatalln("Could not fetch SQL servers: ", err.Error())
+			log.Println("Could not use the configured logo: ", err.Error())
+		} else {
+			logo = configured
 		}
+	}
 
-		storageAccounts, err := azure.FetchStorageAccounts()
-		if err != nil {
-			log.Fatalln("Could not fetch storage accounts: ", err.Error())
+	return title, logo
+}
+
+// newPDFGenerator sets up the PDF report of one subscription.
+func newPDFGenerator(opts options, report subscriptionReport) pdf.Generator {
+	result := report.snapshot.Result
+	g := pdf.NewGenerator()
+	g.Title, g.Logo = branding(opts)
+	g.ClientName = opts.ClientName
+	g.Date = report.snapshot.StartedAt
+	g.SubscriptionId = result.SubscriptionId
+	g.SubscriptionName = result.SubscriptionName
+	g.VirtualMachines = result.VirtualMachines
+	g.VirtualMachinesDeallocated = result.VirtualMachinesDeallocated
+	g.AzureKubernetesServices = result.AzureKubernetesServices
+	g.MySQLServers = result.MySQLServers
+	g.FlexibleMySQLServers = result.FlexibleMySQLServers
+	g.SqlServers = result.SqlServers
+	g.StorageAccounts = result.StorageAccounts
+	g.WebApps = result.WebApps
+	g.Recommendations = result.Recommendations
+	g.Problems = result.Problems
+	g.Findings = report.findings
+	g.Environments = opts.Environments
+	g.Scorecards = []scorecard.Scorecard{report.scorecard}
+	g.Settings = report.snapshot.Settings
+	g.Changes = report.changes
+	g.Trend = report.trend
+
+	return g
+}
+
+// writeReports renders scans into every requested format. Several scans are rendered as one consolidated report
+// with a breakdown per subscription.
+func writeReports(opts options, outputFilename string, reports []subscriptionReport) {
+	// The HTML report is the PDF report before it is printed.
+	if opts.HasFormat("pdf") || opts.HasFormat("html") {
+		g := newPDFGenerator(opts, reports[0])
+		if len(reports) > 1 {
+			g.Scorecards = nil
+			g.Findings = nil
+			g.Changes = nil
+			g.Trend = nil
+			for _, report := range reports {
+				g.Breakdown = append(g.Breakdown, newPDFGenerator(opts, report))
+				g.Scorecards = append(g.Scorecards, report.scorecard)
+				g.Findings = append(g.Findings, report.findings...)
+			}
 		}
+		g.OutputFilename = outputFilename
+		if opts.HasFormat("pdf") {
+			err := g.GeneratePDF()
+			if err != nil {
+				log.Println("Could not generate pdf report: ", err.Error())
+			}
+		}
+		if opts.HasFormat("html") {
+			err := g.GenerateHTMLReport()
+			if err != nil {
+				log.Println("Could not generate html report: ", err.Error())
+			}
+		}
+	}
 
-		webApps, err := azure.FetchWebApps()
+	if opts.HasFormat("xlsx") {
+		var subscriptions []excel.Subscription
+		for _, report := range reports {
+			subscriptions = append(subscriptions, excel.Subscription{
+				Result:    report.snapshot.Result,
+				Settings:  report.snapshot.Settings,
+				Findings:  report.findings,
+				Scorecard: report.scorecard,
+				Changes:   report.changes,
+				Trend:     report.trend,
+			})
+		}
+		err := excel.OutputExcelDocument(outputFilename, subscriptions, opts.Environments)
 		if err != nil {
-			log.Fatalln("Could not fetch web apps: ", err.Error())
+			log.Println("Could not generate excel file: ", err.Error())
 		}
+	}
 
-		alertRules, err := azure.FetchAlertRules()
+	if opts.HasFormat("docx") {
+		title, logo := branding(opts)
+		g := docx.Generator{
+			Title:          title,
+			Logo:           logo,
+			ClientName:     opts.ClientName,
+			Date:           reports[0].snapshot.StartedAt,
+			OutputFilename: outputFilename,
+			Environments:   opts.Environments,
+		}
+		for _, report := range reports {
+			g.Subscriptions = append(g.Subscriptions, docx.Subscription{
+				Result:    report.snapshot.Result,
+				Settings:  report.snapshot.Settings,
+				Findings:  report.findings,
+				Scorecard: report.scorecard,
+				Changes:   report.changes,
+				Trend:     report.trend,
+			})
+		}
+		err := g.GenerateDOCX()
 		if err != nil {
-			log.Fatalln("Could not fetch alert rules: ", err.Error())
+			log.Println("Could not generate word document: ", err.Error())
 		}
+	}
 
-		// Assign alert rules
-		azure.AssignAlertRulesToResources(alertRules, vms)
-		azure.AssignAlertRulesToResources(alertRules, aksClusters)
-		azure.AssignAlertRulesToResources(alertRules, mySQLServers)
-		azure.AssignAlertRulesToResources(alertRules, flexibleMySQLServers)
-		azure.AssignAlertRulesToResources(alertRules, sqlServers)
-		azure.AssignAlertRulesToResources(alertRules, storageAccounts)
-		azure.AssignAlertRulesToResources(alertRules, webApps)
-
-		err = azure.FetchVMBackups(vms)
-		if err != nil {
-			log.Fatalln("Could not fetch VM backups: ", err.Error())
+	if opts.HasFormat("json") || opts.HasFormat("csv") {
+		var subscriptions []export.Subscription
+		for _, report := range reports {
+			subscriptions = append(subscriptions, export.Subscription{Snapshot: report.snapshot, Findings: report.findings})
+		}
+		data := export.Build(opts.ClientName, subscriptions, opts.Environments)
+
+		// The scan data is already saved as <outputFilename>.json.
+		if opts.HasFormat("json") {
+			filename := fmt.Sprintf("%s-report.json", outputFilename)
+			err := export.WriteJSON(filename, data)
+			if err != nil {
+				log.Println("Could not generate json report: ", err.Error())
+			} else {
+				fmt.Println(fmt.Sprintf("Saved JSON report to %s", filename))
+			}
 		}
 
-		recommendations, err := azure.FetchAdvisorRecommendations()
-		if err != nil {
-			log.Fatalln("Could not fetch advisor recommendations: ", err.Error())
+		if opts.HasFormat("csv") {
+			filenames, err := export.WriteCSV(outputFilename, data)
+			if err != nil {
+				log.Println("Could not generate csv files: ", err.Error())
+			}
+			if len(filenames) > 0 {
+				fmt.Println(fmt.Sprintf("Saved CSV files to %s", strings.Join(filenames, ", ")))
+			}
 		}
+	}
+}
 
-		patchResults := make(chan azure.PatchResult, len(vms))
-		var wg sync.WaitGroup
-		wg.Add(len(vms))
+// runScan collects the selected subscriptions, saves the scan data and renders the reports.
+func runScan(args []string) error {
+	opts, err := parseOptions(args)
+	if err != nil {
+		return err
+	}
 
-		fmt.Println(fmt.Sprintf("Created queue for %d VMs...", len(vms)))
+	err = os.MkdirAll(opts.OutputDir, 0755)
+	if err != nil {
+		return errors.New(fmt.Sprintf("could not create output directory: %s", err.Error()))
+	}
 
-		for _, vm := range vms {
-			vm := vm // Don't remove this. It's for the iteration variable in the range loop. Otherwise you end up with the &vm below constantly pointing to the same object.
-			go azure.AssessPatches(&vm, patchResults, &wg)
-		}
+	b := newBackend(opts)
+	subscriptions, err := selectSubscriptions(opts, b)
+	if err != nil {
+		return errors.New(fmt.Sprintf("could not discover subscriptions: %s", azure.Describe(err)))
+	}
 
-		fmt.Println("Deallocated VM's")
-		for _, vm := range vmsDeallocated {
-			fmt.Println(vm.Name)
-		}
-		fmt.Println("")
+	for _, subscription := range subscriptions {
+		fmt.Println(fmt.Sprintf("Scanning %s (%s)", subscription.DisplayName(), subscription.Id))
+	}
 
-		go func() {
-			defer close(patchResults)
-			wg.Wait()
-		}()
+	var inventory *azure.Inventory
+	if opts.Inventory == inventoryGraph {
+		inventory, err = fetchInventory(b, subscriptions)
+		if err != nil {
+			log.Println("Could not fetch the Resource Graph inventory, listing each resource type instead: ", azure.Describe(err))
+		}
+	}
 
-		for patchResult := range patchResults {
-			vms[strings.ToLower(patchResult.VM.Id)] = *patchResult.VM
+	store := historyStore(opts)
+
+	// Subscriptions are scanned concurrently, at most opts.Parallelism at a time.
+	problemCounts := make([]int, len(subscriptions))
+	reports := make([]*subscriptionReport, len(subscriptions))
+	slots := make(chan struct{}, opts.Parallelism)
+	var wg sync.WaitGroup
+	for i, subscription := range subscriptions {
+		wg.Add(1)
+		go func(i int, subscription azure.Subscription) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			collector, err := b.collector(subscription.Id)
+			if err != nil {
+				log.Println(fmt.Sprintf("Skipping subscription %s, could not set up the %s backend: %s", subscription.DisplayName(), opts.Backend, err.Error()))
+				problemCounts[i] = 1
+				return
+			}
+
+			if inventory != nil {
+				collector = azure.GraphCollector{Collector: collector, Inventory: inventory, SubscriptionId: subscription.Id}
+			}
+
+			snapshot := scan.Snapshot{
+				ToolVersion: version,
+				ClientName:  opts.ClientName,
+				StartedAt:   time.Now(),
+				Settings:    opts.Settings,
+			}
+			snapshot.Result = scan.Run(collector, subscription.Id, opts.Settings)
+			snapshot.Result.SubscriptionName = subscription.Name
+			snapshot.Result.TenantId = subscription.TenantId
+			snapshot.FinishedAt = time.Now()
+			problemCounts[i] = len(snapshot.Result.Problems)
+
+			// Look for the last scan before this one is saved, since a scan on the same day replaces it.
+			changes := compareWithPrevious(store, opts.OutputDir, nil, snapshot, opts.Rules, opts.Environments)
+
+			filename := fmt.Sprintf("%s.json", outputFilename(opts, subscription.Id, snapshot.StartedAt))
+			err = scan.SaveSnapshot(filename, snapshot)
+			if err != nil {
+				log.Println("Could not save scan data: ", err.Error())
+			} else {
+				fmt.Println(fmt.Sprintf("Saved scan data to %s", filename))
+			}
+
+			// A replayed recording is an old scan with a new date, so it would only distort the trend.
+			if store != nil && opts.ReplayDir == "" {
+				_, err = store.Save(snapshot)
+				if err != nil {
+					log.Println("Could not add the scan to the history: ", err.Error())
+				}
+			}
+
+			report := newSubscriptionReport(opts, snapshot, changes, trendUntil(opts, store, snapshot))
+			if opts.Consolidate {
+				reports[i] = &report
+				return
+			}
+			writeReports(opts, outputFilename(opts, subscription.Id, snapshot.StartedAt), []subscriptionReport{report})
+		}(i, subscription)
+	}
+	wg.Wait()
+
+	if opts.Consolidate {
+		var scanned []subscriptionReport
+		for _, report := range reports {
+			if report != nil {
+				scanned = append(scanned, *report)
+			}
+		}
+		if len(scanned) > 0 {
+			writeReports(opts, consolidatedFilename(opts, scanned[0].snapshot.StartedAt), scanned)
 		}
+	}
 
-		now := time.Now()
-		outputFilename := fmt.Sprintf("%s-%s-%d-%d-%d", clientName, subscriptionId, now.Year(), now.Month(), now.Day())
+	problemCount := 0
+	for _, count := range problemCounts {
+		problemCount += count
+	}
 
-		g := pdf.NewGenerator()
-		g.ClientName = clientName
-		g.SubscriptionId = subscriptionId
-		g.OutputFilename = outputFilename
-		g.VirtualMachines = vms
-		g.VirtualMachinesDeallocated = vmsDeallocated
-		g.AzureKubernetesServices = aksClusters
-		g.MySQLServers = mySQLServers
-		g.FlexibleMySQLServers = flexibleMySQLServers
-		g.SqlServers = sqlServers
-		g.StorageAccounts = storageAccounts
-		g.WebApps = webApps
-		g.Recommendations = recommendations
-		err = g.GeneratePDF()
-		if err != nil {
-			log.Println("Could not generate pdf report: ", err.Error())
-		}
-
-		err = excel.OutputExcelDocument(
-			outputFilename,
-			vms,
-			vmsDeallocated,
-			aksClusters,
-			mySQLServers,
-			flexibleMySQLServers,
-			sqlServers,
-			storageAccounts,
-			webApps,
-			recommendations,
-		)
-		if err != nil {
-			log.Fatalln("Could not generate excel file: ", err.Error())
-		}
+	if problemCount > 0 {
+		fmt.Println(fmt.Sprintf("All done, but %d checks could not be completed. See the collection problems section of the reports.", problemCount))
+		return nil
 	}
 
 	fmt.Println("All done.")
+
+	return nil
+}
+
+func main() {
+	err := runCommand(os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/jayps/azure-checker-go/azure"
+)
+
+// fakeAz answers the tenant-wide commands of a discovery run with an inventory: the subscription list and the
+// Resource Graph query.
+const fakeAz = `#!/bin/sh
+case "$1 $2" in
+"account list")
+	echo '[{"id": "00000000-0000-0000-0000-000000000001", "name": "Prod-Web", "tenantId": "tenant-a", "state": "Enabled"}, {"id": "00000000-0000-0000-0000-000000000002", "name": "Old", "tenantId": "tenant-a", "state": "Disabled"}]'
+	;;
+"graph query")
+	echo '{"data": [{"id": "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/rg-web/providers/Microsoft.Compute/virtualMachines/vm-web-01", "name": "vm-web-01", "type": "Microsoft.Compute/virtualMachines", "resourceGroup": "rg-web", "subscriptionId": "00000000-0000-0000-0000-000000000001", "powerState": "PowerState/running"}]}'
+	;;
+*)
+	echo "unexpected command: az $*" >&2
+	exit 1
+	;;
+esac
+`
+
+// discoverAndTakeInventory runs discovery and the Resource Graph inventory the way a scan does.
+func discoverAndTakeInventory(t *testing.T, opts options) ([]azure.Subscription, *azure.Inventory) {
+	t.Helper()
+
+	b := newBackend(opts)
+	subscriptions, err := selectSubscriptions(opts, b)
+	if err != nil {
+		t.Fatalf("could not discover subscriptions: %s", err)
+	}
+	inventory, err := fetchInventory(b, subscriptions)
+	if err != nil {
+		t.Fatalf("could not fetch the inventory: %s", err)
+	}
+
+	return subscriptions, inventory
+}
+
+func TestRecordThenReplayDiscoveryAndInventory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake az is a shell script")
+	}
+
+	bin := t.TempDir()
+	err := os.WriteFile(filepath.Join(bin, "az"), []byte(fakeAz), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+
+	recording := t.TempDir()
+	opts := options{Discover: true, Backend: backendCLI, Inventory: inventoryGraph, RecordDir: recording}
+	recorded, _ := discoverAndTakeInventory(t, opts)
+
+	// The replay must not need az at all.
+	t.Setenv("PATH", t.TempDir())
+
+	opts = options{Discover: true, Backend: backendCLI, Inventory: inventoryGraph, ReplayDir: recording}
+	replayed, inventory := discoverAndTakeInventory(t, opts)
+
+	if len(recorded) != 1 || len(replayed) != 1 || replayed[0] != recorded[0] {
+		t.Errorf("recorded %v, replayed %v, want the one enabled subscription", recorded, replayed)
+	}
+
+	vms := inventory.Resources("00000000-0000-0000-0000-000000000001", "Microsoft.Compute/virtualMachines", nil)
+	if len(vms) != 1 {
+		t.Errorf("got %d VMs from the replayed inventory, want 1", len(vms))
+	}
+}
@@ -0,0 +1,518 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jayps/azure-checker-go/azure"
+	"github.com/jayps/azure-checker-go/config"
+	"github.com/jayps/azure-checker-go/history"
+	"github.com/jayps/azure-checker-go/rules"
+	"github.com/jayps/azure-checker-go/scan"
+	"golang.org/x/term"
+)
+
+const (
+	envSubscriptions = "AZURE_CHECKER_SUBSCRIPTIONS"
+	envClient        = "AZURE_CHECKER_CLIENT"
+	envOutputDir     = "AZURE_CHECKER_OUTPUT_DIR"
+	envFormats       = "AZURE_CHECKER_FORMATS"
+	envRecord        = "AZURE_CHECKER_RECORD"
+	envReplay        = "AZURE_CHECKER_REPLAY"
+	envParallelism   = "AZURE_CHECKER_PARALLELISM"
+	envDiscover      = "AZURE_CHECKER_DISCOVER"
+	envTenant        = "AZURE_CHECKER_TENANT"
+	envManagementGrp = "AZURE_CHECKER_MANAGEMENT_GROUP"
+	envInclude       = "AZURE_CHECKER_INCLUDE"
+	envExclude       = "AZURE_CHECKER_EXCLUDE"
+	envBackend       = "AZURE_CHECKER_BACKEND"
+	envARMEndpoint   = "AZURE_CHECKER_ARM_ENDPOINT"
+	envInventory     = "AZURE_CHECKER_INVENTORY"
+	envConfig        = "AZURE_CHECKER_CONFIG"
+	envProfile       = "AZURE_CHECKER_PROFILE"
+	envHistoryDir    = "AZURE_CHECKER_HISTORY_DIR"
+	envRules         = "AZURE_CHECKER_RULES"
+	envWaivers       = "AZURE_CHECKER_WAIVERS"
+	envConsolidate   = "AZURE_CHECKER_CONSOLIDATE"
+)
+
+const (
+	backendCLI = "cli"
+	backendARM = "arm"
+
+	inventoryList  = "list"
+	inventoryGraph = "graph"
+)
+
+const defaultParallelism = 4
+
+type options struct {
+	SubscriptionIds []string
+	ClientName      string
+	OutputDir       string
+	Formats         []string
+	RecordDir       string
+	ReplayDir       string
+	Parallelism     int
+	Discover        bool
+	Filter          azure.SubscriptionFilter
+	Backend         string
+	ARMEndpoint     string
+	Inventory       string
+	Settings        scan.Settings
+	Branding        config.Branding
+	Previous        string // report only: the scan to compare with
+	HistoryDir      string // empty when the history is switched off
+	Rules           []rules.Rule
+	Waivers         []rules.Waiver
+	Environments    rules.Environments
+	Consolidate     bool // one report for all subscriptions instead of one per subscription
+}
+
+func (o options) HasFormat(format string) bool {
+	for _, f := range o.Formats {
+		if f == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitList turns a comma separated value into a list, dropping whitespace and empty entries.
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+// orDefault returns value, or fallback when value is empty. It is used to let flags and environment variables take
+// precedence over the config file.
+func orDefault(value string, fallback string) string {
+	if value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+// loadConfig reads the named profile or the config file named by the flag or environment variable, or
+// azure-checker.yaml in the working directory if there is one. Without a config file everything comes from the flags.
+func loadConfig(profileName string, path string) (config.Config, error) {
+	if profileName != "" && path != "" {
+		return config.Config{}, errors.New("--profile and --config cannot be used together")
+	}
+
+	if profileName != "" {
+		profile, err := config.LoadProfile(profileName)
+		return profile.Config, err
+	}
+
+	if path != "" {
+		return config.Load(path)
+	}
+
+	_, err := os.Stat(config.DefaultFilename)
+	if err != nil {
+		return config.Config{}, nil
+	}
+
+	return config.Load(config.DefaultFilename)
+}
+
+// loadRules returns the built-in rules and the custom rules in the files named by the flag or environment variable,
+// or else in the config file.
+func loadRules(flagValue string, cfg config.Config) ([]rules.Rule, error) {
+	paths := splitList(firstNonEmpty(flagValue, envRules))
+	if len(paths) == 0 {
+		paths = cfg.Rules
+	}
+
+	return rules.Load(paths)
+}
+
+// loadWaivers reads the waiver files named by the flag or environment variable, or else in the config file, and
+// checks that they are for rules in ruleSet.
+func loadWaivers(flagValue string, cfg config.Config, ruleSet []rules.Rule) ([]rules.Waiver, error) {
+	paths := splitList(firstNonEmpty(flagValue, envWaivers))
+	if len(paths) == 0 {
+		paths = cfg.Waivers
+	}
+
+	waivers, err := rules.LoadWaivers(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	return waivers, rules.CheckWaivers(waivers, ruleSet)
+}
+
+// historyDir resolves where the scan history is kept, from the flag or environment variable or else the default. It
+// is empty when the history is switched off.
+func historyDir(flagValue string, disabled bool) (string, error) {
+	if disabled {
+		return "", nil
+	}
+
+	if dir := firstNonEmpty(flagValue, envHistoryDir); dir != "" {
+		return dir, nil
+	}
+
+	return history.DefaultDir()
+}
+
+// firstNonEmpty returns the flag value if it was supplied, otherwise the value of the environment variable.
+func firstNonEmpty(flagValue string, envName string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+
+	return strings.TrimSpace(os.Getenv(envName))
+}
+
+func isInteractive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+func prompt(reader *bufio.Reader, question string) (string, error) {
+	fmt.Println(question)
+
+	line, err := reader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+// formatsUsage describes the --formats flag.
+var formatsUsage = fmt.Sprintf("comma separated list of output formats: %s (default %q)", strings.Join(config.Formats, ", "), strings.Join(config.DefaultFormats, ","))
+
+// validateFormats checks the requested output formats, defaulting to the PDF and Excel reports.
+func validateFormats(formats []string) ([]string, error) {
+	if len(formats) == 0 {
+		return config.DefaultFormats, nil
+	}
+
+	return config.NormalizeFormats(formats)
+}
+
+func validateSubscriptionIds(subscriptionIds []string) error {
+	for _, subscriptionId := range subscriptionIds {
+		err := azure.ValidateSubscriptionId(subscriptionId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func recordedSubscriptions(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			result = append(result, entry.Name())
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, errors.New(fmt.Sprintf("no recorded subscriptions found in %s", dir))
+	}
+
+	return result, nil
+}
+
+func parseOptions(args []string) (options, error) {
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: azure-checker scan [flags]")
+		fmt.Fprintln(flags.Output(), "")
+		fmt.Fprintln(flags.Output(), "Collects the selected subscriptions, saves the scan data as JSON next to the reports and renders them.")
+		fmt.Fprintln(flags.Output(), "")
+		fmt.Fprintln(flags.Output(), "Flags can also be supplied through environment variables:")
+		for _, name := range []string{envSubscriptions, envClient, envOutputDir, envFormats, envRecord, envReplay, envParallelism, envDiscover, envTenant, envManagementGrp, envInclude, envExclude, envBackend, envARMEndpoint, envInventory, envConfig, envProfile, envHistoryDir, envRules, envWaivers, envConsolidate} {
+			fmt.Fprintf(flags.Output(), "  %s\n", name)
+		}
+		fmt.Fprintln(flags.Output(), "")
+		flags.PrintDefaults()
+	}
+
+	subscriptions := flags.String("subscriptions", "", "comma separated list of subscription IDs to check")
+	client := flags.String("client", "", "name of the client, used in the report and output filenames")
+	outputDir := flags.String("output-dir", "", "directory the reports are written to (default \".\")")
+	formats := flags.String("formats", "", formatsUsage)
+	record := flags.String("record", "", "save the raw output of every az command to this directory")
+	replay := flags.String("replay", "", "generate the reports from a directory created with --record instead of querying Azure")
+	parallelism := flags.String("parallel", "", fmt.Sprintf("number of subscriptions to scan at the same time (default %d)", defaultParallelism))
+	discover := flags.Bool("discover", false, "scan every enabled subscription returned by az account list instead of --subscriptions")
+	tenant := flags.String("tenant", "", "only discover subscriptions in this tenant")
+	managementGroup := flags.String("management-group", "", "only discover subscriptions directly under this management group (implies --discover)")
+	include := flags.String("include", "", "comma separated subscription name or ID patterns to discover, e.g. \"Prod-*\"")
+	exclude := flags.String("exclude", "", "comma separated subscription name or ID patterns to skip during discovery")
+	backend := flags.String("backend", "", "how to talk to Azure: cli (the az CLI) or arm (the Resource Manager REST API) (default \"cli\")")
+	armEndpoint := flags.String("arm-endpoint", "", fmt.Sprintf("Resource Manager endpoint used by the arm backend (default %q)", azure.DefaultARMEndpoint))
+	profile := flags.String("profile", "", "name of a saved client profile to scan, see the profile command")
+	historyFlag := flags.String("history-dir", "", "directory the scan history is kept in for comparisons and trends (default: azure-checker/history in the user config directory)")
+	noHistory := flags.Bool("no-history", false, "do not read or add to the scan history")
+	configFile := flags.String("config", "", fmt.Sprintf("YAML or JSON config file (default %q if it exists)", config.DefaultFilename))
+	rulesFiles := flags.String("rules", "", "comma separated list of custom rules files, see docs/rules.md")
+	waiverFiles := flags.String("waivers", "", "comma separated list of waiver files accepting the risk of findings, see docs/waivers.md")
+	inventory := flags.String("inventory", "", "how to find resources: list (one request per resource type) or graph (one Azure Resource Graph query, limited to the resource types that are checked) (default \"list\")")
+	consolidate := flags.Bool("consolidate", false, "write one report covering all scanned subscriptions instead of one per subscription")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return options{}, err
+	}
+
+	if flags.NArg() > 0 {
+		return options{}, errors.New(fmt.Sprintf("unexpected arguments: %s", strings.Join(flags.Args(), " ")))
+	}
+
+	cfg, err := loadConfig(firstNonEmpty(*profile, envProfile), firstNonEmpty(*configFile, envConfig))
+	if err != nil {
+		return options{}, err
+	}
+
+	historyPath, err := historyDir(*historyFlag, *noHistory)
+	if err != nil {
+		return options{}, err
+	}
+
+	ruleSet, err := loadRules(*rulesFiles, cfg)
+	if err != nil {
+		return options{}, err
+	}
+
+	waivers, err := loadWaivers(*waiverFiles, cfg, ruleSet)
+	if err != nil {
+		return options{}, err
+	}
+
+	result := options{
+		SubscriptionIds: splitList(firstNonEmpty(*subscriptions, envSubscriptions)),
+		ClientName:      orDefault(firstNonEmpty(*client, envClient), cfg.Client),
+		OutputDir:       orDefault(firstNonEmpty(*outputDir, envOutputDir), cfg.Output.Dir),
+		Formats:         splitList(firstNonEmpty(*formats, envFormats)),
+		RecordDir:       firstNonEmpty(*record, envRecord),
+		ReplayDir:       firstNonEmpty(*replay, envReplay),
+		Backend:         strings.ToLower(firstNonEmpty(*backend, envBackend)),
+		ARMEndpoint:     firstNonEmpty(*armEndpoint, envARMEndpoint),
+		Inventory:       strings.ToLower(firstNonEmpty(*inventory, envInventory)),
+		Filter: azure.SubscriptionFilter{
+			TenantId:        firstNonEmpty(*tenant, envTenant),
+			ManagementGroup: firstNonEmpty(*managementGroup, envManagementGrp),
+			Include:         splitList(firstNonEmpty(*include, envInclude)),
+			Exclude:         splitList(firstNonEmpty(*exclude, envExclude)),
+		},
+		Settings:     cfg.Settings(),
+		Branding:     cfg.Branding,
+		HistoryDir:   historyPath,
+		Rules:        ruleSet,
+		Waivers:      waivers,
+		Environments: cfg.Environments,
+	}
+
+	if len(result.Formats) == 0 {
+		result.Formats = cfg.Output.Formats
+	}
+
+	discoverEnv, _ := strconv.ParseBool(os.Getenv(envDiscover))
+	result.Discover = *discover || discoverEnv || result.Filter.ManagementGroup != ""
+
+	consolidateEnv, _ := strconv.ParseBool(os.Getenv(envConsolidate))
+	result.Consolidate = *consolidate || consolidateEnv || cfg.Output.Consolidate
+
+	// The config file only decides which subscriptions to scan when the flags and environment do not.
+	if !result.Discover && len(result.SubscriptionIds) == 0 {
+		result.SubscriptionIds = cfg.Subscriptions
+		result.Discover = cfg.Discovery.Enabled || cfg.Discovery.ManagementGroup != ""
+		result.Filter.TenantId = orDefault(result.Filter.TenantId, cfg.Discovery.Tenant)
+		result.Filter.ManagementGroup = orDefault(result.Filter.ManagementGroup, cfg.Discovery.ManagementGroup)
+		if len(result.Filter.Include) == 0 {
+			result.Filter.Include = cfg.Discovery.Include
+		}
+		if len(result.Filter.Exclude) == 0 {
+			result.Filter.Exclude = cfg.Discovery.Exclude
+		}
+	}
+
+	if result.Discover && len(result.SubscriptionIds) > 0 {
+		return options{}, errors.New("--subscriptions cannot be combined with --discover")
+	}
+
+	err = azure.ValidatePatterns(append(result.Filter.Include, result.Filter.Exclude...))
+	if err != nil {
+		return options{}, err
+	}
+
+	result.Parallelism = defaultParallelism
+	if value := firstNonEmpty(*parallelism, envParallelism); value != "" {
+		result.Parallelism, err = strconv.Atoi(value)
+		if err != nil || result.Parallelism < 1 {
+			return options{}, errors.New(fmt.Sprintf("--parallel must be a positive number, got %q", value))
+		}
+	}
+
+	if result.Backend == "" {
+		result.Backend = backendCLI
+	}
+	if result.Backend != backendCLI && result.Backend != backendARM {
+		return options{}, errors.New(fmt.Sprintf("unsupported backend %q, expected %s or %s", result.Backend, backendCLI, backendARM))
+	}
+	if result.Backend == backendARM && (result.RecordDir != "" || result.ReplayDir != "") {
+		return options{}, errors.New("--record and --replay capture az CLI output and only work with the cli backend")
+	}
+
+	if result.Inventory == "" {
+		result.Inventory = inventoryList
+	}
+	if result.Inventory != inventoryList && result.Inventory != inventoryGraph {
+		return options{}, errors.New(fmt.Sprintf("unsupported inventory mode %q, expected %s or %s", result.Inventory, inventoryList, inventoryGraph))
+	}
+
+	if result.RecordDir != "" && result.ReplayDir != "" {
+		return options{}, errors.New("--record and --replay cannot be used together")
+	}
+
+	if result.OutputDir == "" {
+		result.OutputDir = "."
+	}
+
+	result.Formats, err = validateFormats(result.Formats)
+	if err != nil {
+		return options{}, err
+	}
+
+	// A recording holds one directory per subscription, so replay everything in it unless told otherwise.
+	if result.ReplayDir != "" && !result.Discover && len(result.SubscriptionIds) == 0 {
+		result.SubscriptionIds, err = recordedSubscriptions(result.ReplayDir)
+		if err != nil {
+			return options{}, err
+		}
+	}
+
+	if (result.Discover || len(result.SubscriptionIds) > 0) && result.ClientName != "" {
+		return result, validateSubscriptionIds(result.SubscriptionIds)
+	}
+
+	// Only fall back to prompting when somebody is there to answer.
+	if !isInteractive() {
+		return options{}, errors.New(fmt.Sprintf("--subscriptions and --client (or %s and %s) are required when not running in a terminal", envSubscriptions, envClient))
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	for !result.Discover && len(result.SubscriptionIds) == 0 {
+		input, err := prompt(reader, "Enter a comma separated list of subscription IDs you are checking:")
+		if err != nil {
+			return options{}, err
+		}
+		result.SubscriptionIds = splitList(input)
+	}
+
+	for result.ClientName == "" {
+		result.ClientName, err = prompt(reader, "Enter the name of the client:")
+		if err != nil {
+			return options{}, err
+		}
+	}
+
+	return result, validateSubscriptionIds(result.SubscriptionIds)
+}
+
+// parseReportOptions reads the flags of the report command, which renders saved scan data. The client name, output
+// directory, formats and branding come from the flags, the config file or profile, or else the scan data itself.
+func parseReportOptions(args []string) (options, []string, error) {
+	flags := flag.NewFlagSet("report", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: azure-checker report [flags] <scan.json>...")
+		fmt.Fprintln(flags.Output(), "")
+		fmt.Fprintln(flags.Output(), "Renders the reports from scan data saved by the scan command, without querying Azure.")
+		fmt.Fprintln(flags.Output(), "")
+		flags.PrintDefaults()
+	}
+
+	client := flags.String("client", "", "name of the client (default: the client the scan was made for)")
+	outputDir := flags.String("output-dir", "", "directory the reports are written to (default \".\")")
+	formats := flags.String("formats", "", formatsUsage)
+	profile := flags.String("profile", "", "name of a saved client profile to take the branding and output settings from")
+	configFile := flags.String("config", "", fmt.Sprintf("YAML or JSON config file (default %q if it exists)", config.DefaultFilename))
+	previous := flags.String("previous", "", "scan data to compare with (default: the latest earlier scan of the subscription in the history or next to the scan data)")
+	historyFlag := flags.String("history-dir", "", "directory the scan history is kept in (default: azure-checker/history in the user config directory)")
+	noHistory := flags.Bool("no-history", false, "do not read the scan history")
+	rulesFiles := flags.String("rules", "", "comma separated list of custom rules files, see docs/rules.md")
+	waiverFiles := flags.String("waivers", "", "comma separated list of waiver files accepting the risk of findings, see docs/waivers.md")
+	consolidate := flags.Bool("consolidate", false, "write one report covering all the given scans instead of one per scan")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return options{}, nil, err
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return options{}, nil, errors.New("no scan data given")
+	}
+
+	cfg, err := loadConfig(firstNonEmpty(*profile, envProfile), firstNonEmpty(*configFile, envConfig))
+	if err != nil {
+		return options{}, nil, err
+	}
+
+	historyPath, err := historyDir(*historyFlag, *noHistory)
+	if err != nil {
+		return options{}, nil, err
+	}
+
+	ruleSet, err := loadRules(*rulesFiles, cfg)
+	if err != nil {
+		return options{}, nil, err
+	}
+
+	waivers, err := loadWaivers(*waiverFiles, cfg, ruleSet)
+	if err != nil {
+		return options{}, nil, err
+	}
+
+	result := options{
+		ClientName:   orDefault(firstNonEmpty(*client, envClient), cfg.Client),
+		OutputDir:    orDefault(orDefault(firstNonEmpty(*outputDir, envOutputDir), cfg.Output.Dir), "."),
+		Formats:      splitList(firstNonEmpty(*formats, envFormats)),
+		Branding:     cfg.Branding,
+		Previous:     *previous,
+		HistoryDir:   historyPath,
+		Rules:        ruleSet,
+		Waivers:      waivers,
+		Environments: cfg.Environments,
+	}
+
+	consolidateEnv, _ := strconv.ParseBool(os.Getenv(envConsolidate))
+	result.Consolidate = *consolidate || consolidateEnv || cfg.Output.Consolidate
+
+	if len(result.Formats) == 0 {
+		result.Formats = cfg.Output.Formats
+	}
+	result.Formats, err = validateFormats(result.Formats)
+	if err != nil {
+		return options{}, nil, err
+	}
+
+	return result, flags.Args(), nil
+}
@@ -0,0 +1,120 @@
+package pdf
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"strings"
+)
+
+const (
+	chartWidth  = 640
+	chartHeight = 240
+	chartLeft   = 48
+	chartRight  = 16
+	chartTop    = 16
+	chartBottom = 40
+	chartTicks  = 4
+)
+
+var chartColors = []string{"#1f77b4", "#e8542f", "#2ca02c", "#ff7f0e", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f"}
+
+// chartSeries is one line on a chart. A nil value leaves a gap in the line.
+type chartSeries struct {
+	Name   string
+	Values []*float64
+}
+
+// niceMax rounds the largest value up to a number that divides evenly into the axis ticks.
+func niceMax(series []chartSeries) float64 {
+	largest := 0.0
+	for _, s := range series {
+		for _, value := range s.Values {
+			if value != nil && *value > largest {
+				largest = *value
+			}
+		}
+	}
+
+	if largest <= 0 {
+		return chartTicks
+	}
+
+	step := math.Pow(10, math.Floor(math.Log10(largest/chartTicks)))
+	for _, multiplier := range []float64{1, 2, 2.5, 5, 10} {
+		if step*multiplier*chartTicks >= largest {
+			return step * multiplier * chartTicks
+		}
+	}
+
+	return largest
+}
+
+// lineChart draws an inline SVG line chart with one point per label. yMax fixes the top of the axis, e.g. 100 for
+// percentages; 0 scales the axis to the data.
+func lineChart(labels []string, series []chartSeries, yMax float64, unit string) string {
+	if yMax <= 0 {
+		yMax = niceMax(series)
+	}
+
+	plotWidth := float64(chartWidth - chartLeft - chartRight)
+	plotHeight := float64(chartHeight - chartTop - chartBottom)
+	x := func(i int) float64 {
+		if len(labels) < 2 {
+			return chartLeft + plotWidth/2
+		}
+		return chartLeft + plotWidth*float64(i)/float64(len(labels)-1)
+	}
+	y := func(value float64) float64 {
+		return chartTop + plotHeight - plotHeight*value/yMax
+	}
+
+	legendHeight := 18 * ((len(series) + 2) / 3)
+	output := fmt.Sprintf("<svg xmlns='http://www.w3.org/2000/svg' width='%d' height='%d' viewBox='0 0 %d %d' font-family='Montserrat, sans-serif' font-size='10'>",
+		chartWidth, chartHeight+legendHeight, chartWidth, chartHeight+legendHeight)
+
+	// Axis and grid lines
+	for tick := 0; tick <= chartTicks; tick++ {
+		value := yMax * float64(tick) / chartTicks
+		output += fmt.Sprintf("<line x1='%d' y1='%.1f' x2='%d' y2='%.1f' stroke='#ddd' />", chartLeft, y(value), chartWidth-chartRight, y(value))
+		output += fmt.Sprintf("<text x='%d' y='%.1f' text-anchor='end' fill='#666'>%s%s</text>", chartLeft-6, y(value)+3, strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.1f", value), "0"), "."), unit)
+	}
+	for i, label := range labels {
+		output += fmt.Sprintf("<text x='%.1f' y='%d' text-anchor='middle' fill='#666'>%s</text>", x(i), chartHeight-chartBottom+16, html.EscapeString(label))
+	}
+
+	// Lines, broken where a value is missing
+	for i, s := range series {
+		color := chartColors[i%len(chartColors)]
+		path := ""
+		move := true
+		for j, value := range s.Values {
+			if value == nil {
+				move = true
+				continue
+			}
+			command := "L"
+			if move {
+				command = "M"
+				move = false
+			}
+			path += fmt.Sprintf("%s%.1f %.1f ", command, x(j), y(*value))
+			output += fmt.Sprintf("<circle cx='%.1f' cy='%.1f' r='3' fill='%s' />", x(j), y(*value), color)
+		}
+		if path != "" {
+			output += fmt.Sprintf("<path d='%s' fill='none' stroke='%s' stroke-width='2' />", strings.TrimSpace(path), color)
+		}
+	}
+
+	// Legend, three entries per row
+	for i, s := range series {
+		legendX := chartLeft + (i%3)*200
+		legendY := chartHeight + 18*(i/3)
+		output += fmt.Sprintf("<rect x='%d' y='%d' width='10' height='10' fill='%s' />", legendX, legendY-9, chartColors[i%len(chartColors)])
+		output += fmt.Sprintf("<text x='%d' y='%d' fill='#666'>%s</text>", legendX+14, legendY, html.EscapeString(s.Name))
+	}
+
+	output += "</svg>"
+
+	return output
+}
@@ -0,0 +1,280 @@
+package pdf
+
+import (
+	"fmt"
+	"html"
+	"os"
+)
+
+// interactiveStylesheet lays the report out for a screen instead of pages, and styles the controls added by
+// interactiveScript.
+const interactiveStylesheet = `<style>
+body {
+	font-family: 'Montserrat', 'Segoe UI', Helvetica, Arial, sans-serif;
+	max-width: 1100px;
+	margin: 0 auto;
+	padding: 0 16px 48px 16px;
+}
+
+.toolbar {
+	position: sticky;
+	top: 0;
+	z-index: 1;
+	display: flex;
+	gap: 8px;
+	align-items: center;
+	padding: 8px 0;
+	background-color: #fff;
+	border-bottom: 1px solid #ddd;
+}
+
+.toolbar input {
+	flex: 1;
+}
+
+input[type=search] {
+	font: inherit;
+	padding: 4px 8px;
+	border: 1px solid #ccc;
+}
+
+.table-filter {
+	display: block;
+	width: 300px;
+	margin: 8px 0;
+}
+
+button {
+	font: inherit;
+	padding: 4px 12px;
+	border: 1px solid #ccc;
+	background-color: #eee;
+	cursor: pointer;
+}
+
+.section-title {
+	cursor: pointer;
+	border-bottom: 1px solid #ddd;
+}
+
+.section-title:before {
+	content: '\25BE  ';
+}
+
+.section-title.collapsed:before {
+	content: '\25B8  ';
+}
+
+.hidden {
+	display: none;
+}
+
+th.sortable {
+	cursor: pointer;
+}
+
+th.sorted-ascending:after {
+	content: ' \25B4';
+}
+
+th.sorted-descending:after {
+	content: ' \25BE';
+}
+
+@media print {
+	.toolbar, .table-filter {
+		display: none;
+	}
+
+	.section-content.hidden {
+		display: block;
+	}
+}
+</style>
+`
+
+// interactiveScript makes the sections collapsible, the tables sortable and filterable, and adds a search box that
+// narrows the report down to the sections, tables rows and blocks mentioning the search text.
+const interactiveScript = `<script>
+document.addEventListener('DOMContentLoaded', function () {
+	function textOf(element) {
+		return (element.textContent || '').toLowerCase();
+	}
+
+	function each(elements, callback) {
+		Array.prototype.forEach.call(elements, callback);
+	}
+
+	// Every section heading folds the content up to the next one.
+	var sections = [];
+	each(document.querySelectorAll('h2'), function (heading) {
+		var content = document.createElement('div');
+		content.className = 'section-content';
+		while (heading.nextSibling && heading.nextSibling.nodeName !== 'H2') {
+			content.appendChild(heading.nextSibling);
+		}
+		heading.parentNode.insertBefore(content, heading.nextSibling);
+		heading.classList.add('section-title');
+
+		var section = {heading: heading, content: content};
+		heading.addEventListener('click', function () {
+			setCollapsed(section, !heading.classList.contains('collapsed'));
+		});
+		sections.push(section);
+	});
+
+	function setCollapsed(section, collapsed) {
+		section.heading.classList.toggle('collapsed', collapsed);
+		section.content.classList.toggle('hidden', collapsed);
+	}
+
+	// Tables sort on a click on a column header, numbers and percentages by value. Rows can be filtered by text.
+	function sortKey(row, column) {
+		var cell = row.cells[column];
+		var value = cell ? cell.textContent.trim() : '';
+		if (/^-?\d+(\.\d+)?%?$/.test(value)) {
+			return {number: parseFloat(value)};
+		}
+		return {text: value.toLowerCase()};
+	}
+
+	function compare(a, b) {
+		if (a.number !== undefined && b.number !== undefined) {
+			return a.number - b.number;
+		}
+		if (a.number !== undefined) {
+			return -1;
+		}
+		if (b.number !== undefined) {
+			return 1;
+		}
+		return a.text < b.text ? -1 : a.text > b.text ? 1 : 0;
+	}
+
+	each(document.querySelectorAll('table'), function (table) {
+		var header = table.rows[0];
+		if (!header || !header.querySelector('th')) {
+			return;
+		}
+		var body = header.parentNode;
+		function dataRows() {
+			return Array.prototype.slice.call(table.rows, 1);
+		}
+
+		each(header.cells, function (cell, column) {
+			cell.classList.add('sortable');
+			cell.title = 'Sort by this column';
+			cell.addEventListener('click', function () {
+				var ascending = !cell.classList.contains('sorted-ascending');
+				each(header.cells, function (other) {
+					other.classList.remove('sorted-ascending', 'sorted-descending');
+				});
+				cell.classList.add(ascending ? 'sorted-ascending' : 'sorted-descending');
+				var rows = dataRows();
+				rows.sort(function (a, b) {
+					var result = compare(sortKey(a, column), sortKey(b, column));
+					return ascending ? result : -result;
+				});
+				rows.forEach(function (row) {
+					body.appendChild(row);
+				});
+			});
+		});
+
+		var filter = document.createElement('input');
+		filter.type = 'search';
+		filter.className = 'table-filter';
+		filter.placeholder = 'Filter rows';
+		filter.addEventListener('input', function () {
+			var query = filter.value.toLowerCase();
+			dataRows().forEach(function (row) {
+				row.classList.toggle('hidden', query !== '' && textOf(row).indexOf(query) < 0);
+			});
+		});
+		table.parentNode.insertBefore(filter, table);
+	});
+
+	// The search box hides the sections that do not mention the search text, and within the others the table rows
+	// and blocks that do not.
+	var toolbar = document.createElement('div');
+	toolbar.className = 'toolbar';
+	var search = document.createElement('input');
+	search.type = 'search';
+	search.placeholder = 'Search the report, e.g. a resource name';
+	var matches = document.createElement('span');
+	var expand = document.createElement('button');
+	expand.textContent = 'Expand all';
+	var collapse = document.createElement('button');
+	collapse.textContent = 'Collapse all';
+	toolbar.appendChild(search);
+	toolbar.appendChild(matches);
+	toolbar.appendChild(expand);
+	toolbar.appendChild(collapse);
+	document.body.insertBefore(toolbar, document.body.firstChild);
+
+	expand.addEventListener('click', function () {
+		sections.forEach(function (section) {
+			setCollapsed(section, false);
+		});
+	});
+	collapse.addEventListener('click', function () {
+		sections.forEach(function (section) {
+			setCollapsed(section, true);
+		});
+	});
+
+	function items(section) {
+		var result = [];
+		each(section.content.querySelectorAll('tr, .page-break-avoid, .mb-1'), function (item) {
+			// Blocks in blocks go with the outer one.
+			var block = item.parentNode.closest('.page-break-avoid, .mb-1');
+			if (block && section.content.contains(block)) {
+				return;
+			}
+			if (item.nodeName === 'TR' && item.querySelector('th')) {
+				return;
+			}
+			result.push(item);
+		});
+		return result;
+	}
+
+	search.addEventListener('input', function () {
+		var query = search.value.trim().toLowerCase();
+		var found = 0;
+		sections.forEach(function (section) {
+			var matched = query === '' || textOf(section.heading).indexOf(query) >= 0 || textOf(section.content).indexOf(query) >= 0;
+			section.heading.classList.toggle('hidden', !matched);
+			section.content.classList.toggle('hidden', !matched || section.heading.classList.contains('collapsed') && query === '');
+			if (query !== '' && matched) {
+				found++;
+				section.heading.classList.remove('collapsed');
+			}
+			var headingMatched = query !== '' && textOf(section.heading).indexOf(query) >= 0;
+			items(section).forEach(function (item) {
+				item.classList.toggle('hidden', query !== '' && !headingMatched && textOf(item).indexOf(query) < 0);
+			});
+		});
+		matches.textContent = query === '' ? '' : found + (found === 1 ? ' section' : ' sections');
+	});
+});
+</script>
+`
+
+// GenerateHTMLReport writes the report as a single HTML file that can be emailed or put on a share. It embeds its
+// styles, scripts and images, and leaves out the web font of the PDF so it does not depend on other sites.
+func (g Generator) GenerateHTMLReport() error {
+	head := "<meta charset='utf-8'>\n<meta name='viewport' content='width=device-width, initial-scale=1'>\n"
+	head += fmt.Sprintf("<title>%s - %s</title>\n", html.EscapeString(g.Title), html.EscapeString(g.ClientName))
+	head += stylesheet + interactiveStylesheet + interactiveScript
+
+	filename := fmt.Sprintf("%s.html", g.OutputFilename)
+	err := os.WriteFile(filename, []byte(g.GenerateHTML(head)), 0644)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(fmt.Sprintf("Saved HTML report to %s", filename))
+
+	return nil
+}
@@ -2,36 +2,31 @@ package pdf
 
 import (
 	"fmt"
+	"html"
+	"sort"
 	"strings"
 	"time"
 
 	wkhtml "github.com/SebastiaanKlippert/go-wkhtmltopdf"
 	"github.com/jayps/azure-checker-go/azure"
+	"github.com/jayps/azure-checker-go/diff"
+	"github.com/jayps/azure-checker-go/history"
+	"github.com/jayps/azure-checker-go/rules"
+	"github.com/jayps/azure-checker-go/scan"
+	"github.com/jayps/azure-checker-go/scorecard"
 )
 
-type Generator struct {
-	Head                       string `default:"test"`
-	ClientName                 string `default:"Client"`
-	SubscriptionId             string
-	OutputFilename             string
-	VirtualMachines            map[string]azure.Resource
-	VirtualMachinesDeallocated map[string]azure.Resource
-	AzureKubernetesServices    map[string]azure.Resource
-	MySQLServers               map[string]azure.Resource
-	FlexibleMySQLServers       map[string]azure.Resource
-	SqlServers                 map[string]azure.Resource
-	StorageAccounts            map[string]azure.Resource
-	WebApps                    map[string]azure.Resource
-	Recommendations            map[string][]azure.AdvisorRecommendation
-}
+// defaultLogo is shown on the cover unless the configuration brands the report with a client's own logo.
+const defaultLogo = "data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAZAAAABVCAYAAABn7bJ/AAAAGXRFWHRTb2Z0d2FyZQBBZG9iZSBJbWFnZVJlYWR5ccllPAAAEWBJREFUeNrsXT1y4zoSxrx6+dM7wdDJpiOfwNSGm1iumtzSCSydwNYJZJ9Acr5VlpMN1/IJrEknGc0JVnuCt2i7uQPDJAWAIABK31fFUskWSfw0+utuAA0hAAAAAAAAAAAAQuETmgAAuo2//+OiLz9yeX2WV5//nGs/W/PnVl4/6fu///WwRusBXgjk6/fnpxKhA+ww+OffztYGA57a+SmhcpMyGTgqL5e6/Cnft/OsRPfJL71vIN+78fhOm7o7t3ENaVzKayivrMGjVvJ6pM+mfZKIXA/qiFGW8a899xPBnvqUT/nOG/lxbfjzmXz3TcNnhMBrOX+Dzgca4tLhnmGEcvbk9SAHYq/j3kbOZPkir0lD8ij6YiGvH/K58663jwdkiRl3SQMEAjRRZj1HMriCcrBu60xeD1z+NiIFPSakH2ztHjP6sg0WGOEgEKBdDFnxuAzQLFKZO6ccZHmH7HGE8NyoP6/Jyzlyb2QEIgWBAO3issG9VxHLTcph0hHyoHI+OBJ1E+QCoRwi0hGGOQgE8K/YMtEslDKMXIU5W/YptzF5SvOIRdhA0l/lpI9mAIEAftHUg8gSUOCLVJUDW74xrV8ijynE/NXze8LiAhAI4Bc+lP9l5DokuTKLl8PGnKehJaxj30utQSIgEAAoJnUzHySUwKCkejwl1LavpBa5GGOf+2UOBOSpztEM7/E7miAKtvKaOVjrpkp7Kd52G9uUJ5bnMJLXbWzlQPMNUmmOE5CNuWg+Yb7hq5CBL/zM3ODeW9kOq0Bj4L6l57YFWnzxs2yjXwCsHe6x2Xg4cykPCCQCpACSkFsJoRTcMwsCuW8rTUWDvR91ZHSbQLfEVA5F22bCfd6DZOqOjIe60BN7j+cV76Hd8qHmPbYx27oBaGUWlX0ZWGesbUlElvPa4vlOfYEQFuDiMfi2/vsJKYdRzPc73kcETOk3bvfNW5B3wZ7WiXhLY1KA7ruAeJt5iViZBQIB3D0G3y73ZUL1i6IcGngfNF8xtZ3wJi9YXhdMGkWeMEyamwGT6iAQwEHJkWI1Va53FiQygnJwCgtOm4ZSeL7jBJPmIBEXHMIcCAn+o8N95xbKEHiD6d6PHSkmHly5yWCk0FHouLKBcghpldt6YRSK8jJ3BM/DGcXKrPGxNsAhEMjUJIW6iq/fn3sibiqNrsLUSl4pn6b7GYjQl8eoHDh8ZWvMYJNfGoi++CImuh7CWtuSh6IIsSnITsmNLNrsXrFsTZeEDiMmWKxTDiEUQ275+yWv5APSwNHmzOq6BzJz7XDIvDVMQyxbbQnxo4XnQr+7TVA5tL1s05Y47w5Annq8474pdonM39Dii82xzSV1mUCcvI+v359Hws8u6mPyPjILK3lV8t00jHWVIIGEUA5nvhVmm6cDyvf7OAq776l8pAMGCchIMW92ckxzSl0OYcH7CAcb9/xeUzY2YazMk1VqS3KmyiGFsCdWSwU0UjssJyAQeB/JwCZ8VabgHlt4VxO87thOSDnYkOYzxDEYLhwI+6hyZnWVQOB9BAJ7BKaku/Jg8YdKsDiFcgDqwN4zrcKzDUnR4oujkJMuEgi8jzS9j8KyrxqIpiTiO9dWnXIYOCqHG4jF0ZDIRrgt5Z4cw8qsLhIIvI9w3gcpc9NBsNmztDS1MJZKItayhKNOj4pEVsJt383i0HNmdY1A4H2EhY0nsC81t00YKw+1J6SBhUkhCl/KwcYL+gNiGYVEaHXg0uFWWmn2GQQC7+MYYbNbf2Vg7dsMwFFA5UDlsl0+3BP+5kNs5mKQficeiYyF/bxZT6SV680rurQPBN5HQFgmTtwY7ox+tBhMFMa6Cagcpuz1xDin3cYDIe+sZ7DXgP5vOl7yCHXeCj8HSm0Dl5tCnj8EMll0jkDgfYSFzTyEkSLgBIs7w8FHe0KGgU7HKzBmYyO0lf/NkriG+7w5Ds3tnd9hQ+ElBoF0MX8UETcl2RRvoamjJ5GuEAi8j/CwcbvnLS1bpASLq8DKYRxBOawtDZ1L4S/xpG09j34jI5GzlBOaVF8ce1t0ZQ4E3kdA8LGnKVhXo9C7etlyD30yn61Szj3u2D+39RwwQpznzUAg8D6OAimdEDiMoBxI3qYB32ezT6bAwhO52rYvdsL/6rdpSA8ZBALvowveRybiTCRX4SqScnBdtukK20PRqJ8WDfv6xsHIWmOUvIPLyiwQCLyPg8UwsfL0Y50T4rhs0xVkydruiqe0L06eCG+EtDWytjj6ttR7dEl3AgKB93GQuEKZ3sEl3YmrInI554OI4MVmTkT+duLovdxjeJT2XYx5MxAIvI+0YJk4MSRGkS3MICQi3iZlXd5DfUaZgolIJmXpM+hv/D/aw+C6Ym6JUVIpJ2txhGejp7yMF95HeFwmWq5ehD0h7yzMEMs2eRnxXQMZ/v/mT/kc38XzfYwurST7qw2PUTsRM6ScLGWdzsQB7zzvCoHA+wjvfdhmwaX+aboi59Kiv+i30Va8sHLI2jZQaHOdfM+5SCtlCXlFU4wSo/4bW2ZxAIHA+zgI2O79mDadUJUDjRIDTkzLZ5jCo23l/kW0v9CA3IcXkc5O5+kxHdPqwwvi/jt4YzbFORB4H3FgE77ytRrHdlI2hdBA6yuzOFQ0SEQulrxpDjDvvx0bAQdPuikSCLyPwODQTG5xy8rTQCNFvG2J5DqtHJQ08zGV0JKXMgNu/XfwYb/UCATeRxzYLpP1uZzThoz6KRzQwx7CRYD3LEW4FWAgj3b6b3bIdUyNQOB9xIFNTN/3ZjLbvQ9XiSiHtQiwbJPb+lSE3QE+A3l4678bccDLn1MiEHgfEcCJE23a787zACNr3oaQhgkph2UI5UBtJC/yRKYteyM0/k67mGY9cUzFgaY7SYlA4H3EgW021jaW0tqExHopnUceMt0J5+c64bGy9UwctH9igFQlrfTbwU6qp7KMF97HftgMbBtBzYR5eGTreTOZSkrnlmUO1T4mIO+ANhn2PPXfPmVEHsINe49n7JXZjgPqc9rH43ODoM0piG1hZ1DvGCSy5YOo9mUB8G0YtIpPijKmQ3TySJ0+cCSQH4kRiFM9AKApeCNon8dDVkNiu1g7tYHDQwoeCLwPAPDjmYAYgKBIYQ4Ecx8AAAAgEHgfAAAAIBB4HwAAAECiBALvAwAAAAQC7wMAAAAEAu8DAAAASJhA4H0AAAB0HDH2gcD7aAGckp2utnaLq+/Y4IChTshETp+HtHGQszH3IIPHSyDwPvwMJEpfQek/cp1Y+TxsGly08/hRXisXUjF4Bz2flNO9bQ4lef+N8rVxOg1WlnlhpLgoTSbIUfFdTSqo/88DtvpBTZzjK3NpE96JPlL6q0weqE2K9CU7x7a9dVXcde1bc49ap57PegHdIxB4H34ssLnYn3amx7+hay7vI2U1M1FKTBxzgzYvzn6eyHuoX8cWSk81COjepl5TXvJMW2TaM25q/td4LIiPmXwvVRI0aRMmDjoW+ErU5+Iqzryn61red2eRdVdtW1Lmp451rmvfMjl/MJBBtV6FnOMI3kAIPQcC76M5eVTlLFsrVxlGfO++dywMB26ZknlJKVPuEcnDtbA7P73HJPLCXoEN+iwjbdbrVZYqZLCQ8U2NnM8hHYfngcD7aA494yu1J1mSqwrlMmSrtmi/qQF5jEoGLKVbX6lWHT8/Z8s3UxTTgkILB3iONimsfeeUz9kjK9q6LqzXyEJWyKOnPZNk4V4P4SnhyJHmQRKJ2KZxH8l7ntvoY/aoHrQ/L1nONxXtcMn1KtriDqri8AgE3kezgTVUlNProKo7NY4H20bed8ttmJURTQ15vJ5hUDWXUDxfXrc8n6H2E5HI9pAmb02SFco6q6Swaav+ipLVjYnKECL3/UreO+N7+xrpDyzDPnTPpoXzQ0ZavcZ1RKXI+YwNrG840+TwCATeR3Oo5LE1PXKUlcI+zyMvIQ9jq5Ri6UQYPIALPMi/nSAW3ZonmpkaE1pfUT+dagZDnw2AqWU5nlro4zNVb5h6OcqhTUBAhJoDgffRHF9UAmlBIamwPpmOB7qqgIrJXcCv90FkP9SUrPX55SUnKU4M50M24lf4jfr4yXMVVe/jGT0OAoH34QfbikHWVCHpp9nNXEMAfOSq2tdX6Dbv0Nt03OBZYweDTbf025xU/4zuBoHA+/CDn9qgHXl67qWmHG499nePCQrwQ/Y9zftotH+GDYWl8qeh4X1rzdsceZTHjfbcPnr+eAkE3oc/6BPgNIk5Z6XSBLn6jqbxbFYuqlI7Q9d5Q659f/TwzEeN8PuG/Xyrkc/Ck7LX60TzLDce5BzoIIHA+/AEtjT1SU6aY/gPhRBcLH0lLYRPhfRqOKjeEnrPG/qaTKw8yNVqD0nVQV+q/NRU0bMBohJTj/XBD1c5B9pDm6uwyJLNpTeRW973Gd5HtdXH6Rv0jVIjdveLpaaPht6EPti3nor6zVEhAfX4UkHSPgg/r5CJOnncSZkjgXzh+3pMIoMmnixN8LMsTzRZVeWciO/RB4kCaRJID55EayRCg2ZRopz1tA70u5lpnNzj+nmsw29vTCXnGTOJPCleEhk444bPncrnPrIOKZNzlUzIY7lrK4koUI3f0ASdJBHaB0K7ok/E26T3tkLZjNj1nyOGDFhEAGzlkTwYfVJ94kHO1yznp0wSuwo5p3e9aAk6ARAIYEAklDjuhAdZFZlMhEF82iPJgKy6i5+unrF4P3cxL9LJe5Bz2vFOO9L/FG/pZMrkvMjvtUAXgkAAt0GmkslS+wmFFvYNLl8T3upzEM7yB7UtM4/P9fUsfVL9wSFZo4lXUsg5kcla+8kInggIBPBgsTGRqJbaUFtqqSv33FMR1KW72whN8MXDM7YJdq3qHWQ+lLNySFhjwlfSiag71R/aCp8qIa6BeB/eukbIFgQCeCAS8TE/0FAb8KrCOPekkFQiipGOwlV55IkTyLqqLxtguOcdtjK31WSumFRvU87XJXKeQwOAQAA/JKIqhT+0n6h7P/oe4tYj7XuoZZaqws8dLdA284356ku1XI1SxXAbqc9Y+0iM2NakusE7txpxASAQwDMy7ftS++5sLXJ47FpTSKEUcSPrnJVpbM/JBPdqXzaM9080ebj3qNA/TKoH8Aq2GN4gEKBd0vhWEnJYal6I9UoWVsD6fbNQFeR6qOE42zg4KdNeBM/JFqSY9Xi/SxaCkUb22xYOiNIn1VvbF1ZiAOAYARAIoA0SyglkNSnJikIlkE3FQN9pIYeF6XuU0/HUsMFthAOl7jTSnFu0karclqmeY8Ll0jfpPdgkM+Rwkk7245bKemGrzFn25g4GQJ1HCoBAjpo8clZyZG3+YDLJ6iwyDm8sNCtzVTHQ9ZUspJBqzzin9/M7XsTHpbuz0G3EFvRaI8KnqnaqaKO9B3AlQCIr8TH0uOC65nUyRL8pIdZpW2RfMqluQuYj8Wtz4GSPnJMMzsXH0CmWjwfA72iCzkCd7CzSxFzzSYB0FTH7IpdYXuFpVA10OhZ0IN6fs52xYlqwYqZB+V9+R1+UT1S+nh1uacE/cY4vGwwqlN5Y/MrNJLgdiHCpXOqCgc9MxrqVe9GFUxQ5X1RB9EKpa84yQfUtwpVn3FdlFv2M5yvaLOtalmlq6BFel3iRc+6/rVKnKhlM3gAAgQAxMObBoXsEWQ1hvBtU+xLPKSSyKBmYudg/CUphq6iDl3MzldWhivDUNrro0jnuTCLPrGR7JTIx3FPfcahkhJzD7UuJ/H4wDER5nrei//bVaQDvIxwQwuqOstgpmwOXwjyuTL89tThbmjYhnjJhbSzecRKbPErqMBP7V+bslPKvOygXr2U3rKvg38y4vqEXCkz3yZSS540u0/IVB6GdgDzC4hOaoLvgeHdVaIKU4aZpOEbZGJiVvaMLSpfbKe9q+S3r2ue69kqUbCfnBmr67yD7sEv4nwADANrGvaMkeEBoAAAAAElFTkSuQmCC"
 
-func NewGenerator() Generator {
-	result := Generator{
-		Head: `
+// fontLinks load the report font. The standalone HTML report leaves them out, it must not depend on other sites.
+const fontLinks = `
 <link rel="preconnect" href="https://fonts.googleapis.com">
 <link rel="preconnect" href="https://fonts.gstatic.com" crossorigin>
 <link href="https://fonts.googleapis.com/css2?family=Montserrat:wght@300;400;700&display=swap" rel="stylesheet">
-<style>
+`
+
+const stylesheet = `<style>
 body {
 font-family: 'Montserrat', sans-serif;
 color: #666;
@@ -108,15 +103,442 @@ small {
 	background-color: red;
 }
 
+.ok {
+	color: green;
+}
+
+.score {
+	font-size: 4em;
+	font-weight: 700;
+	margin: 0;
+}
+
+td, th {
+	padding: 4px 8px;
+}
+
 </style>
-`,
+`
+
+type Generator struct {
+	Head                       string `default:"test"`
+	Title                      string
+	Logo                       string // data URI of the cover image
+	ClientName                 string `default:"Client"`
+	Date                       time.Time
+	SubscriptionId             string
+	SubscriptionName           string
+	OutputFilename             string
+	VirtualMachines            map[string]azure.Resource
+	VirtualMachinesDeallocated map[string]azure.Resource
+	AzureKubernetesServices    map[string]azure.Resource
+	MySQLServers               map[string]azure.Resource
+	FlexibleMySQLServers       map[string]azure.Resource
+	SqlServers                 map[string]azure.Resource
+	StorageAccounts            map[string]azure.Resource
+	WebApps                    map[string]azure.Resource
+	Recommendations            map[string][]azure.AdvisorRecommendation
+	Problems                   []scan.Problem
+	Findings                   []rules.Finding
+	Environments               rules.Environments
+	Scorecards                 []scorecard.Scorecard // one per subscription in the report
+	Breakdown                  []Generator           // consolidated reports: the sections of each subscription
+	Settings                   scan.Settings
+	Changes                    *diff.Changes // nil when there is no earlier scan to compare with
+	Trend                      []history.Point
+}
+
+func NewGenerator() Generator {
+	result := Generator{
+		Title: "Tangent Solutions Managed Services Report",
+		Logo:  defaultLogo,
+		Date:  time.Now(),
+		Head:  fontLinks + stylesheet,
 	}
 
 	return result
 }
 
+var statusClasses = map[string]string{
+	scorecard.Green: "ok",
+	scorecard.Amber: "warn",
+	scorecard.Red:   "danger",
+}
+
+func generateScorecardTable(scorecards []scorecard.Scorecard) string {
+	output := "<table style='width: 100%; border-collapse: collapse;'>"
+	output += "<tr class='bg-grey'><th style='text-align: left;'>Subscription</th>"
+	for _, metric := range scorecards[0].Metrics() {
+		output += fmt.Sprintf("<th>%s</th>", metric.Name)
+	}
+	output += "<th>Status</th></tr>"
+	for _, card := range scorecards {
+		output += fmt.Sprintf("<tr><td>%s</td>", html.EscapeString(orDefault(card.SubscriptionName, card.SubscriptionId)))
+		for _, metric := range card.Metrics() {
+			output += fmt.Sprintf("<td style='text-align: center;' class='%s'>%s</td>", statusClasses[metric.Status], metric.Value)
+		}
+		output += fmt.Sprintf("<td style='text-align: center;' class='%s'><strong>%s</strong></td></tr>", statusClasses[card.Status], orDefault(card.Status, "n/a"))
+	}
+	output += "</table><br />"
+
+	return output
+}
+
+// GenerateSummarySection renders the scorecards as a one page executive summary. A consolidated report leads with
+// the totals of all its subscriptions.
+func (g Generator) GenerateSummarySection() string {
+	if len(g.Scorecards) == 0 {
+		return ""
+	}
+
+	headline := g.Scorecards[0]
+	scorecards := g.Scorecards
+	if len(g.Scorecards) > 1 {
+		headline = scorecard.Combine("All subscriptions", g.Scorecards)
+		scorecards = append(append([]scorecard.Scorecard{}, g.Scorecards...), headline)
+	}
+
+	output := "<div class='page-break-before page-break-avoid'>"
+	output += "<h2>Executive Summary</h2>"
+	if headline.Score != nil {
+		output += fmt.Sprintf("<p class='score %s'>%.0f</p>", statusClasses[headline.Status], *headline.Score)
+		output += fmt.Sprintf("<strong>Overall score out of 100: <span class='%s'>%s</span></strong><br /><br />", statusClasses[headline.Status], headline.Status)
+	}
+
+	output += generateScorecardTable(scorecards)
+
+	open := make(map[string]int)
+	accepted := 0
+	for _, finding := range g.Findings {
+		if finding.Accepted() {
+			accepted++
+		} else {
+			open[finding.Severity]++
+		}
+	}
+	var counts []string
+	for _, severity := range rules.Severities {
+		counts = append(counts, fmt.Sprintf("<span class='%s'>%d %s</span>", severityClasses[severity], open[severity], severity))
+	}
+	output += fmt.Sprintf("<strong>Open findings:</strong> %s. %d accepted risks.<br />", strings.Join(counts, ", "), accepted)
+
+	changes := []*diff.Changes{g.Changes}
+	for _, subscription := range g.Breakdown {
+		changes = append(changes, subscription.Changes)
+	}
+	changeCounts := make(map[string]int)
+	for _, c := range changes {
+		if c != nil {
+			for _, change := range []string{diff.Regressed, diff.Added, diff.Fixed} {
+				changeCounts[change] += c.Count(change)
+			}
+		}
+	}
+	if changeCounts[diff.Regressed]+changeCounts[diff.Added]+changeCounts[diff.Fixed] > 0 {
+		output += fmt.Sprintf("<strong>Since the last review:</strong> <span class='danger'>%d regressed</span>, <span class='warn'>%d added</span>, <span class='ok'>%d fixed</span>.<br />",
+			changeCounts[diff.Regressed], changeCounts[diff.Added], changeCounts[diff.Fixed])
+	}
+
+	output += fmt.Sprintf("<br /><small>%s</small>", scorecard.Explanation())
+	output += "</div>" // page break before
+
+	return output
+}
+
+func orDefault(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+
+	return value
+}
+
+func (g Generator) GenerateProblemsSection() string {
+	if len(g.Problems) == 0 {
+		return ""
+	}
+
+	output := "<div class='page-break-before'>"
+	output += "<h2>Collection Problems</h2>"
+	output += "The following checks could not be completed. The results in this report are incomplete for these items.<br /><br />"
+	for _, problem := range g.Problems {
+		output += "<div class='mb-1 page-break-avoid bg-grey p-1'>"
+		output += fmt.Sprintf("<strong>Could not check %s</strong><br />", html.EscapeString(problem.Check))
+		output += fmt.Sprintf("<span>Reason:</span> <span class='danger'>%s</span><br />", html.EscapeString(string(problem.Kind)))
+		output += fmt.Sprintf("<small>%s</small>", html.EscapeString(problem.Message))
+		if problem.Hint != "" {
+			output += fmt.Sprintf("<br /><strong>Action to be performed:</strong> %s", html.EscapeString(problem.Hint))
+		}
+		output += "</div>" // page break avoid
+	}
+	output += "</div>" // page break before
+
+	return output
+}
+
+var changeClasses = map[string]string{
+	diff.Regressed: "danger",
+	diff.Added:     "warn",
+	diff.Fixed:     "ok",
+	diff.Removed:   "",
+}
+
+var changeLabels = map[string]string{
+	diff.Regressed: "Regressed",
+	diff.Added:     "New",
+	diff.Fixed:     "Fixed",
+	diff.Removed:   "Removed",
+}
+
+func (g Generator) GenerateChangesSection() string {
+	if g.Changes == nil {
+		return ""
+	}
+
+	changes := *g.Changes
+	output := "<div class='page-break-before'>"
+	output += "<h2>Changes since last review</h2>"
+	output += fmt.Sprintf("Compared with the scan of %d-%d-%d.<br /><br />", changes.Before.Year(), changes.Before.Month(), changes.Before.Day())
+	if changes.Empty() {
+		output += "No resources or findings changed since the last review."
+		output += "</div>" // page break before
+		return output
+	}
+
+	output += fmt.Sprintf("<strong><span class='danger'>%d regressed</span>, <span class='warn'>%d added</span>, <span class='ok'>%d fixed</span>, %d removed.</strong><br />",
+		changes.Count(diff.Regressed), changes.Count(diff.Added), changes.Count(diff.Fixed), changes.Count(diff.Removed))
+
+	if len(changes.Resources) > 0 {
+		output += "<h3>Resources</h3>"
+		for _, change := range changes.Resources {
+			output += fmt.Sprintf("<span class='%s'>%s</span> %s: %s<br />", changeClasses[change.Change], change.Change, html.EscapeString(scan.Descriptions[change.ResourceType]), html.EscapeString(change.Resource.Name))
+		}
+	}
+
+	if len(changes.Findings) > 0 {
+		output += "<h3>Findings</h3>"
+		for _, change := range changes.Findings {
+			output += "<div class='mb-1 page-break-avoid bg-grey p-1'>"
+			output += fmt.Sprintf("<strong class='%s'>%s</strong> %s<br />", changeClasses[change.Change], changeLabels[change.Change], html.EscapeString(change.Finding.Message))
+			output += fmt.Sprintf("<small>Resource: %s</small>", html.EscapeString(change.Finding.ResourceName))
+			output += "</div>" // page break avoid
+		}
+	}
+
+	if changes.ProblemsBefore != changes.ProblemsAfter {
+		output += fmt.Sprintf("<br />Checks that could not be completed: %d last time, %d now.", changes.ProblemsBefore, changes.ProblemsAfter)
+	}
+	output += "</div>" // page break before
+
+	return output
+}
+
+func (g Generator) GenerateTrendsSection() string {
+	// A single scan is not a trend.
+	if len(g.Trend) < 2 {
+		return ""
+	}
+
+	labels := make([]string, len(g.Trend))
+	alertCoverage := chartSeries{Name: "Resources with alert rules"}
+	backupCoverage := chartSeries{Name: "VMs backed up"}
+	criticalPatches := chartSeries{Name: "Critical and security patches"}
+	for i, point := range g.Trend {
+		labels[i] = fmt.Sprintf("%d-%d-%d", point.Time.Year(), point.Time.Month(), point.Time.Day())
+		alertCoverage.Values = append(alertCoverage.Values, point.AlertCoverage)
+		backupCoverage.Values = append(backupCoverage.Values, point.BackupCoverage)
+		var patches *float64
+		if point.CriticalPatches != nil {
+			value := float64(*point.CriticalPatches)
+			patches = &value
+		}
+		criticalPatches.Values = append(criticalPatches.Values, patches)
+	}
+
+	var recommendations []chartSeries
+	for _, category := range history.Categories(g.Trend) {
+		series := chartSeries{Name: category}
+		for _, point := range g.Trend {
+			var value *float64
+			if point.Recommendations != nil {
+				count := float64(point.Recommendations[category])
+				value = &count
+			}
+			series.Values = append(series.Values, value)
+		}
+		recommendations = append(recommendations, series)
+	}
+
+	output := "<div class='page-break-before'>"
+	output += "<h2>Trends</h2>"
+	output += fmt.Sprintf("How this subscription has changed over the last %d reviews.<br />", len(g.Trend))
+	output += "<div class='page-break-avoid'><h3>Monitoring and backup coverage</h3>"
+	output += lineChart(labels, []chartSeries{alertCoverage, backupCoverage}, 100, "%")
+	output += "</div>" // page break avoid
+	output += "<div class='page-break-avoid'><h3>Outstanding critical and security patches</h3>"
+	output += lineChart(labels, []chartSeries{criticalPatches}, 0, "")
+	output += "</div>" // page break avoid
+	if len(recommendations) > 0 {
+		output += "<div class='page-break-avoid'><h3>Advisor recommendations by category</h3>"
+		output += lineChart(labels, recommendations, 0, "")
+		output += "</div>" // page break avoid
+	}
+	output += "</div>" // page break before
+
+	return output
+}
+
+var severityClasses = map[string]string{
+	rules.SeverityHigh:   "danger",
+	rules.SeverityMedium: "warn",
+	rules.SeverityLow:    "",
+	rules.SeverityInfo:   "",
+}
+
+// groupByEnvironment sorts resources by name and groups them by environment. Without an environment mapping all
+// resources are in a single group without a name.
+func (g Generator) groupByEnvironment(resources map[string]azure.Resource) ([]string, map[string][]azure.Resource) {
+	sorted := make([]azure.Resource, 0, len(resources))
+	for _, resource := range resources {
+		sorted = append(sorted, resource)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return strings.ToLower(sorted[i].Name) < strings.ToLower(sorted[j].Name)
+	})
+
+	groups := make(map[string][]azure.Resource)
+	for _, resource := range sorted {
+		environment := ""
+		if g.Environments.Configured() {
+			environment = g.Environments.Classify(resource.Tags)
+		}
+		groups[environment] = append(groups[environment], resource)
+	}
+
+	var names []string
+	for _, environment := range append([]string{""}, rules.EnvironmentNames...) {
+		if len(groups[environment]) > 0 {
+			names = append(names, environment)
+		}
+	}
+
+	return names, groups
+}
+
+func generateEnvironmentHeading(environment string) string {
+	if environment == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("<h3 class='bg-grey p-1'>%s%s</h3>", strings.ToUpper(environment[:1]), environment[1:])
+}
+
+// generateFinding renders a finding with the action to be performed about it, or the waiver that accepts it.
+func generateFinding(finding rules.Finding) string {
+	if finding.Accepted() {
+		output := fmt.Sprintf("<span class='ok'>Accepted risk:</span> %s <small>(%s, %s)</small><br />", html.EscapeString(finding.Message), html.EscapeString(finding.RuleId), html.EscapeString(finding.Severity))
+		output += fmt.Sprintf("<strong>Justification: </strong>%s<br />", html.EscapeString(finding.Waiver.Justification))
+		output += fmt.Sprintf("<small>Approved by %s until %s.</small>", html.EscapeString(finding.Waiver.Approver), html.EscapeString(finding.Waiver.Expires))
+		return output
+	}
+
+	output := fmt.Sprintf("<span class='%s'>%s</span> <small>(%s, %s)</small><br />", severityClasses[finding.Severity], html.EscapeString(finding.Message), html.EscapeString(finding.RuleId), html.EscapeString(finding.Severity))
+	output += fmt.Sprintf("<strong>Action to be performed: </strong>%s", html.EscapeString(finding.Remediation))
+	if finding.Status() == rules.StatusWaiverExpired {
+		output += fmt.Sprintf("<br /><span class='danger'>The waiver for this finding expired on %s.</span> <small>It was accepted by %s: %s</small>",
+			html.EscapeString(finding.Waiver.Expires), html.EscapeString(finding.Waiver.Approver), html.EscapeString(finding.Waiver.Justification))
+	}
+
+	return output
+}
+
+// GenerateFindingsSection summarises the findings of every rule by severity.
+func (g Generator) GenerateFindingsSection() string {
+	if len(g.Findings) == 0 {
+		return ""
+	}
+
+	var ruleIds []string
+	var accepted, expired []rules.Finding
+	titles := make(map[string]string)
+	counts := make(map[string]map[string]int)
+	for _, finding := range g.Findings {
+		if counts[finding.RuleId] == nil {
+			ruleIds = append(ruleIds, finding.RuleId)
+			titles[finding.RuleId] = finding.Title
+			counts[finding.RuleId] = make(map[string]int)
+		}
+		switch finding.Status() {
+		case rules.StatusAccepted:
+			counts[finding.RuleId][rules.StatusAccepted]++
+			accepted = append(accepted, finding)
+		case rules.StatusWaiverExpired:
+			counts[finding.RuleId][finding.Severity]++
+			expired = append(expired, finding)
+		default:
+			counts[finding.RuleId][finding.Severity]++
+		}
+	}
+	sort.Strings(ruleIds)
+
+	output := "<div class='page-break-before'>"
+	output += "<h2>Findings</h2>"
+	output += fmt.Sprintf("%d findings across all checks. The sections that follow describe each of them.<br /><br />", len(g.Findings))
+	if g.Environments.Configured() {
+		environments := make(map[string]int)
+		for _, finding := range g.Findings {
+			environments[finding.Environment]++
+		}
+		var parts []string
+		for _, environment := range rules.EnvironmentNames {
+			parts = append(parts, fmt.Sprintf("%d %s", environments[environment], environment))
+		}
+		output += fmt.Sprintf("By environment: %s.<br /><br />", strings.Join(parts, ", "))
+	}
+	output += "<table style='width: 100%; border-collapse: collapse;'>"
+	output += "<tr class='bg-grey'><th style='text-align: left;'>Rule</th><th style='text-align: left;'>Description</th>"
+	for _, severity := range rules.Severities {
+		output += fmt.Sprintf("<th class='%s'>%s</th>", severityClasses[severity], severity)
+	}
+	output += fmt.Sprintf("<th class='ok'>%s</th>", rules.StatusAccepted)
+	output += "</tr>"
+	for _, ruleId := range ruleIds {
+		output += fmt.Sprintf("<tr><td>%s</td><td>%s</td>", html.EscapeString(ruleId), html.EscapeString(titles[ruleId]))
+		for _, column := range append(append([]string{}, rules.Severities...), rules.StatusAccepted) {
+			output += fmt.Sprintf("<td style='text-align: center;'>%d</td>", counts[ruleId][column])
+		}
+		output += "</tr>"
+	}
+	output += "</table>"
+
+	if len(expired) > 0 {
+		output += "<h3>Expired waivers</h3>"
+		output += "These findings were accepted as a risk, but the waiver has expired. They are reported again until the waiver is renewed or the finding is resolved.<br /><br />"
+		for _, finding := range expired {
+			output += "<div class='mb-1 page-break-avoid bg-grey p-1'>"
+			output += fmt.Sprintf("<strong>%s</strong> %s<br />", html.EscapeString(finding.ResourceName), html.EscapeString(finding.Message))
+			output += fmt.Sprintf("<span class='danger'>Expired on %s.</span> <small>Accepted by %s: %s</small>", html.EscapeString(finding.Waiver.Expires), html.EscapeString(finding.Waiver.Approver), html.EscapeString(finding.Waiver.Justification))
+			output += "</div>" // page break avoid
+		}
+	}
+
+	if len(accepted) > 0 {
+		output += "<h3>Accepted risks</h3>"
+		for _, finding := range accepted {
+			output += "<div class='mb-1 page-break-avoid bg-grey p-1'>"
+			output += fmt.Sprintf("<strong>%s</strong> %s <small>(%s, %s)</small><br />", html.EscapeString(finding.ResourceName), html.EscapeString(finding.Message), html.EscapeString(finding.RuleId), html.EscapeString(finding.Severity))
+			output += fmt.Sprintf("<strong>Justification: </strong>%s<br />", html.EscapeString(finding.Waiver.Justification))
+			output += fmt.Sprintf("<small>Approved by %s until %s.</small>", html.EscapeString(finding.Waiver.Approver), html.EscapeString(finding.Waiver.Expires))
+			output += "</div>" // page break avoid
+		}
+	}
+	output += "</div>" // page break before
+
+	return output
+}
+
 func (g Generator) GenerateAlertRulesSection(title string, resources map[string]azure.Resource) string {
-	if len(resources) == 0 {
+	if len(resources) == 0 || !g.Settings.Enabled(scan.CheckAlertRules) {
 		return ""
 	}
 
@@ -126,51 +548,81 @@ func (g Generator) GenerateAlertRulesSection(title string, resources map[string]
 		output += "No resources of this type."
 		return output
 	}
-	for _, resource := range resources {
-		output += "<div class='page-break-avoid'>"
-		output += fmt.Sprintf("<h3>%s</h3>", resource.Name)
-		if len(resource.AlertRules) == 0 {
-			output += "<span class='danger'>No alert rules are configured for this resource.</span><br />"
-			output += "<strong>Action to be performed: </strong>If this alert is used in production, create resource alert rules. We do not monitor non-production resources."
-		} else {
-			output += fmt.Sprintf("This resource has %d alert rules configured:<br /><br />", len(resource.AlertRules))
-			output += "<div class='bg-grey p-1'>"
-			for _, rule := range resource.AlertRules {
-				output += "<small>"
-				output += fmt.Sprintf("<strong>Rule: %s: </strong><br />", rule.Name)
-				for _, criterion := range rule.Criteria.AllOf {
-					output += fmt.Sprintf("<strong>Criteria: </strong>%s %s %s %s<br /><br />", criterion.TimeAggregation,
-						criterion.MetricName,
-						criterion.Operator,
-						fmt.Sprintf("%.2f", criterion.Threshold),
-					)
-				}
-				output += "</small>"
-			}
-			output += "<strong>Action to be performed:</strong> Review alert rules and confirm that they are appropriate for this resource."
-			output += "</div>" // background grey
+	environments, groups := g.groupByEnvironment(resources)
+	for _, environment := range environments {
+		output += generateEnvironmentHeading(environment)
+		for _, resource := range groups[environment] {
+			output += g.generateResourceAlerts(resource, environment)
 		}
-		output += "</div>" // page break avoid
 	}
 	output += "</div>" // page break before
 
 	return output
 }
 
+func (g Generator) generateResourceAlerts(resource azure.Resource, environment string) string {
+	output := "<div class='page-break-avoid'>"
+	output += fmt.Sprintf("<h3>%s</h3>", html.EscapeString(resource.Name))
+	findings := rules.ForResource(g.Findings, rules.RuleNoAlertRules, resource.Id)
+	if len(findings) > 0 {
+		output += generateFinding(findings[0])
+	} else if len(resource.AlertRules) == 0 && environment == rules.EnvironmentNonProduction {
+		output += "This is a non-production resource, it does not need alert rules."
+	} else if len(resource.AlertRules) == 0 {
+		output += "Alert rules could not be checked for this resource."
+	} else {
+		output += fmt.Sprintf("This resource has %d alert rules configured:<br /><br />", len(resource.AlertRules))
+		output += "<div class='bg-grey p-1'>"
+		for _, rule := range resource.AlertRules {
+			output += "<small>"
+			output += fmt.Sprintf("<strong>Rule: %s: </strong><br />", html.EscapeString(rule.Name))
+			for _, criterion := range rule.Criteria.AllOf {
+				output += fmt.Sprintf("<strong>Criteria: </strong>%s %s %s %s<br /><br />", html.EscapeString(criterion.TimeAggregation),
+					html.EscapeString(criterion.MetricName),
+					html.EscapeString(criterion.Operator),
+					fmt.Sprintf("%.2f", criterion.Threshold),
+				)
+			}
+			output += "</small>"
+		}
+		output += "<strong>Action to be performed:</strong> Review alert rules and confirm that they are appropriate for this resource."
+		output += "</div>" // background grey
+	}
+	output += "</div>" // page break avoid
+
+	return output
+}
+
+// notChecked is shown for a resource a check could not be completed for, instead of a result.
+const notChecked = "This could not be checked, see Collection Problems."
+
+// failed reports whether a check could not be completed for a single resource.
+func (g Generator) failed(check string, resourceId string) bool {
+	return scan.Result{Problems: g.Problems}.Failed(check, resourceId)
+}
+
 func (g Generator) GenerateBackupsSection() string {
-	if len(g.VirtualMachines) == 0 {
+	if len(g.VirtualMachines) == 0 || !g.Settings.Enabled(scan.CheckBackups) {
 		return ""
 	}
 	output := "<div class='page-break-before'>"
 	output += fmt.Sprintf("<h2>Virtual Machine Backups</h2>")
-	for _, vm := range g.VirtualMachines {
-		output += fmt.Sprintf("<h3>%s</h3>", vm.Name)
-		if vm.BackupVault != nil {
-			output += fmt.Sprintf("This virtual machine is backed up to %s.<br />", vm.BackupVault.Name)
-			output += fmt.Sprintf("<strong>Action to be performed:</strong> None")
-		} else {
-			output += fmt.Sprintf("<span class='danger'>This virtual machine is not backed up.</span><br />")
-			output += fmt.Sprintf("<strong>Action to be performed:</strong> If this is a production machine, consider setting up backups using Azure Backup Vault. If an alternative backup solution is being used, this recommendation can be ignored.")
+	environments, groups := g.groupByEnvironment(g.VirtualMachines)
+	for _, environment := range environments {
+		output += generateEnvironmentHeading(environment)
+		for _, vm := range groups[environment] {
+			output += fmt.Sprintf("<h3>%s</h3>", html.EscapeString(vm.Name))
+			findings := rules.ForResource(g.Findings, rules.RuleNotBackedUp, vm.Id)
+			if g.failed(scan.CheckBackups, vm.Id) {
+				output += notChecked
+			} else if len(findings) > 0 {
+				output += generateFinding(findings[0])
+			} else if vm.BackupVault != nil {
+				output += fmt.Sprintf("This virtual machine is backed up to %s.<br />", html.EscapeString(vm.BackupVault.Name))
+				output += fmt.Sprintf("<strong>Action to be performed:</strong> None")
+			} else if environment == rules.EnvironmentNonProduction {
+				output += "This is a non-production machine, it does not need to be backed up."
+			}
 		}
 	}
 	output += "</div>" // page break before
@@ -184,8 +636,8 @@ func (g Generator) GenerateDeallocatedVMsSection() string {
 	}
 	output := "<div class='page-break-before'>"
 	output += fmt.Sprintf("<h2>Deallocated Virtual Machines</h2>")
-	for _, vm := range g.VirtualMachines {
-		output += fmt.Sprintf("<h3>%s</h3>", vm.Name)
+	for _, vm := range g.VirtualMachinesDeallocated {
+		output += fmt.Sprintf("<h3>%s</h3>", html.EscapeString(vm.Name))
 	}
 	output += "</div>" // page break before
 
@@ -193,22 +645,30 @@ func (g Generator) GenerateDeallocatedVMsSection() string {
 }
 
 func (g Generator) GeneratePatchesSection() string {
-	if len(g.VirtualMachines) == 0 {
+	if len(g.VirtualMachines) == 0 || !g.Settings.Enabled(scan.CheckPatches) {
 		return ""
 	}
 	output := "<div class='page-break-before'>"
 	output += fmt.Sprintf("<h2>Virtual Machine Patches</h2>")
-	for _, vm := range g.VirtualMachines {
-		output += fmt.Sprintf("<h3>%s</h3>", vm.Name)
-		output += fmt.Sprintf("<span class='mb-1'>%d patches available.", len(vm.PatchAssessmentResult.AvailablePatches))
-		for _, patch := range vm.PatchAssessmentResult.AvailablePatches {
-			output += "<div class='mb-1 page-break-avoid bg-grey p-1'>"
-			output += fmt.Sprintf("<strong>Patch Name: %s</strong><br />", patch.Name)
-			output += fmt.Sprintf("<strong>Patch ID: %s</strong><br />", patch.PatchId)
-			output += fmt.Sprintf("<strong>KB ID: %s</strong><br />", patch.KbId)
-			output += fmt.Sprintf("<strong>Version: %s</strong><br />", patch.Version)
-			output += fmt.Sprintf("<strong>Reboot: %s</strong><br />", patch.RebootBehavior)
-			output += "</div>" // page break avoid
+	environments, groups := g.groupByEnvironment(g.VirtualMachines)
+	for _, environment := range environments {
+		output += generateEnvironmentHeading(environment)
+		for _, vm := range groups[environment] {
+			output += fmt.Sprintf("<h3>%s</h3>", html.EscapeString(vm.Name))
+			if g.failed(scan.CheckPatches, vm.Id) {
+				output += notChecked
+				continue
+			}
+			output += fmt.Sprintf("<span class='mb-1'>%d patches available.", len(vm.PatchAssessmentResult.AvailablePatches))
+			for _, patch := range vm.PatchAssessmentResult.AvailablePatches {
+				output += "<div class='mb-1 page-break-avoid bg-grey p-1'>"
+				output += fmt.Sprintf("<strong>Patch Name: %s</strong><br />", html.EscapeString(patch.Name))
+				output += fmt.Sprintf("<strong>Patch ID: %s</strong><br />", html.EscapeString(patch.PatchId))
+				output += fmt.Sprintf("<strong>KB ID: %s</strong><br />", html.EscapeString(patch.KbId))
+				output += fmt.Sprintf("<strong>Version: %s</strong><br />", html.EscapeString(patch.Version))
+				output += fmt.Sprintf("<strong>Reboot: %s</strong><br />", html.EscapeString(patch.RebootBehavior))
+				output += "</div>" // page break avoid
+			}
 		}
 	}
 	output += "</div>" // page break before
@@ -217,9 +677,13 @@ func (g Generator) GeneratePatchesSection() string {
 }
 
 func (g Generator) GenerateRecommendationsSections() string {
+	if !g.Settings.Enabled(scan.CheckRecommendations) {
+		return ""
+	}
+
 	output := "<div class='page-break-before'>"
 	for category, categoryRecommendations := range g.Recommendations {
-		output += fmt.Sprintf("<h2>Advisory Recommendations: %s</h2>", category)
+		output += fmt.Sprintf("<h2>Advisory Recommendations: %s</h2>", html.EscapeString(category))
 
 		if len(categoryRecommendations) == 0 {
 			output += "No recommendations in this category. Looking good!"
@@ -235,11 +699,11 @@ func (g Generator) GenerateRecommendationsSections() string {
 				color = "danger"
 			}
 			output += "<div class='mb-1 page-break-avoid bg-grey p-1'>"
-			output += fmt.Sprintf("<strong>%s</strong><br />", rec.Description.Problem)
-			output += fmt.Sprintf("<span>Impact:</span> <span class='%s'>%s</span><br />", color, rec.Impact)
-			output += fmt.Sprintf("<span>Resource Type:</span> %s<br />", rec.ResourceType)
-			output += fmt.Sprintf("<span>Affected Resource:</span> %s<br />", rec.AffectedResource)
-			output += fmt.Sprintf("<span>Resource Group:</span> %s", rec.ResourceGroup)
+			output += fmt.Sprintf("<strong>%s</strong><br />", html.EscapeString(rec.Description.Problem))
+			output += fmt.Sprintf("<span>Impact:</span> <span class='%s'>%s</span><br />", color, html.EscapeString(rec.Impact))
+			output += fmt.Sprintf("<span>Resource Type:</span> %s<br />", html.EscapeString(rec.ResourceType))
+			output += fmt.Sprintf("<span>Affected Resource:</span> %s<br />", html.EscapeString(rec.AffectedResource))
+			output += fmt.Sprintf("<span>Resource Group:</span> %s", html.EscapeString(rec.ResourceGroup))
 			output += "</div>" // page break avoid
 		}
 	}
@@ -248,65 +712,113 @@ func (g Generator) GenerateRecommendationsSections() string {
 	return output
 }
 
-func (g Generator) GeneratePDF() error {
-	pdfGenerator, err := wkhtml.NewPDFGenerator()
-	if err != nil {
-		return err
+// generateSections renders the sections of a subscription, everything after the cover and the summary.
+func (g Generator) generateSections() string {
+	sections := []string{
+		g.GenerateProblemsSection(),
+		g.GenerateChangesSection(),
+		g.GenerateTrendsSection(),
+		g.GenerateFindingsSection(),
+		g.GenerateAlertRulesSection("Virtual Machines", g.VirtualMachines),
+		g.GenerateAlertRulesSection("Azure Kubernetes Services", g.AzureKubernetesServices),
+		g.GenerateAlertRulesSection("MySQL Servers", g.MySQLServers),
+		g.GenerateAlertRulesSection("Flexible MySQL Servers", g.FlexibleMySQLServers),
+		g.GenerateAlertRulesSection("SQL Servers", g.SqlServers),
+		g.GenerateAlertRulesSection("Storage Accounts", g.StorageAccounts),
+		g.GenerateAlertRulesSection("Web Apps", g.WebApps),
+		g.GenerateBackupsSection(),
+		g.GenerateDeallocatedVMsSection(),
+		g.GenerateRecommendationsSections(),
+		g.GeneratePatchesSection(),
+	}
+
+	return strings.Join(sections, "\n")
+}
+
+// GenerateBreakdownSection renders the sections of every subscription of a consolidated report, each after a page
+// with the name and scorecard of the subscription.
+func (g Generator) GenerateBreakdownSection() string {
+	output := ""
+	for i, subscription := range g.Breakdown {
+		output += "<div class='page-break-before'>"
+		output += fmt.Sprintf("<h1>%s</h1>", html.EscapeString(orDefault(subscription.SubscriptionName, subscription.SubscriptionId)))
+		output += fmt.Sprintf("<h3>Subscription ID: %s</h3><br />", html.EscapeString(subscription.SubscriptionId))
+		if i < len(g.Scorecards) {
+			output += generateScorecardTable(g.Scorecards[i : i+1])
+		}
+		output += "</div>" // page break before
+		output += subscription.generateSections()
+	}
+
+	return output
+}
+
+// generateCoverSubscriptions names the subscription on the cover, or every subscription of a consolidated report.
+func (g Generator) generateCoverSubscriptions() string {
+	if len(g.Breakdown) == 0 {
+		return fmt.Sprintf("<h3>%s</h3><h3>Subscription ID: %s</h3>", html.EscapeString(g.SubscriptionName), html.EscapeString(g.SubscriptionId))
+	}
+
+	output := fmt.Sprintf("<h3>%d subscriptions</h3><p>", len(g.Breakdown))
+	for _, subscription := range g.Breakdown {
+		output += fmt.Sprintf("%s<br />", html.EscapeString(orDefault(subscription.SubscriptionName, subscription.SubscriptionId)))
 	}
-	htmlStr := `<html>
+	output += "</p>"
+
+	return output
+}
+
+// GenerateHTML renders the whole report as an HTML document with the given head, e.g. g.Head.
+func (g Generator) GenerateHTML(head string) string {
+	htmlStr := `<!DOCTYPE html>
+<html>
 <head>
 {headContent}
 </head>
 <body>
 <div style="margin-top: 320px; text-align: center;">
-<img height="150px; margin-top: " src="data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAAZAAAABVCAYAAABn7bJ/AAAAGXRFWHRTb2Z0d2FyZQBBZG9iZSBJbWFnZVJlYWR5ccllPAAAEWBJREFUeNrsXT1y4zoSxrx6+dM7wdDJpiOfwNSGm1iumtzSCSydwNYJZJ9Acr5VlpMN1/IJrEknGc0JVnuCt2i7uQPDJAWAIABK31fFUskWSfw0+utuAA0hAAAAAAAAAAAAQuETmgAAuo2//+OiLz9yeX2WV5//nGs/W/PnVl4/6fu///WwRusBXgjk6/fnpxKhA+ww+OffztYGA57a+SmhcpMyGTgqL5e6/Cnft/OsRPfJL71vIN+78fhOm7o7t3ENaVzKayivrMGjVvJ6pM+mfZKIXA/qiFGW8a899xPBnvqUT/nOG/lxbfjzmXz3TcNnhMBrOX+Dzgca4tLhnmGEcvbk9SAHYq/j3kbOZPkir0lD8ij6YiGvH/K58663jwdkiRl3SQMEAjRRZj1HMriCcrBu60xeD1z+NiIFPSakH2ztHjP6sg0WGOEgEKBdDFnxuAzQLFKZO6ccZHmH7HGE8NyoP6/Jyzlyb2QEIgWBAO3issG9VxHLTcph0hHyoHI+OBJ1E+QCoRwi0hGGOQgE8K/YMtEslDKMXIU5W/YptzF5SvOIRdhA0l/lpI9mAIEAftHUg8gSUOCLVJUDW74xrV8ijynE/NXze8LiAhAI4Bc+lP9l5DokuTKLl8PGnKehJaxj30utQSIgEAAoJnUzHySUwKCkejwl1LavpBa5GGOf+2UOBOSpztEM7/E7miAKtvKaOVjrpkp7Kd52G9uUJ5bnMJLXbWzlQPMNUmmOE5CNuWg+Yb7hq5CBL/zM3ODeW9kOq0Bj4L6l57YFWnzxs2yjXwCsHe6x2Xg4cykPCCQCpACSkFsJoRTcMwsCuW8rTUWDvR91ZHSbQLfEVA5F22bCfd6DZOqOjIe60BN7j+cV76Hd8qHmPbYx27oBaGUWlX0ZWGesbUlElvPa4vlOfYEQFuDiMfi2/vsJKYdRzPc73kcETOk3bvfNW5B3wZ7WiXhLY1KA7ruAeJt5iViZBQIB3D0G3y73ZUL1i6IcGngfNF8xtZ3wJi9YXhdMGkWeMEyamwGT6iAQwEHJkWI1Va53FiQygnJwCgtOm4ZSeL7jBJPmIBEXHMIcCAn+o8N95xbKEHiD6d6PHSkmHly5yWCk0FHouLKBcghpldt6YRSK8jJ3BM/DGcXKrPGxNsAhEMjUJIW6iq/fn3sibiqNrsLUSl4pn6b7GYjQl8eoHDh8ZWvMYJNfGoi++CImuh7CWtuSh6IIsSnITsmNLNrsXrFsTZeEDiMmWKxTDiEUQ275+yWv5APSwNHmzOq6BzJz7XDIvDVMQyxbbQnxo4XnQr+7TVA5tL1s05Y47w5Annq8474pdonM39Dii82xzSV1mUCcvI+v359Hws8u6mPyPjILK3lV8t00jHWVIIGEUA5nvhVmm6cDyvf7OAq776l8pAMGCchIMW92ckxzSl0OYcH7CAcb9/xeUzY2YazMk1VqS3KmyiGFsCdWSwU0UjssJyAQeB/JwCZ8VabgHlt4VxO87thOSDnYkOYzxDEYLhwI+6hyZnWVQOB9BAJ7BKaku/Jg8YdKsDiFcgDqwN4zrcKzDUnR4oujkJMuEgi8jzS9j8KyrxqIpiTiO9dWnXIYOCqHG4jF0ZDIRrgt5Z4cw8qsLhIIvI9w3gcpc9NBsNmztDS1MJZKItayhKNOj4pEVsJt383i0HNmdY1A4H2EhY0nsC81t00YKw+1J6SBhUkhCl/KwcYL+gNiGYVEaHXg0uFWWmn2GQQC7+MYYbNbf2Vg7dsMwFFA5UDlsl0+3BP+5kNs5mKQficeiYyF/bxZT6SV680rurQPBN5HQFgmTtwY7ox+tBhMFMa6Cagcpuz1xDin3cYDIe+sZ7DXgP5vOl7yCHXeCj8HSm0Dl5tCnj8EMll0jkDgfYSFzTyEkSLgBIs7w8FHe0KGgU7HKzBmYyO0lf/NkriG+7w5Ds3tnd9hQ+ElBoF0MX8UETcl2RRvoamjJ5GuEAi8j/CwcbvnLS1bpASLq8DKYRxBOawtDZ1L4S/xpG09j34jI5GzlBOaVF8ce1t0ZQ4E3kdA8LGnKVhXo9C7etlyD30yn61Szj3u2D+39RwwQpznzUAg8D6OAimdEDiMoBxI3qYB32ezT6bAwhO52rYvdsL/6rdpSA8ZBALvowveRybiTCRX4SqScnBdtukK20PRqJ8WDfv6xsHIWmOUvIPLyiwQCLyPg8UwsfL0Y50T4rhs0xVkydruiqe0L06eCG+EtDWytjj6ttR7dEl3AgKB93GQuEKZ3sEl3YmrInI554OI4MVmTkT+duLovdxjeJT2XYx5MxAIvI+0YJk4MSRGkS3MICQi3iZlXd5DfUaZgolIJmXpM+hv/D/aw+C6Ym6JUVIpJ2txhGejp7yMF95HeFwmWq5ehD0h7yzMEMs2eRnxXQMZ/v/mT/kc38XzfYwurST7qw2PUTsRM6ScLGWdzsQB7zzvCoHA+wjvfdhmwaX+aboi59Kiv+i30Va8sHLI2jZQaHOdfM+5SCtlCXlFU4wSo/4bW2ZxAIHA+zgI2O79mDadUJUDjRIDTkzLZ5jCo23l/kW0v9CA3IcXkc5O5+kxHdPqwwvi/jt4YzbFORB4H3FgE77ytRrHdlI2hdBA6yuzOFQ0SEQulrxpDjDvvx0bAQdPuikSCLyPwODQTG5xy8rTQCNFvG2J5DqtHJQ08zGV0JKXMgNu/XfwYb/UCATeRxzYLpP1uZzThoz6KRzQwx7CRYD3LEW4FWAgj3b6b3bIdUyNQOB9xIFNTN/3ZjLbvQ9XiSiHtQiwbJPb+lSE3QE+A3l4678bccDLn1MiEHgfEcCJE23a787zACNr3oaQhgkph2UI5UBtJC/yRKYteyM0/k67mGY9cUzFgaY7SYlA4H3EgW021jaW0tqExHopnUceMt0J5+c64bGy9UwctH9igFQlrfTbwU6qp7KMF97HftgMbBtBzYR5eGTreTOZSkrnlmUO1T4mIO+ANhn2PPXfPmVEHsINe49n7JXZjgPqc9rH43ODoM0piG1hZ1DvGCSy5YOo9mUB8G0YtIpPijKmQ3TySJ0+cCSQH4kRiFM9AKApeCNon8dDVkNiu1g7tYHDQwoeCLwPAPDjmYAYgKBIYQ4Ecx8AAAAgEHgfAAAAIBB4HwAAAECiBALvAwAAAAQC7wMAAAAEAu8DAAAASJhA4H0AAAB0HDH2gcD7aAGckp2utnaLq+/Y4IChTshETp+HtHGQszH3IIPHSyDwPvwMJEpfQek/cp1Y+TxsGly08/hRXisXUjF4Bz2flNO9bQ4lef+N8rVxOg1WlnlhpLgoTSbIUfFdTSqo/88DtvpBTZzjK3NpE96JPlL6q0weqE2K9CU7x7a9dVXcde1bc49ap57PegHdIxB4H34ssLnYn3amx7+hay7vI2U1M1FKTBxzgzYvzn6eyHuoX8cWSk81COjepl5TXvJMW2TaM25q/td4LIiPmXwvVRI0aRMmDjoW+ErU5+Iqzryn61red2eRdVdtW1Lmp451rmvfMjl/MJBBtV6FnOMI3kAIPQcC76M5eVTlLFsrVxlGfO++dywMB26ZknlJKVPuEcnDtbA7P73HJPLCXoEN+iwjbdbrVZYqZLCQ8U2NnM8hHYfngcD7aA494yu1J1mSqwrlMmSrtmi/qQF5jEoGLKVbX6lWHT8/Z8s3UxTTgkILB3iONimsfeeUz9kjK9q6LqzXyEJWyKOnPZNk4V4P4SnhyJHmQRKJ2KZxH8l7ntvoY/aoHrQ/L1nONxXtcMn1KtriDqri8AgE3kezgTVUlNProKo7NY4H20bed8ttmJURTQ15vJ5hUDWXUDxfXrc8n6H2E5HI9pAmb02SFco6q6Swaav+ipLVjYnKECL3/UreO+N7+xrpDyzDPnTPpoXzQ0ZavcZ1RKXI+YwNrG840+TwCATeR3Oo5LE1PXKUlcI+zyMvIQ9jq5Ri6UQYPIALPMi/nSAW3ZonmpkaE1pfUT+dagZDnw2AqWU5nlro4zNVb5h6OcqhTUBAhJoDgffRHF9UAmlBIamwPpmOB7qqgIrJXcCv90FkP9SUrPX55SUnKU4M50M24lf4jfr4yXMVVe/jGT0OAoH34QfbikHWVCHpp9nNXEMAfOSq2tdX6Dbv0Nt03OBZYweDTbf025xU/4zuBoHA+/CDn9qgHXl67qWmHG499nePCQrwQ/Y9zftotH+GDYWl8qeh4X1rzdsceZTHjfbcPnr+eAkE3oc/6BPgNIk5Z6XSBLn6jqbxbFYuqlI7Q9d5Q659f/TwzEeN8PuG/Xyrkc/Ck7LX60TzLDce5BzoIIHA+/AEtjT1SU6aY/gPhRBcLH0lLYRPhfRqOKjeEnrPG/qaTKw8yNVqD0nVQV+q/NRU0bMBohJTj/XBD1c5B9pDm6uwyJLNpTeRW973Gd5HtdXH6Rv0jVIjdveLpaaPht6EPti3nor6zVEhAfX4UkHSPgg/r5CJOnncSZkjgXzh+3pMIoMmnixN8LMsTzRZVeWciO/RB4kCaRJID55EayRCg2ZRopz1tA70u5lpnNzj+nmsw29vTCXnGTOJPCleEhk444bPncrnPrIOKZNzlUzIY7lrK4koUI3f0ASdJBHaB0K7ok/E26T3tkLZjNj1nyOGDFhEAGzlkTwYfVJ94kHO1yznp0wSuwo5p3e9aAk6ARAIYEAklDjuhAdZFZlMhEF82iPJgKy6i5+unrF4P3cxL9LJe5Bz2vFOO9L/FG/pZMrkvMjvtUAXgkAAt0GmkslS+wmFFvYNLl8T3upzEM7yB7UtM4/P9fUsfVL9wSFZo4lXUsg5kcla+8kInggIBPBgsTGRqJbaUFtqqSv33FMR1KW72whN8MXDM7YJdq3qHWQ+lLNySFhjwlfSiag71R/aCp8qIa6BeB/eukbIFgQCeCAS8TE/0FAb8KrCOPekkFQiipGOwlV55IkTyLqqLxtguOcdtjK31WSumFRvU87XJXKeQwOAQAA/JKIqhT+0n6h7P/oe4tYj7XuoZZaqws8dLdA284356ku1XI1SxXAbqc9Y+0iM2NakusE7txpxASAQwDMy7ftS++5sLXJ47FpTSKEUcSPrnJVpbM/JBPdqXzaM9080ebj3qNA/TKoH8Aq2GN4gEKBd0vhWEnJYal6I9UoWVsD6fbNQFeR6qOE42zg4KdNeBM/JFqSY9Xi/SxaCkUb22xYOiNIn1VvbF1ZiAOAYARAIoA0SyglkNSnJikIlkE3FQN9pIYeF6XuU0/HUsMFthAOl7jTSnFu0karclqmeY8Ll0jfpPdgkM+Rwkk7245bKemGrzFn25g4GQJ1HCoBAjpo8clZyZG3+YDLJ6iwyDm8sNCtzVTHQ9ZUspJBqzzin9/M7XsTHpbuz0G3EFvRaI8KnqnaqaKO9B3AlQCIr8TH0uOC65nUyRL8pIdZpW2RfMqluQuYj8Wtz4GSPnJMMzsXH0CmWjwfA72iCzkCd7CzSxFzzSYB0FTH7IpdYXuFpVA10OhZ0IN6fs52xYlqwYqZB+V9+R1+UT1S+nh1uacE/cY4vGwwqlN5Y/MrNJLgdiHCpXOqCgc9MxrqVe9GFUxQ5X1RB9EKpa84yQfUtwpVn3FdlFv2M5yvaLOtalmlq6BFel3iRc+6/rVKnKhlM3gAAgQAxMObBoXsEWQ1hvBtU+xLPKSSyKBmYudg/CUphq6iDl3MzldWhivDUNrro0jnuTCLPrGR7JTIx3FPfcahkhJzD7UuJ/H4wDER5nrei//bVaQDvIxwQwuqOstgpmwOXwjyuTL89tThbmjYhnjJhbSzecRKbPErqMBP7V+bslPKvOygXr2U3rKvg38y4vqEXCkz3yZSS540u0/IVB6GdgDzC4hOaoLvgeHdVaIKU4aZpOEbZGJiVvaMLSpfbKe9q+S3r2ue69kqUbCfnBmr67yD7sEv4nwADANrGvaMkeEBoAAAAAElFTkSuQmCC">
+<img height="150px; margin-top: " src="{logo}">
 <h1>
 	{title}
 </h1>
 <h2>
 {clientName}
 </h2>
-<h3>
-Subscription ID: {subscriptionId}
-</h3>
+{subscriptions}
 <p>
 Document Date: {date}
 </p>
 </div>
-{vmAlerts}
-{aksAlerts}
-{mySQLServers}
-{flexibleMySQLServers}
-{sqlServers}
-{storageAccounts}
-{webApps}
-{backups}
-{deallocatedVMs}
-{recommendations}
-{patches}
+{summary}
+{sections}
 </body>
 </html>`
 
-	now := time.Now()
+	sections := g.generateSections()
+	if len(g.Breakdown) > 0 {
+		sections = g.GenerateBreakdownSection()
+	}
+
 	documentReplacer := strings.NewReplacer(
-		"{headContent}", g.Head,
-		"{title}", "Tangent Solutions Managed Services Report",
-		"{clientName}", g.ClientName,
-		"{subscriptionId}", g.SubscriptionId,
-		"{date}", fmt.Sprintf("%d-%d-%d", now.Year(), now.Month(), now.Day()),
-		"{vmAlerts}", g.GenerateAlertRulesSection("Virtual Machines", g.VirtualMachines),
-		"{aksAlerts}", g.GenerateAlertRulesSection("Azure Kubernetes Services", g.AzureKubernetesServices),
-		"{mySQLServers}", g.GenerateAlertRulesSection("MySQL Servers", g.MySQLServers),
-		"{flexibleMySQLServers}", g.GenerateAlertRulesSection("Flexible MySQL Servers", g.FlexibleMySQLServers),
-		"{sqlServers}", g.GenerateAlertRulesSection("SQL Servers", g.SqlServers),
-		"{storageAccounts}", g.GenerateAlertRulesSection("Storage Accounts", g.StorageAccounts),
-		"{webApps}", g.GenerateAlertRulesSection("Web Apps", g.WebApps),
-		"{backups}", g.GenerateBackupsSection(),
-		"{patches}", g.GeneratePatchesSection(),
-		"{recommendations}", g.GenerateRecommendationsSections(),
-		"{deallocatedVMs}", g.GenerateDeallocatedVMsSection(),
+		"{headContent}", head,
+		"{title}", html.EscapeString(g.Title),
+		"{logo}", html.EscapeString(g.Logo),
+		"{clientName}", html.EscapeString(g.ClientName),
+		"{subscriptions}", g.generateCoverSubscriptions(),
+		"{date}", fmt.Sprintf("%d-%d-%d", g.Date.Year(), g.Date.Month(), g.Date.Day()),
+		"{summary}", g.GenerateSummarySection(),
+		"{sections}", sections,
 	)
-	populatedHtml := documentReplacer.Replace(htmlStr)
+
+	return documentReplacer.Replace(htmlStr)
+}
+
+func (g Generator) GeneratePDF() error {
+	pdfGenerator, err := wkhtml.NewPDFGenerator()
+	if err != nil {
+		return err
+	}
+	populatedHtml := g.GenerateHTML(g.Head)
 
 	var margin uint = 16
 	pdfGenerator.MarginRight.Set(margin)
@@ -321,7 +833,7 @@ Document Date: {date}
 		return err
 	}
 
-	filename := fmt.Sprintf("./%s.pdf", g.OutputFilename)
+	filename := fmt.Sprintf("%s.pdf", g.OutputFilename)
 	err = pdfGenerator.WriteFile(filename)
 	if err != nil {
 		return err
@@ -0,0 +1,122 @@
+package pdf
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jayps/azure-checker-go/history"
+	"github.com/jayps/azure-checker-go/rules"
+	"github.com/jayps/azure-checker-go/scan"
+	"github.com/jayps/azure-checker-go/scorecard"
+)
+
+var environments = rules.Environments{Production: []string{"environment=prod*"}}
+
+func newTestGenerator(t *testing.T, snapshot scan.Snapshot) Generator {
+	t.Helper()
+
+	findings := rules.Evaluate(rules.Builtin, snapshot, environments)
+	card := scorecard.Compute(snapshot, findings, environments)
+	result := snapshot.Result
+
+	g := NewGenerator()
+	g.ClientName = "Acme <Holdings> & Sons"
+	g.Date = snapshot.StartedAt
+	g.SubscriptionId = result.SubscriptionId
+	g.SubscriptionName = result.SubscriptionName
+	g.VirtualMachines = result.VirtualMachines
+	g.VirtualMachinesDeallocated = result.VirtualMachinesDeallocated
+	g.AzureKubernetesServices = result.AzureKubernetesServices
+	g.MySQLServers = result.MySQLServers
+	g.FlexibleMySQLServers = result.FlexibleMySQLServers
+	g.SqlServers = result.SqlServers
+	g.StorageAccounts = result.StorageAccounts
+	g.WebApps = result.WebApps
+	g.Recommendations = result.Recommendations
+	g.Problems = result.Problems
+	g.Findings = findings
+	g.Environments = environments
+	g.Scorecards = []scorecard.Scorecard{card}
+	g.Settings = snapshot.Settings
+	g.Trend = []history.Point{history.PointOf(snapshot, card), history.PointOf(snapshot, card)}
+
+	return g
+}
+
+func loadSnapshot(t *testing.T) scan.Snapshot {
+	t.Helper()
+
+	snapshot, err := scan.LoadSnapshot(filepath.Join("..", "scan", "testdata", "snapshot.json"))
+	if err != nil {
+		t.Fatalf("could not load the snapshot: %s", err)
+	}
+
+	return snapshot
+}
+
+func TestGenerateHTML(t *testing.T) {
+	output := newTestGenerator(t, loadSnapshot(t)).GenerateHTML("<title>report</title>")
+
+	if !strings.HasPrefix(output, "<!DOCTYPE html>") {
+		t.Errorf("the report does not start with a doctype: %.40q", output)
+	}
+
+	for _, want := range []string{
+		"<title>report</title>",
+		"Acme &lt;Holdings&gt; &amp; Sons",
+		"<h2>Executive Summary</h2>",
+		"<h2>Collection Problems</h2>",
+		"<h2>Trends</h2>",
+		"<h2>Findings</h2>",
+		"<h2>Virtual Machine Backups</h2>",
+		"<h2>Deallocated Virtual Machines</h2>",
+		"<h2>Virtual Machine Patches</h2>",
+		"<h2>Advisory Recommendations: HighAvailability</h2>",
+		"vault1",
+		"vm2",
+		"KB1",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("the report does not contain %q", want)
+		}
+	}
+
+	if strings.Contains(output, "Acme <Holdings>") {
+		t.Error("the client name is not escaped")
+	}
+	if strings.Count(output, "<html>") != 1 || strings.Count(output, "</html>") != 1 {
+		t.Error("the report is not a single HTML document")
+	}
+}
+
+func TestGenerateHTMLFailedChecks(t *testing.T) {
+	snapshot := loadSnapshot(t)
+	for _, vm := range snapshot.Result.VirtualMachines {
+		snapshot.Result.Problems = append(snapshot.Result.Problems,
+			scan.Problem{Check: "backups for VM " + vm.Name, ResourceId: vm.Id},
+			scan.Problem{Check: "patches for VM " + vm.Name, ResourceId: vm.Id},
+		)
+	}
+
+	output := newTestGenerator(t, snapshot).GenerateHTML("")
+
+	if got := strings.Count(output, notChecked); got != 2*len(snapshot.Result.VirtualMachines) {
+		t.Errorf("got %d notes that a check could not be completed, want %d", got, 2*len(snapshot.Result.VirtualMachines))
+	}
+	if strings.Contains(output, "vault1") {
+		t.Error("the backup vault of a VM whose backups could not be checked is reported")
+	}
+}
+
+func TestGenerateHTMLBreakdown(t *testing.T) {
+	snapshot := loadSnapshot(t)
+	g := newTestGenerator(t, snapshot)
+	g.Breakdown = []Generator{newTestGenerator(t, snapshot), newTestGenerator(t, snapshot)}
+
+	output := g.GenerateHTML("")
+
+	if got := strings.Count(output, "<h1>Prod-Payments</h1>"); got != 2 {
+		t.Errorf("got %d subscription chapters, want 2", got)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jayps/azure-checker-go/config"
+	"github.com/jayps/azure-checker-go/rules"
+)
+
+const profileUsage = `Usage: azure-checker profile <command> [arguments]
+
+Commands:
+  add <name> [flags]   save a client profile
+  list                 list the saved profiles
+  show <name>          print a profile
+  remove <name>        delete a profile
+
+Profiles are kept in %s (set AZURE_CHECKER_PROFILE_DIR to change this).
+Scan a profile with: azure-checker run --profile <name>
+`
+
+func printProfileUsage() {
+	dir, err := config.ProfileDir()
+	if err != nil {
+		dir = "the user config directory"
+	}
+	fmt.Printf(profileUsage, dir)
+}
+
+// profileName takes the single profile name a subcommand expects from its arguments.
+func profileName(command string, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errors.New(fmt.Sprintf("usage: azure-checker profile %s <name>", command))
+	}
+
+	return args[0], config.ValidateProfileName(args[0])
+}
+
+func addProfile(args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return errors.New("usage: azure-checker profile add <name> [flags]")
+	}
+	name := args[0]
+	err := config.ValidateProfileName(name)
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("profile add", flag.ContinueOnError)
+	client := flags.String("client", "", "client display name used in the reports")
+	subscriptions := flags.String("subscriptions", "", "comma separated list of subscription IDs to check")
+	discover := flags.Bool("discover", false, "discover the subscriptions instead of listing them")
+	tenant := flags.String("tenant", "", "only discover subscriptions in this tenant")
+	managementGroup := flags.String("management-group", "", "only discover subscriptions directly under this management group")
+	include := flags.String("include", "", "comma separated subscription name or ID patterns to discover")
+	exclude := flags.String("exclude", "", "comma separated subscription name or ID patterns to skip during discovery")
+	outputDir := flags.String("output-dir", "", "directory the reports are written to")
+	formats := flags.String("formats", "", fmt.Sprintf("comma separated list of output formats: %s", strings.Join(config.Formats, ", ")))
+	consolidate := flags.Bool("consolidate", false, "write one report covering all subscriptions instead of one per subscription")
+	title := flags.String("title", "", "title on the cover of the PDF report")
+	logo := flags.String("logo", "", "PNG or JPEG logo for the cover of the PDF report")
+	rulesFiles := flags.String("rules", "", "comma separated list of custom rules files")
+	waiverFiles := flags.String("waivers", "", "comma separated list of waiver files")
+	production := flags.String("production", "", "comma separated tag selectors of production resources, e.g. environment=prod*")
+	nonProduction := flags.String("non-production", "", "comma separated tag selectors of non-production resources, e.g. environment=dev")
+	force := flags.Bool("force", false, "replace the profile if it already exists")
+
+	err = flags.Parse(args[1:])
+	if err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return errors.New(fmt.Sprintf("unexpected arguments: %s", strings.Join(flags.Args(), " ")))
+	}
+	if *client == "" {
+		return errors.New("--client is required")
+	}
+
+	formatList, err := config.NormalizeFormats(splitList(*formats))
+	if err != nil {
+		return err
+	}
+
+	profile := config.Profile{
+		Name: name,
+		Config: config.Config{
+			Client:        *client,
+			Subscriptions: splitList(*subscriptions),
+			Discovery: config.Discovery{
+				Enabled:         *discover,
+				Tenant:          *tenant,
+				ManagementGroup: *managementGroup,
+				Include:         splitList(*include),
+				Exclude:         splitList(*exclude),
+			},
+			Output: config.Output{
+				Dir:         *outputDir,
+				Formats:     formatList,
+				Consolidate: *consolidate,
+			},
+			Branding: config.Branding{
+				Title: *title,
+				Logo:  *logo,
+			},
+			Rules:   splitList(*rulesFiles),
+			Waivers: splitList(*waiverFiles),
+			Environments: rules.Environments{
+				Production:    splitList(*production),
+				NonProduction: splitList(*nonProduction),
+			},
+		},
+	}
+
+	// The profile is read from the profile directory later, so relative paths are resolved now.
+	if profile.Config.Branding.Logo != "" {
+		profile.Config.Branding.Logo, err = filepath.Abs(profile.Config.Branding.Logo)
+		if err != nil {
+			return err
+		}
+	}
+	for _, files := range [][]string{profile.Config.Rules, profile.Config.Waivers} {
+		for i, file := range files {
+			files[i], err = filepath.Abs(file)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if len(profile.Config.Subscriptions) == 0 && !profile.Config.Discovery.Enabled && *managementGroup == "" {
+		return errors.New("either --subscriptions or --discover is required")
+	}
+
+	path, err := config.SaveProfile(profile, *force)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(fmt.Sprintf("Saved profile %s to %s", name, path))
+
+	return nil
+}
+
+func listProfiles() error {
+	names, err := config.ListProfiles()
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		fmt.Println("No profiles saved yet. Create one with: azure-checker profile add <name> --client <client> --subscriptions <ids>")
+		return nil
+	}
+
+	for _, name := range names {
+		profile, err := config.LoadProfile(name)
+		if err != nil {
+			fmt.Println(fmt.Sprintf("%-20s %s", name, err.Error()))
+			continue
+		}
+
+		scope := fmt.Sprintf("%d subscriptions", len(profile.Config.Subscriptions))
+		if len(profile.Config.Subscriptions) == 0 {
+			scope = "discovered subscriptions"
+		}
+		fmt.Println(fmt.Sprintf("%-20s %s (%s)", name, profile.Config.Client, scope))
+	}
+
+	return nil
+}
+
+func showProfile(args []string) error {
+	name, err := profileName("show", args)
+	if err != nil {
+		return err
+	}
+
+	profile, err := config.LoadProfile(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := profile.Config.Marshal()
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(string(data))
+
+	return nil
+}
+
+func removeProfile(args []string) error {
+	name, err := profileName("remove", args)
+	if err != nil {
+		return err
+	}
+
+	err = config.RemoveProfile(name)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(fmt.Sprintf("Removed profile %s", name))
+
+	return nil
+}
+
+func runProfileCommand(args []string) error {
+	if len(args) == 0 {
+		printProfileUsage()
+		return flag.ErrHelp
+	}
+
+	switch args[0] {
+	case "add":
+		return addProfile(args[1:])
+	case "list":
+		return listProfiles()
+	case "show":
+		return showProfile(args[1:])
+	case "remove":
+		return removeProfile(args[1:])
+	case "help", "-h", "-help", "--help":
+		printProfileUsage()
+		return flag.ErrHelp
+	}
+
+	printProfileUsage()
+	return errors.New(fmt.Sprintf("unknown profile command %q", args[0]))
+}
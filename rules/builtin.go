@@ -0,0 +1,108 @@
+package rules
+
+import (
+	"fmt"
+
+	"github.com/jayps/azure-checker-go/scan"
+)
+
+// IDs of the built-in rules.
+const (
+	RuleNoAlertRules          = "ALERT-001"
+	RuleNotBackedUp           = "BACKUP-001"
+	RuleOutstandingPatch      = "PATCH-001"
+	RuleAdvisorRecommendation = "ADVISOR-001"
+)
+
+// Builtin are the rules every scan is evaluated against.
+var Builtin = []Rule{
+	{
+		Id:       RuleNoAlertRules,
+		Title:    "Resource has no alert rules",
+		Check:    scan.CheckAlertRules,
+		Severity: SeverityMedium,
+		// Deallocated VMs are not running, so nobody expects them to alert.
+		ResourceTypes: []string{scan.VirtualMachines, scan.AKSClusters, scan.MySQLServers, scan.FlexibleMySQLServers, scan.SQLServers, scan.StorageAccounts, scan.WebApps},
+		// We do not monitor non-production resources.
+		Environments: []string{EnvironmentProduction, EnvironmentUnclassified},
+		Evaluate: func(target Target) []Violation {
+			if len(target.Resource.AlertRules) > 0 {
+				return nil
+			}
+			violation := Violation{Message: "No alert rules are configured for this resource."}
+			if target.Environment == EnvironmentProduction {
+				violation.Remediation = "Create resource alert rules for this production resource."
+			}
+			return []Violation{violation}
+		},
+		Remediation: "If this resource is used in production, create resource alert rules. We do not monitor non-production resources.",
+	},
+	{
+		Id:            RuleNotBackedUp,
+		Title:         "Virtual machine is not backed up",
+		Check:         scan.CheckBackups,
+		Severity:      SeverityHigh,
+		ResourceTypes: []string{scan.VirtualMachines},
+		Environments:  []string{EnvironmentProduction, EnvironmentUnclassified},
+		Evaluate: func(target Target) []Violation {
+			if target.Resource.BackupVault != nil {
+				return nil
+			}
+			violation := Violation{Message: "This virtual machine is not backed up."}
+			if target.Environment == EnvironmentProduction {
+				violation.Remediation = "Set up backups for this production machine using Azure Backup Vault. If an alternative backup solution is being used, record it as a waiver."
+			}
+			return []Violation{violation}
+		},
+		Remediation: "If this is a production machine, consider setting up backups using Azure Backup Vault. If an alternative backup solution is being used, this recommendation can be ignored.",
+	},
+	{
+		Id:            RuleOutstandingPatch,
+		Title:         "Patch outstanding",
+		Check:         scan.CheckPatches,
+		Severity:      SeverityLow,
+		ResourceTypes: []string{scan.VirtualMachines},
+		Evaluate: func(target Target) []Violation {
+			var violations []Violation
+			for _, patch := range target.Resource.PatchAssessmentResult.AvailablePatches {
+				violation := Violation{
+					Detail:  patch.PatchId,
+					Message: fmt.Sprintf("Patch outstanding: %s", patch.Name),
+				}
+				if violation.Detail == "" {
+					violation.Detail = patch.Name
+				}
+				for _, classification := range patch.Classifications {
+					if classification == "Critical" || classification == "Security" {
+						violation.Severity = SeverityHigh
+					}
+				}
+				violations = append(violations, violation)
+			}
+			return violations
+		},
+		Remediation: "Install the outstanding patches in the next maintenance window, critical and security patches first.",
+	},
+	{
+		Id:            RuleAdvisorRecommendation,
+		Title:         "Azure Advisor recommendation",
+		Check:         scan.CheckRecommendations,
+		Severity:      SeverityLow,
+		ResourceTypes: []string{AdvisorRecommendations},
+		Evaluate: func(target Target) []Violation {
+			recommendation := target.Recommendation
+			violation := Violation{
+				Detail:  fmt.Sprintf("%s|%s|%s|%s", recommendation.Category, recommendation.Description.Problem, recommendation.ResourceGroup, recommendation.AffectedResource),
+				Message: fmt.Sprintf("%s (%s, %s impact)", recommendation.Description.Problem, recommendation.Category, recommendation.Impact),
+			}
+			switch recommendation.Impact {
+			case "High":
+				violation.Severity = SeverityHigh
+			case "Medium":
+				violation.Severity = SeverityMedium
+			}
+			return []Violation{violation}
+		},
+		Remediation: "Review the recommendation in Azure Advisor and apply it, or dismiss it there if it does not apply.",
+	},
+}
@@ -0,0 +1,211 @@
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/jayps/azure-checker-go/scan"
+	"gopkg.in/yaml.v3"
+)
+
+var ruleIdPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// Definition is a rule as it is written in a rules file. See docs/rules.md.
+type Definition struct {
+	Id            string   `yaml:"id"`
+	Title         string   `yaml:"title"`
+	Severity      string   `yaml:"severity"`
+	Check         string   `yaml:"check,omitempty"`
+	ResourceTypes []string `yaml:"resourceTypes"`
+	Environments  []string `yaml:"environments,omitempty"`
+	When          string   `yaml:"when,omitempty"`
+	Assert        string   `yaml:"assert"`
+	Message       string   `yaml:"message,omitempty"`
+	Remediation   string   `yaml:"remediation,omitempty"`
+}
+
+type file struct {
+	Rules []Definition `yaml:"rules"`
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// document turns a target into the JSON document expressions are evaluated against. A resource starts from the JSON
+// Azure returned for it, with the fields the checker collected (alertRules, backupVault, ...) laid over it.
+func document(target Target) (interface{}, error) {
+	var value interface{} = target.Resource
+	if target.ResourceType == AdvisorRecommendations {
+		value = target.Recommendation
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var result interface{}
+	err = json.Unmarshal(data, &result)
+	if err != nil || target.ResourceType == AdvisorRecommendations {
+		return result, err
+	}
+
+	fields := result.(map[string]interface{})
+	delete(fields, "raw")
+	if len(target.Resource.Raw) == 0 {
+		return fields, nil
+	}
+
+	var raw map[string]interface{}
+	err = json.Unmarshal(target.Resource.Raw, &raw)
+	if err != nil || raw == nil {
+		return fields, nil
+	}
+	for key, field := range fields {
+		raw[key] = field
+	}
+
+	return raw, nil
+}
+
+// Compile checks a definition and turns it into a rule. A resource is flagged when it matches When (or there is no
+// When) and does not match Assert.
+func (d Definition) Compile() (Rule, error) {
+	if !ruleIdPattern.MatchString(d.Id) {
+		return Rule{}, errors.New(fmt.Sprintf("invalid rule ID %q, use letters, digits, '.', '_' and '-'", d.Id))
+	}
+	if d.Title == "" {
+		return Rule{}, errors.New(fmt.Sprintf("rule %s has no title", d.Id))
+	}
+	if !contains(Severities, d.Severity) {
+		return Rule{}, errors.New(fmt.Sprintf("rule %s has severity %q, expected one of: %s", d.Id, d.Severity, strings.Join(Severities, ", ")))
+	}
+	if d.Check != "" && !contains(scan.Checks, d.Check) {
+		return Rule{}, errors.New(fmt.Sprintf("rule %s has check %q, expected one of: %s", d.Id, d.Check, strings.Join(scan.Checks, ", ")))
+	}
+	if len(d.ResourceTypes) == 0 {
+		return Rule{}, errors.New(fmt.Sprintf("rule %s has no resourceTypes", d.Id))
+	}
+	knownTypes := append(append([]string{}, scan.ResourceTypes...), AdvisorRecommendations)
+	for _, resourceType := range d.ResourceTypes {
+		if !contains(knownTypes, resourceType) {
+			return Rule{}, errors.New(fmt.Sprintf("rule %s has resource type %q, expected one of: %s", d.Id, resourceType, strings.Join(knownTypes, ", ")))
+		}
+	}
+
+	for _, environment := range d.Environments {
+		if !contains(EnvironmentNames, environment) {
+			return Rule{}, errors.New(fmt.Sprintf("rule %s has environment %q, expected one of: %s", d.Id, environment, strings.Join(EnvironmentNames, ", ")))
+		}
+	}
+
+	if d.Assert == "" {
+		return Rule{}, errors.New(fmt.Sprintf("rule %s has no assert expression", d.Id))
+	}
+	assert, err := Compile(d.Assert)
+	if err != nil {
+		return Rule{}, errors.New(fmt.Sprintf("rule %s: invalid assert expression: %s", d.Id, err.Error()))
+	}
+	var when *Expression
+	if d.When != "" {
+		w, err := Compile(d.When)
+		if err != nil {
+			return Rule{}, errors.New(fmt.Sprintf("rule %s: invalid when expression: %s", d.Id, err.Error()))
+		}
+		when = &w
+	}
+
+	message := d.Message
+	if message == "" {
+		message = d.Title
+	}
+
+	return Rule{
+		Id:            d.Id,
+		Title:         d.Title,
+		Check:         d.Check,
+		Severity:      d.Severity,
+		ResourceTypes: d.ResourceTypes,
+		Environments:  d.Environments,
+		Evaluate: func(target Target) []Violation {
+			doc, err := document(target)
+			if err != nil {
+				return nil
+			}
+			if when != nil && !when.Matches(doc) {
+				return nil
+			}
+			if assert.Matches(doc) {
+				return nil
+			}
+			return []Violation{{Message: message}}
+		},
+		Remediation: d.Remediation,
+	}, nil
+}
+
+// LoadFile reads the rules in a YAML rules file. Unknown keys are an error, like in the config file.
+func LoadFile(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var contents file
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&contents)
+	if err != nil && err != io.EOF {
+		return nil, errors.New(fmt.Sprintf("invalid rules file %s: %s", path, err.Error()))
+	}
+
+	var result []Rule
+	for _, definition := range contents.Rules {
+		rule, err := definition.Compile()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid rules file %s: %s", path, err.Error()))
+		}
+		result = append(result, rule)
+	}
+
+	return result, nil
+}
+
+// Load returns the built-in rules followed by the rules in the given files. Rule IDs must be unique.
+func Load(paths []string) ([]Rule, error) {
+	result := append([]Rule{}, Builtin...)
+	seen := make(map[string]bool)
+	for _, rule := range Builtin {
+		seen[rule.Id] = true
+	}
+
+	for _, path := range paths {
+		rules, err := LoadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rule := range rules {
+			if seen[rule.Id] {
+				return nil, errors.New(fmt.Sprintf("rule %s in %s is defined more than once", rule.Id, path))
+			}
+			seen[rule.Id] = true
+			result = append(result, rule)
+		}
+	}
+
+	return result, nil
+}
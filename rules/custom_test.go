@@ -0,0 +1,95 @@
+package rules
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/jayps/azure-checker-go/azure"
+	"github.com/jayps/azure-checker-go/scan"
+)
+
+// sqlServer is an Azure SQL server as ARM returns it, with properties the checker does not collect.
+const sqlServer = `{
+	"id": "/subscriptions/00000000-0000-0000-0000-000000000001/resourceGroups/rg-web/providers/Microsoft.Sql/servers/sql-web",
+	"name": "sql-web",
+	"type": "Microsoft.Sql/servers",
+	"location": "westeurope",
+	"tags": {"environment": "production"},
+	"sku": {"name": "GP_Gen5_2", "tier": "GeneralPurpose"},
+	"properties": {"minimalTlsVersion": "1.0", "publicNetworkAccess": "Enabled"}
+}`
+
+func sqlServerTarget(t *testing.T, raw string) Target {
+	t.Helper()
+
+	var resource azure.Resource
+	err := json.Unmarshal([]byte(raw), &resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resource.Raw = json.RawMessage(raw)
+	resource.ResourceGroup = "rg-web"
+	resource.AlertRules = []azure.AlertRule{{Name: "dtu"}}
+
+	return Target{ResourceType: scan.SQLServers, Resource: resource, Environment: EnvironmentProduction}
+}
+
+func TestCustomRuleEvaluatesRawResource(t *testing.T) {
+	target := sqlServerTarget(t, sqlServer)
+	collected := target
+	collected.Resource.Raw = nil
+
+	tests := []struct {
+		name   string
+		when   string
+		assert string
+		target Target
+		want   int
+	}{
+		{"nested property", "", "$.properties.minimalTlsVersion == '1.2'", target, 1},
+		{"nested property passes", "", "$.properties.minimalTlsVersion =~ '^1\\.'", target, 0},
+		{"sku", "$.sku.tier == 'GeneralPurpose'", "$.sku.name =~ '^BC_'", target, 1},
+		{"collected fields", "", "count($.alertRules[*]) == 1 && $.resourceGroup == 'rg-web'", target, 0},
+		{"raw is not a field", "", "exists($.raw)", target, 1},
+		{"no raw JSON", "", "$.properties.minimalTlsVersion == '1.2'", collected, 1},
+		{"no raw JSON keeps collected fields", "", "$.name == 'sql-web' && exists($.alertRules[0])", collected, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, err := Definition{
+				Id:            "SQL-TLS",
+				Title:         "SQL server allows TLS below 1.2",
+				Severity:      SeverityHigh,
+				ResourceTypes: []string{scan.SQLServers},
+				When:          test.when,
+				Assert:        test.assert,
+			}.Compile()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if got := rule.Evaluate(test.target); len(got) != test.want {
+				t.Errorf("got %d violations, want %d", len(got), test.want)
+			}
+		})
+	}
+}
+
+func TestDocumentOverlaysCollectedFields(t *testing.T) {
+	// The fields the checker collected win over properties of the same name in the raw JSON.
+	target := sqlServerTarget(t, `{"name": "sql-web", "resourceGroup": "RG-WEB", "alertRules": []}`)
+
+	doc, err := document(target)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	fields := doc.(map[string]interface{})
+	if fields["resourceGroup"] != "rg-web" {
+		t.Errorf("got resourceGroup %v, want the collected rg-web", fields["resourceGroup"])
+	}
+	if rules, ok := fields["alertRules"].([]interface{}); !ok || len(rules) != 1 {
+		t.Errorf("got alertRules %v, want the collected rule", fields["alertRules"])
+	}
+}
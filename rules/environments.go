@@ -0,0 +1,100 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Environments a resource can be classified as.
+const (
+	EnvironmentProduction    = "production"
+	EnvironmentNonProduction = "non-production"
+	EnvironmentUnclassified  = "unclassified"
+)
+
+var EnvironmentNames = []string{EnvironmentProduction, EnvironmentNonProduction, EnvironmentUnclassified}
+
+// Environments classifies resources by their tags. Each selector is "name=value", or "name" for any value; the
+// value can be a pattern such as "prod*". Production selectors are tried first. Resources matching neither are
+// classified as Default, or as unclassified when it is not set.
+type Environments struct {
+	Production    []string `yaml:"production,omitempty" json:"production,omitempty"`
+	NonProduction []string `yaml:"nonProduction,omitempty" json:"nonProduction,omitempty"`
+	Default       string   `yaml:"default,omitempty" json:"default,omitempty"`
+}
+
+// matchesTag reports whether tags contain a tag selected by selector. Azure tag names are not case sensitive, so
+// neither is the selector.
+func matchesTag(selector string, tags map[string]string) bool {
+	name, value, hasValue := strings.Cut(selector, "=")
+	name = strings.TrimSpace(name)
+	pattern := strings.ToLower(strings.TrimSpace(value))
+	for tagName, tagValue := range tags {
+		if !strings.EqualFold(tagName, name) {
+			continue
+		}
+		if !hasValue {
+			return true
+		}
+		if matched, _ := path.Match(pattern, strings.ToLower(tagValue)); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateTagSelector(selector string) error {
+	name, value, _ := strings.Cut(selector, "=")
+	if strings.TrimSpace(name) == "" {
+		return errors.New(fmt.Sprintf("tag selector %q has no tag name", selector))
+	}
+
+	_, err := path.Match(strings.ToLower(strings.TrimSpace(value)), "")
+	if err != nil {
+		return errors.New(fmt.Sprintf("tag selector %q has an invalid pattern: %s", selector, err.Error()))
+	}
+
+	return nil
+}
+
+func (e Environments) Validate() error {
+	for _, selector := range append(append([]string{}, e.Production...), e.NonProduction...) {
+		err := validateTagSelector(selector)
+		if err != nil {
+			return err
+		}
+	}
+
+	if e.Default != "" && !contains(EnvironmentNames, e.Default) {
+		return errors.New(fmt.Sprintf("unknown default environment %q, expected one of: %s", e.Default, strings.Join(EnvironmentNames, ", ")))
+	}
+
+	return nil
+}
+
+// Configured reports whether resources are classified at all. Without a mapping every resource is unclassified.
+func (e Environments) Configured() bool {
+	return len(e.Production) > 0 || len(e.NonProduction) > 0 || e.Default != ""
+}
+
+func (e Environments) Classify(tags map[string]string) string {
+	for _, selector := range e.Production {
+		if matchesTag(selector, tags) {
+			return EnvironmentProduction
+		}
+	}
+	for _, selector := range e.NonProduction {
+		if matchesTag(selector, tags) {
+			return EnvironmentNonProduction
+		}
+	}
+
+	if e.Default != "" {
+		return e.Default
+	}
+
+	return EnvironmentUnclassified
+}
@@ -0,0 +1,125 @@
+package rules
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jayps/azure-checker-go/azure"
+	"github.com/jayps/azure-checker-go/scan"
+)
+
+var testEnvironments = Environments{
+	Production:    []string{"environment=prod*", "criticality=high"},
+	NonProduction: []string{"environment=dev", "environment=test", "sandbox"},
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name         string
+		environments Environments
+		tags         map[string]string
+		want         string
+	}{
+		{"production", testEnvironments, map[string]string{"environment": "prod"}, EnvironmentProduction},
+		{"pattern", testEnvironments, map[string]string{"environment": "production-eu"}, EnvironmentProduction},
+		{"second selector", testEnvironments, map[string]string{"criticality": "high"}, EnvironmentProduction},
+		{"non-production", testEnvironments, map[string]string{"environment": "dev"}, EnvironmentNonProduction},
+		{"tag without a value", testEnvironments, map[string]string{"sandbox": ""}, EnvironmentNonProduction},
+		{"tag name is not case sensitive", testEnvironments, map[string]string{"Environment": "dev"}, EnvironmentNonProduction},
+		{"tag value is not case sensitive", testEnvironments, map[string]string{"environment": "PROD"}, EnvironmentProduction},
+		{"production wins", testEnvironments, map[string]string{"environment": "dev", "criticality": "high"}, EnvironmentProduction},
+		{"unknown value", testEnvironments, map[string]string{"environment": "staging"}, EnvironmentUnclassified},
+		{"missing tag", testEnvironments, map[string]string{"owner": "web-team"}, EnvironmentUnclassified},
+		{"no tags", testEnvironments, nil, EnvironmentUnclassified},
+		{"pattern matches the whole value", testEnvironments, map[string]string{"environment": "preprod"}, EnvironmentUnclassified},
+		{"default", Environments{Production: []string{"environment=prod"}, Default: EnvironmentNonProduction}, nil, EnvironmentNonProduction},
+		{"not configured", Environments{}, map[string]string{"environment": "prod"}, EnvironmentUnclassified},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.environments.Classify(test.tags); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEnvironmentsValidate(t *testing.T) {
+	tests := []struct {
+		environments Environments
+		message      string
+	}{
+		{testEnvironments, ""},
+		{Environments{Production: []string{"=prod"}}, "has no tag name"},
+		{Environments{NonProduction: []string{"environment=[dev"}}, "has an invalid pattern"},
+		{Environments{Default: "staging"}, `unknown default environment "staging"`},
+	}
+
+	for _, test := range tests {
+		err := test.environments.Validate()
+		if test.message == "" && err != nil {
+			t.Errorf("%+v: unexpected error: %s", test.environments, err)
+		}
+		if test.message != "" && (err == nil || !strings.Contains(err.Error(), test.message)) {
+			t.Errorf("%+v: got %v, want an error containing %q", test.environments, err, test.message)
+		}
+	}
+}
+
+func TestBuiltinRulesEnvironments(t *testing.T) {
+	// Three bare VMs: no alert rules, no backups and one outstanding patch each.
+	var patches azure.PatchAssessmentResult
+	err := json.Unmarshal([]byte(`{"availablePatches": [{"patchId": "p-1", "name": "2024-01 Cumulative Update", "classifications": ["Security"]}]}`), &patches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vms := make(map[string]azure.Resource)
+	for name, tags := range map[string]map[string]string{
+		"vm-prod":  {"environment": "prod"},
+		"vm-dev":   {"environment": "dev"},
+		"vm-other": nil,
+	} {
+		id := "/subscriptions/00000000-0000-0000-0000-000000000001/resourcegroups/rg-web/providers/microsoft.compute/virtualmachines/" + name
+		vms[id] = azure.Resource{
+			Id:                    id,
+			Name:                  name,
+			Tags:                  tags,
+			PatchAssessmentResult: patches,
+		}
+	}
+	snapshot := scan.Snapshot{Result: scan.Result{VirtualMachines: vms}}
+
+	tests := []struct {
+		name         string
+		environments Environments
+		rule         string
+		want         []string
+	}{
+		// Unclassified resources are treated like production, so a missing tag does not hide a finding.
+		{"alerts", testEnvironments, RuleNoAlertRules, []string{"vm-other", "vm-prod"}},
+		{"backups", testEnvironments, RuleNotBackedUp, []string{"vm-other", "vm-prod"}},
+		{"patches", testEnvironments, RuleOutstandingPatch, []string{"vm-dev", "vm-other", "vm-prod"}},
+		{"alerts without a mapping", Environments{}, RuleNoAlertRules, []string{"vm-dev", "vm-other", "vm-prod"}},
+		{"backups without a mapping", Environments{}, RuleNotBackedUp, []string{"vm-dev", "vm-other", "vm-prod"}},
+		{"backups with a non-production default", Environments{Production: []string{"environment=prod"}, Default: EnvironmentNonProduction}, RuleNotBackedUp, []string{"vm-prod"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, finding := range Evaluate(Builtin, snapshot, test.environments) {
+				if finding.RuleId == test.rule {
+					got = append(got, finding.ResourceName)
+				}
+			}
+			sort.Strings(got)
+
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("got findings on %v, want %v", got, test.want)
+			}
+		})
+	}
+}
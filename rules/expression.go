@@ -0,0 +1,491 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Expressions test the JSON of a resource, as it is saved in the scan snapshot. They are deliberately small:
+//
+//	$.name                      the value at a path from the resource; @ is the current element inside any() and all()
+//	$.alertRules[0]  $.tags['cost-centre']  $.alertRules[*]
+//	== != < <= > >= =~          comparisons, true if any value the paths match satisfies them; =~ takes a regular
+//	                            expression string
+//	&& || ! ( )                 logic
+//	'text' "text" 80 true false null
+//	exists(path)                whether the path matches anything
+//	count(path)                 the number of values the path matches, e.g. count($.alertRules[*])
+//	any(path, expression)       whether the expression holds for any value the path matches
+//	all(path, expression)       whether the expression holds for every value the path matches
+//
+// A path that matches nothing is false on its own and fails every comparison.
+
+// Expression is a compiled expression.
+type Expression struct {
+	source   string
+	evaluate evaluator
+}
+
+type context struct {
+	root    interface{}
+	current interface{}
+}
+
+type evaluator func(ctx context) []interface{}
+
+type token struct {
+	kind  string // "ident", "number", "string", "end" or the operator itself
+	text  string
+	value interface{}
+	pos   int
+}
+
+var operators = []string{"==", "!=", "<=", ">=", "=~", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ".", ",", "$", "@", "*"}
+
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(source) {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			var text strings.Builder
+			j := i + 1
+			for ; j < len(source) && source[j] != c; j++ {
+				if source[j] == '\\' && j+1 < len(source) {
+					j++
+				}
+				text.WriteByte(source[j])
+			}
+			if j >= len(source) {
+				return nil, errors.New(fmt.Sprintf("unterminated string at position %d", i+1))
+			}
+			tokens = append(tokens, token{kind: "string", text: source[i : j+1], value: text.String(), pos: i})
+			i = j + 1
+		case c >= '0' && c <= '9' || c == '-' && i+1 < len(source) && source[i+1] >= '0' && source[i+1] <= '9':
+			j := i + 1
+			for j < len(source) && (source[j] >= '0' && source[j] <= '9' || source[j] == '.') {
+				j++
+			}
+			value, err := strconv.ParseFloat(source[i:j], 64)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("invalid number %q at position %d", source[i:j], i+1))
+			}
+			tokens = append(tokens, token{kind: "number", text: source[i:j], value: value, pos: i})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(source) && (source[j] == '_' || source[j] >= 'a' && source[j] <= 'z' || source[j] >= 'A' && source[j] <= 'Z' || source[j] >= '0' && source[j] <= '9') {
+				j++
+			}
+			tokens = append(tokens, token{kind: "ident", text: source[i:j], pos: i})
+			i = j
+		default:
+			matched := false
+			for _, operator := range operators {
+				if strings.HasPrefix(source[i:], operator) {
+					tokens = append(tokens, token{kind: operator, text: operator, pos: i})
+					i += len(operator)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, errors.New(fmt.Sprintf("unexpected %q at position %d", string(c), i+1))
+			}
+		}
+	}
+
+	return append(tokens, token{kind: "end", text: "end of expression", pos: len(source)}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != "end" {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) expect(kind string) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, errors.New(fmt.Sprintf("expected %q but found %q at position %d", kind, t.text, t.pos+1))
+	}
+
+	return t, nil
+}
+
+func truthy(values []interface{}) bool {
+	for _, value := range values {
+		if value != nil && value != false {
+			return true
+		}
+	}
+
+	return false
+}
+
+func boolean(value bool) []interface{} {
+	return []interface{}{value}
+}
+
+// Compile parses an expression so it can be evaluated against many resources.
+func Compile(source string) (Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return Expression{}, err
+	}
+
+	p := &parser{tokens: tokens}
+	evaluate, err := p.parseOr()
+	if err != nil {
+		return Expression{}, err
+	}
+	if t := p.peek(); t.kind != "end" {
+		return Expression{}, errors.New(fmt.Sprintf("unexpected %q at position %d", t.text, t.pos+1))
+	}
+
+	return Expression{source: source, evaluate: evaluate}, nil
+}
+
+// Matches evaluates the expression against a document decoded from JSON.
+func (e Expression) Matches(document interface{}) bool {
+	return truthy(e.evaluate(context{root: document, current: document}))
+}
+
+func (e Expression) String() string {
+	return e.source
+}
+
+func (p *parser) parseOr() (evaluator, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(ctx context) []interface{} {
+			return boolean(truthy(l(ctx)) || truthy(right(ctx)))
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (evaluator, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == "&&" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(ctx context) []interface{} {
+			return boolean(truthy(l(ctx)) && truthy(right(ctx)))
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseUnary() (evaluator, error) {
+	if p.peek().kind == "!" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context) []interface{} {
+			return boolean(!truthy(operand(ctx)))
+		}, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (evaluator, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	operator := p.peek().kind
+	switch operator {
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context) []interface{} {
+			for _, l := range left(ctx) {
+				for _, r := range right(ctx) {
+					if compare(operator, l, r) {
+						return boolean(true)
+					}
+				}
+			}
+			return boolean(false)
+		}, nil
+	case "=~":
+		p.next()
+		t, err := p.expect("string")
+		if err != nil {
+			return nil, err
+		}
+		pattern, err := regexp.Compile(t.value.(string))
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid regular expression %s: %s", t.text, err.Error()))
+		}
+		return func(ctx context) []interface{} {
+			for _, l := range left(ctx) {
+				if s, ok := l.(string); ok && pattern.MatchString(s) {
+					return boolean(true)
+				}
+			}
+			return boolean(false)
+		}, nil
+	}
+
+	return left, nil
+}
+
+// scalar reports whether a decoded JSON value can be compared with ==. Objects and arrays cannot.
+func scalar(value interface{}) bool {
+	switch value.(type) {
+	case nil, bool, float64, string:
+		return true
+	}
+
+	return false
+}
+
+func compare(operator string, left interface{}, right interface{}) bool {
+	switch operator {
+	case "==":
+		return scalar(left) && scalar(right) && left == right
+	case "!=":
+		return scalar(left) && scalar(right) && left != right
+	}
+
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if !lok || !rok {
+		return false
+	}
+
+	switch operator {
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	default:
+		return l >= r
+	}
+}
+
+func (p *parser) parseOperand() (evaluator, error) {
+	t := p.next()
+	switch t.kind {
+	case "(":
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.expect(")")
+		return inner, err
+	case "string", "number":
+		value := t.value
+		return func(ctx context) []interface{} {
+			return []interface{}{value}
+		}, nil
+	case "$", "@":
+		return p.parsePath(t.kind == "@")
+	case "ident":
+		switch t.text {
+		case "true", "false":
+			value := t.text == "true"
+			return func(ctx context) []interface{} {
+				return []interface{}{value}
+			}, nil
+		case "null":
+			return func(ctx context) []interface{} {
+				return []interface{}{nil}
+			}, nil
+		case "exists", "count", "any", "all":
+			return p.parseFunction(t.text)
+		}
+		return nil, errors.New(fmt.Sprintf("unknown name %q at position %d, paths start with $ or @", t.text, t.pos+1))
+	}
+
+	return nil, errors.New(fmt.Sprintf("unexpected %q at position %d", t.text, t.pos+1))
+}
+
+func (p *parser) parseFunction(name string) (evaluator, error) {
+	_, err := p.expect("(")
+	if err != nil {
+		return nil, err
+	}
+
+	start := p.next()
+	if start.kind != "$" && start.kind != "@" {
+		return nil, errors.New(fmt.Sprintf("%s() takes a path, found %q at position %d", name, start.text, start.pos+1))
+	}
+	path, err := p.parsePath(start.kind == "@")
+	if err != nil {
+		return nil, err
+	}
+
+	var result evaluator
+	switch name {
+	case "exists":
+		result = func(ctx context) []interface{} {
+			return boolean(len(path(ctx)) > 0)
+		}
+	case "count":
+		result = func(ctx context) []interface{} {
+			return []interface{}{float64(len(path(ctx)))}
+		}
+	default:
+		_, err = p.expect(",")
+		if err != nil {
+			return nil, err
+		}
+		condition, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		every := name == "all"
+		result = func(ctx context) []interface{} {
+			for _, value := range path(ctx) {
+				if truthy(condition(context{root: ctx.root, current: value})) != every {
+					return boolean(!every)
+				}
+			}
+			return boolean(every)
+		}
+	}
+
+	_, err = p.expect(")")
+
+	return result, err
+}
+
+type segment struct {
+	field    string
+	index    int
+	wildcard bool
+}
+
+func (p *parser) parsePath(current bool) (evaluator, error) {
+	var segments []segment
+	for {
+		switch p.peek().kind {
+		case ".":
+			p.next()
+			t := p.next()
+			switch t.kind {
+			case "ident":
+				segments = append(segments, segment{field: t.text, index: -1})
+			case "*":
+				segments = append(segments, segment{index: -1, wildcard: true})
+			default:
+				return nil, errors.New(fmt.Sprintf("expected a field name after \".\" but found %q at position %d", t.text, t.pos+1))
+			}
+			continue
+		case "[":
+			p.next()
+			t := p.next()
+			switch t.kind {
+			case "*":
+				segments = append(segments, segment{index: -1, wildcard: true})
+			case "string":
+				segments = append(segments, segment{field: t.value.(string), index: -1})
+			case "number":
+				index := t.value.(float64)
+				if index < 0 || index != float64(int(index)) {
+					return nil, errors.New(fmt.Sprintf("invalid index %s at position %d", t.text, t.pos+1))
+				}
+				segments = append(segments, segment{index: int(index)})
+			default:
+				return nil, errors.New(fmt.Sprintf("expected an index, a quoted field name or * but found %q at position %d", t.text, t.pos+1))
+			}
+			_, err := p.expect("]")
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		break
+	}
+
+	return func(ctx context) []interface{} {
+		values := []interface{}{ctx.root}
+		if current {
+			values = []interface{}{ctx.current}
+		}
+		for _, s := range segments {
+			values = s.apply(values)
+		}
+		return values
+	}, nil
+}
+
+func (s segment) apply(values []interface{}) []interface{} {
+	var result []interface{}
+	for _, value := range values {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if s.wildcard {
+				var keys []string
+				for key := range v {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				for _, key := range keys {
+					result = append(result, v[key])
+				}
+			} else if field, found := v[s.field]; found && s.index < 0 {
+				result = append(result, field)
+			}
+		case []interface{}:
+			if s.wildcard {
+				result = append(result, v...)
+			} else if s.index >= 0 && s.index < len(v) {
+				result = append(result, v[s.index])
+			}
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,268 @@
+package rules
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const testDocument = `{
+	"name": "vm-web-01",
+	"resourceGroup": "rg-web-prod-01",
+	"tags": {"environment": "prod", "cost-centre": "42"},
+	"alertRules": [
+		{"name": "cpu", "criteria": {"enabled": true, "allOf": [{"metricName": "Percentage CPU", "threshold": 80}]}},
+		{"name": "disk", "criteria": {"enabled": false, "allOf": [{"metricName": "Disk Queue", "threshold": 90}, {"metricName": "Disk Read", "threshold": 95}]}}
+	],
+	"backupVault": null,
+	"cores": 4,
+	"ratio": 0.5,
+	"spot": false
+}`
+
+func decode(t *testing.T, source string) interface{} {
+	t.Helper()
+
+	var document interface{}
+	err := json.Unmarshal([]byte(source), &document)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return document
+}
+
+func TestExpressionMatches(t *testing.T) {
+	document := decode(t, testDocument)
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		// Paths and literals.
+		{"$.name == 'vm-web-01'", true},
+		{`$.name == "vm-web-01"`, true},
+		{"$.name != 'vm-web-02'", true},
+		{"$.tags.environment == 'prod'", true},
+		{"$.tags['cost-centre'] == '42'", true},
+		{"$.alertRules[0].name == 'cpu'", true},
+		{"$.alertRules[1].name == 'cpu'", false},
+		{"$.alertRules[*].name == 'disk'", true},
+		{"$.alertRules[*].criteria.allOf[*].threshold == 95", true},
+		{"$.tags.* == 'prod'", true},
+		{"$.tags[*] == '42'", true},
+		{"$.backupVault == null", true},
+		{"$.spot == false", true},
+		{"$.name", true},
+		{"$.spot", false},
+		{"$.backupVault", false},
+		{"true", true},
+		{"null", false},
+		{"'text'", true},
+
+		// Numbers.
+		{"$.cores == 4", true},
+		{"$.cores > 3 && $.cores >= 4 && $.cores < 5 && $.cores <= 4", true},
+		{"$.ratio < 1", true},
+		{"$.cores > -1", true},
+		{"$.cores != 4.5", true},
+
+		// Regular expressions.
+		{"$.resourceGroup =~ '-prod-'", true},
+		{"$.name =~ '(?i)^VM-'", true},
+		{"$.name =~ '^VM-'", false},
+		{`$.name =~ 'web\-01$'`, true},
+
+		// Logic.
+		{"!($.cores == 4)", false},
+		{"!!$.name", true},
+		{"$.cores == 1 || $.cores == 4", true},
+		{"$.cores == 4 && $.name == 'other'", false},
+		{"$.cores == 1 || $.cores == 2 && $.cores == 4", false},
+		{"($.cores == 1 || $.cores == 4) && $.spot == false", true},
+
+		// Functions.
+		{"exists($.tags.environment)", true},
+		{"exists($.tags.owner)", false},
+		{"count($.alertRules[*]) == 2", true},
+		{"count($.alertRules[*].criteria.allOf[*]) >= 3", true},
+		{"count($.tags.*) == 2", true},
+		{"any($.alertRules[*], @.criteria.enabled == false)", true},
+		{"all($.alertRules[*], @.criteria.enabled == true)", false},
+		{"all($.alertRules[*], exists(@.name))", true},
+		{"any($.alertRules[*], @.name == 'cpu' && all(@.criteria.allOf[*], @.threshold <= 80))", true},
+		{"any($.alertRules[*], any(@.criteria.allOf[*], @.metricName =~ '^Disk') && $.cores == 4)", true},
+		{"all($.missing[*], @.name == 'x')", true},
+		{"any($.missing[*], true)", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			expression, err := Compile(test.expression)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := expression.Matches(document); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+			if expression.String() != test.expression {
+				t.Errorf("String() = %q", expression.String())
+			}
+		})
+	}
+}
+
+func TestExpressionMissingPaths(t *testing.T) {
+	document := decode(t, testDocument)
+
+	// A path that matches nothing is false on its own and fails every comparison, != included.
+	for _, source := range []string{
+		"$.owner",
+		"$.owner == null",
+		"$.owner != 'x'",
+		"$.owner < 1 || $.owner >= 1",
+		"$.owner =~ '.*'",
+		"$.tags.owner == 'x'",
+		"$.name.first == 'vm'",
+		"$.alertRules[5].name == 'cpu'",
+		"$.alertRules.name == 'cpu'",
+		"$.tags[0] == 'prod'",
+		"$.cores[*] == 4",
+		"count($.owner[*]) > 0",
+		"exists($.alertRules[2])",
+	} {
+		t.Run(source, func(t *testing.T) {
+			expression, err := Compile(source)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if expression.Matches(document) {
+				t.Error("matched, want no match")
+			}
+		})
+	}
+}
+
+func TestExpressionTypeMismatches(t *testing.T) {
+	document := decode(t, testDocument)
+
+	// Values of different types never compare equal or ordered, and objects and arrays never compare at all.
+	for _, source := range []string{
+		"$.cores == '4'",
+		"$.name > 1",
+		"$.name < 'z'",
+		"$.spot == 'false'",
+		"$.spot == 0",
+		"$.backupVault == false",
+		"$.tags == 'prod'",
+		"$.tags != 'prod'",
+		"$.alertRules == 2",
+		"$.alertRules != null",
+		"$.cores =~ '4'",
+		"$.tags =~ 'prod'",
+		"count($.alertRules[*]) == '2'",
+	} {
+		t.Run(source, func(t *testing.T) {
+			expression, err := Compile(source)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if expression.Matches(document) {
+				t.Error("matched, want no match")
+			}
+		})
+	}
+}
+
+func TestExpressionNonObjectDocuments(t *testing.T) {
+	expression, err := Compile("$.name == 'x' || count($.tags.*) > 0 || any($[*], @.name == 'x')")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, document := range []interface{}{nil, "text", 4.0, true, []interface{}{}, map[string]interface{}{}} {
+		if expression.Matches(document) {
+			t.Errorf("matched %v, want no match", document)
+		}
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		message    string
+	}{
+		{"", `unexpected "end of expression" at position 1`},
+		{"   ", "unexpected"},
+		{"$.name ==", `unexpected "end of expression"`},
+		{"== 'x'", `unexpected "==" at position 1`},
+		{"$.name == 'x", "unterminated string at position 11"},
+		{`$.name == "x\"`, "unterminated string"},
+		{"$.name == 'x' extra", `unexpected "extra"`},
+		{"$.name 'x'", `unexpected "'x'"`},
+		{"name == 'x'", `unknown name "name" at position 1, paths start with $ or @`},
+		{"$.name # 'x'", `unexpected "#" at position 8`},
+		{"$.name = 'x'", `unexpected "="`},
+		{"$.cores == 1.2.3", `invalid number "1.2.3"`},
+		{"$.", `expected a field name after "." but found "end of expression"`},
+		{"$.'name'", `expected a field name after "."`},
+		{"$.tags[", "expected an index, a quoted field name or *"},
+		{"$.tags['x'", `expected "]"`},
+		{"$.alertRules[1.5]", "invalid index 1.5"},
+		{"$.alertRules[-1]", "invalid index -1"},
+		{"$.alertRules[name]", "expected an index"},
+		{"$.name =~ 5", `expected "string" but found "5"`},
+		{"$.name =~ '('", "invalid regular expression '('"},
+		{"($.name == 'x'", `expected ")" but found "end of expression"`},
+		{"$.name == 'x')", `unexpected ")"`},
+		{"!", `unexpected "end of expression"`},
+		{"$.name == 'x' &&", `unexpected "end of expression"`},
+		{"|| $.name", `unexpected "||"`},
+		{"exists", `expected "(" but found "end of expression"`},
+		{"exists('x')", `exists() takes a path, found "'x'"`},
+		{"exists($.name", `expected ")"`},
+		{"count()", "count() takes a path"},
+		{"any($.alertRules[*])", `expected "," but found ")"`},
+		{"any($.alertRules[*], )", `unexpected ")"`},
+		{"all($.alertRules[*], @.name == 'x'", `expected ")"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expression, func(t *testing.T) {
+			_, err := Compile(test.expression)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), test.message) {
+				t.Errorf("got %q, want it to contain %q", err.Error(), test.message)
+			}
+		})
+	}
+}
+
+// TestCompileTruncated compiles every prefix of a set of expressions, which is what a half-written rule looks like.
+// None of them may panic, and those that compile must evaluate without panicking too.
+func TestCompileTruncated(t *testing.T) {
+	document := decode(t, testDocument)
+
+	for _, source := range []string{
+		"any($.alertRules[*], @.name == 'cpu' && all(@.criteria.allOf[*], @.threshold <= 80))",
+		"!($.tags['cost-centre'] =~ '^4' || count($.tags.*) >= 2) && exists(@.name)",
+		`$.name == "vm\"web" || $.cores > -1.5`,
+	} {
+		for i := 0; i <= len(source); i++ {
+			prefix := source[:i]
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Errorf("%q panicked: %v", prefix, r)
+					}
+				}()
+				expression, err := Compile(prefix)
+				if err == nil {
+					expression.Matches(document)
+				}
+			}()
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jayps/azure-checker-go/azure"
+	"github.com/jayps/azure-checker-go/scan"
+)
+
+// Severities of a finding, most severe first.
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+	SeverityInfo   = "info"
+)
+
+var Severities = []string{SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo}
+
+var severityOrder = map[string]int{SeverityHigh: 0, SeverityMedium: 1, SeverityLow: 2, SeverityInfo: 3}
+
+// AdvisorRecommendations selects the advisor recommendations of a subscription instead of a resource type.
+const AdvisorRecommendations = "advisorRecommendations"
+
+// Target is what a rule is evaluated against: a collected resource, or an advisor recommendation for rules that
+// select AdvisorRecommendations.
+type Target struct {
+	ResourceType   string
+	Resource       azure.Resource
+	Recommendation azure.AdvisorRecommendation
+	Environment    string
+}
+
+// Violation is something a rule flagged on a target. Detail tells several violations on one target apart, e.g. the
+// ID of an outstanding patch. Severity and Remediation override those of the rule when they are set.
+type Violation struct {
+	Detail      string
+	Message     string
+	Severity    string
+	Remediation string
+}
+
+// Rule is a named check of the collected resources.
+type Rule struct {
+	Id            string
+	Title         string
+	Check         string   // the check in scan.Settings that switches the rule on and off
+	Severity      string   // severity of the findings unless a violation overrides it
+	ResourceTypes []string // resource types the rule applies to, e.g. scan.VirtualMachines
+	Environments  []string // environments the rule applies to, all of them when empty
+	Evaluate      func(target Target) []Violation
+	Remediation   string
+}
+
+// Finding is a violation of a rule by a resource. Key identifies the same finding across scans.
+type Finding struct {
+	Key          string
+	RuleId       string
+	Title        string
+	Check        string
+	Severity     string
+	ResourceType string
+	ResourceId   string
+	ResourceName string
+	Environment  string
+	Tags         map[string]string
+	Message      string
+	Remediation  string
+	Waiver       *Waiver // the waiver covering the finding, if any
+}
+
+// Finding statuses. A finding with an expired waiver is reported like an open one, with a note about the waiver.
+const (
+	StatusOpen          = "Open"
+	StatusAccepted      = "Accepted risk"
+	StatusWaiverExpired = "Waiver expired"
+)
+
+func (f Finding) Status() string {
+	switch {
+	case f.Waiver == nil:
+		return StatusOpen
+	case f.Waiver.expired:
+		return StatusWaiverExpired
+	default:
+		return StatusAccepted
+	}
+}
+
+// Accepted reports whether the finding is covered by a waiver that has not expired.
+func (f Finding) Accepted() bool {
+	return f.Status() == StatusAccepted
+}
+
+func (r Rule) appliesTo(resourceType string) bool {
+	for _, t := range r.ResourceTypes {
+		if t == resourceType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r Rule) targets(result scan.Result, environments Environments) []Target {
+	var targets []Target
+	resources := result.Resources()
+	for _, resourceType := range scan.ResourceTypes {
+		if !r.appliesTo(resourceType) {
+			continue
+		}
+		for _, resource := range resources[resourceType] {
+			environment := environments.Classify(resource.Tags)
+			if len(r.Environments) > 0 && !contains(r.Environments, environment) {
+				continue
+			}
+			targets = append(targets, Target{ResourceType: resourceType, Resource: resource, Environment: environment})
+		}
+	}
+
+	// Recommendations carry no tags, they are classified by the tags of the resource they are about.
+	if r.appliesTo(AdvisorRecommendations) {
+		tags := make(map[string]map[string]string)
+		for _, resourceType := range scan.ResourceTypes {
+			for _, resource := range resources[resourceType] {
+				tags[strings.ToLower(resource.ResourceGroup+"/"+resource.Name)] = resource.Tags
+			}
+		}
+		for _, recommendations := range result.Recommendations {
+			for _, recommendation := range recommendations {
+				environment := environments.Classify(tags[strings.ToLower(recommendation.ResourceGroup+"/"+recommendation.AffectedResource)])
+				if len(r.Environments) > 0 && !contains(r.Environments, environment) {
+					continue
+				}
+				targets = append(targets, Target{ResourceType: AdvisorRecommendations, Recommendation: recommendation, Environment: environment})
+			}
+		}
+	}
+
+	return targets
+}
+
+func (r Rule) finding(target Target, violation Violation) Finding {
+	result := Finding{
+		RuleId:       r.Id,
+		Title:        r.Title,
+		Check:        r.Check,
+		Severity:     r.Severity,
+		ResourceType: target.ResourceType,
+		ResourceId:   strings.ToLower(target.Resource.Id),
+		ResourceName: target.Resource.Name,
+		Environment:  target.Environment,
+		Tags:         target.Resource.Tags,
+		Message:      violation.Message,
+		Remediation:  r.Remediation,
+	}
+	if violation.Severity != "" {
+		result.Severity = violation.Severity
+	}
+	if violation.Remediation != "" {
+		result.Remediation = violation.Remediation
+	}
+	if target.ResourceType == AdvisorRecommendations {
+		result.ResourceName = target.Recommendation.AffectedResource
+	}
+
+	result.Key = fmt.Sprintf("%s|%s", r.Id, result.ResourceId)
+	if violation.Detail != "" {
+		result.Key += "|" + strings.ToLower(violation.Detail)
+	}
+
+	return result
+}
+
+// Evaluate runs the rules against a scan, classifying the resources into environments. Rules whose check was switched
+// off or could not be completed are skipped, and so are the resources a check failed for, so a failed collection does
+// not read as every resource failing. Findings are sorted by severity, rule and resource.
+func Evaluate(rules []Rule, snapshot scan.Snapshot, environments Environments) []Finding {
+	var findings []Finding
+	for _, rule := range rules {
+		if !snapshot.Ran(rule.Check) {
+			continue
+		}
+
+		for _, target := range rule.targets(snapshot.Result, environments) {
+			if !snapshot.RanFor(rule.Check, target.Resource.Id) {
+				continue
+			}
+			for _, violation := range rule.Evaluate(target) {
+				findings = append(findings, rule.finding(target, violation))
+			}
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Severity != b.Severity {
+			return severityOrder[a.Severity] < severityOrder[b.Severity]
+		}
+		if a.RuleId != b.RuleId {
+			return a.RuleId < b.RuleId
+		}
+		if !strings.EqualFold(a.ResourceName, b.ResourceName) {
+			return strings.ToLower(a.ResourceName) < strings.ToLower(b.ResourceName)
+		}
+
+		return a.Key < b.Key
+	})
+
+	return findings
+}
+
+// ForResource returns the findings of a rule on a resource.
+func ForResource(findings []Finding, ruleId string, resourceId string) []Finding {
+	var result []Finding
+	for _, finding := range findings {
+		if finding.RuleId == ruleId && finding.ResourceId == strings.ToLower(resourceId) {
+			result = append(result, finding)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,169 @@
+package rules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const waiverDateFormat = "2006-01-02"
+
+// Waiver accepts the risk of a rule's findings on the resources it selects, until it expires. Resource is a resource
+// ID or name, or a pattern for either, e.g. "vm-veeam-*". Tag is a tag selector like in Environments. A waiver
+// with both only selects resources matching both.
+type Waiver struct {
+	Rule          string `yaml:"rule"`
+	Resource      string `yaml:"resource,omitempty"`
+	Tag           string `yaml:"tag,omitempty"`
+	Justification string `yaml:"justification"`
+	Approver      string `yaml:"approver"`
+	Expires       string `yaml:"expires"` // the last day the waiver applies, e.g. 2024-12-31
+
+	expiresAt time.Time
+	expired   bool
+}
+
+type waiverFile struct {
+	Waivers []Waiver `yaml:"waivers"`
+}
+
+// ExpiresAt returns the end of the last day the waiver applies.
+func (w Waiver) ExpiresAt() time.Time {
+	return w.expiresAt
+}
+
+func (w Waiver) String() string {
+	selector := w.Resource
+	if w.Tag != "" {
+		selector = strings.TrimSpace(fmt.Sprintf("%s tag %s", selector, w.Tag))
+	}
+
+	return fmt.Sprintf("%s on %s", w.Rule, selector)
+}
+
+func (w *Waiver) validate() error {
+	if w.Rule == "" {
+		return errors.New("waiver without a rule")
+	}
+	if w.Resource == "" && w.Tag == "" {
+		return errors.New(fmt.Sprintf("waiver for %s needs a resource or tag", w.Rule))
+	}
+	if w.Resource != "" {
+		_, err := path.Match(strings.ToLower(w.Resource), "")
+		if err != nil {
+			return errors.New(fmt.Sprintf("waiver for %s has an invalid resource pattern %q: %s", w.Rule, w.Resource, err.Error()))
+		}
+	}
+	if w.Tag != "" {
+		err := validateTagSelector(w.Tag)
+		if err != nil {
+			return errors.New(fmt.Sprintf("waiver for %s: %s", w.Rule, err.Error()))
+		}
+	}
+	if strings.TrimSpace(w.Justification) == "" {
+		return errors.New(fmt.Sprintf("waiver %s needs a justification", w))
+	}
+	if strings.TrimSpace(w.Approver) == "" {
+		return errors.New(fmt.Sprintf("waiver %s needs an approver", w))
+	}
+
+	expires, err := time.Parse(waiverDateFormat, w.Expires)
+	if err != nil {
+		return errors.New(fmt.Sprintf("waiver %s needs an expiry date like 2024-12-31, got %q", w, w.Expires))
+	}
+	w.expiresAt = expires.AddDate(0, 0, 1).Add(-time.Nanosecond)
+
+	return nil
+}
+
+func (w Waiver) matches(finding Finding) bool {
+	if !strings.EqualFold(w.Rule, finding.RuleId) {
+		return false
+	}
+
+	if w.Resource != "" {
+		pattern := strings.ToLower(w.Resource)
+		matchedId, _ := path.Match(pattern, finding.ResourceId)
+		matchedName, _ := path.Match(pattern, strings.ToLower(finding.ResourceName))
+		if !matchedId && !matchedName {
+			return false
+		}
+	}
+
+	return w.Tag == "" || matchesTag(w.Tag, finding.Tags)
+}
+
+// LoadWaivers reads the waivers in YAML waiver files.
+func LoadWaivers(paths []string) ([]Waiver, error) {
+	var result []Waiver
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		var contents waiverFile
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&contents)
+		if err != nil && err != io.EOF {
+			return nil, errors.New(fmt.Sprintf("invalid waiver file %s: %s", p, err.Error()))
+		}
+
+		for _, waiver := range contents.Waivers {
+			err = waiver.validate()
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("invalid waiver file %s: %s", p, err.Error()))
+			}
+			result = append(result, waiver)
+		}
+	}
+
+	return result, nil
+}
+
+// CheckWaivers makes sure every waiver is for a known rule, so a misspelt rule ID does not leave findings open.
+func CheckWaivers(waivers []Waiver, rules []Rule) error {
+	for _, waiver := range waivers {
+		known := false
+		for _, rule := range rules {
+			if strings.EqualFold(rule.Id, waiver.Rule) {
+				known = true
+			}
+		}
+		if !known {
+			return errors.New(fmt.Sprintf("waiver %s is for an unknown rule", waiver))
+		}
+	}
+
+	return nil
+}
+
+// ApplyWaivers marks the findings covered by a waiver as of the time of the scan. A waiver that is still valid wins
+// over one that expired, and of several expired waivers the latest is reported.
+func ApplyWaivers(findings []Finding, waivers []Waiver, at time.Time) []Finding {
+	result := make([]Finding, len(findings))
+	for i, finding := range findings {
+		for _, waiver := range waivers {
+			if !waiver.matches(finding) {
+				continue
+			}
+
+			w := waiver
+			w.expired = at.After(w.expiresAt)
+			if finding.Waiver == nil || (finding.Waiver.expired && (!w.expired || w.expiresAt.After(finding.Waiver.expiresAt))) {
+				finding.Waiver = &w
+			}
+		}
+		result[i] = finding
+	}
+
+	return result
+}
@@ -0,0 +1,101 @@
+package rules
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func waiver(t *testing.T, resource string, expires string) Waiver {
+	t.Helper()
+
+	w := Waiver{Rule: RuleNotBackedUp, Resource: resource, Justification: "Backed up by Veeam", Approver: "J. Smith", Expires: expires}
+	err := w.validate()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	return w
+}
+
+var waivedFinding = Finding{
+	RuleId:       RuleNotBackedUp,
+	ResourceId:   "/subscriptions/00000000-0000-0000-0000-000000000001/resourcegroups/rg-web/providers/microsoft.compute/virtualmachines/vm-veeam-01",
+	ResourceName: "vm-veeam-01",
+}
+
+func TestWaiverExpiry(t *testing.T) {
+	// The expiry date is the last day the waiver applies, up to the end of that day in UTC.
+	tests := []struct {
+		name   string
+		at     time.Time
+		status string
+	}{
+		{"a month before", time.Date(2024, 11, 30, 12, 0, 0, 0, time.UTC), StatusAccepted},
+		{"start of the last day", time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), StatusAccepted},
+		{"end of the last day", time.Date(2024, 12, 31, 23, 59, 59, 999999999, time.UTC), StatusAccepted},
+		{"start of the next day", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), StatusWaiverExpired},
+		{"a year later", time.Date(2025, 12, 31, 12, 0, 0, 0, time.UTC), StatusWaiverExpired},
+		{"last day west of UTC", time.Date(2024, 12, 31, 20, 0, 0, 0, time.FixedZone("EST", -5*60*60)), StatusWaiverExpired},
+		{"next day east of UTC", time.Date(2025, 1, 1, 1, 0, 0, 0, time.FixedZone("SAST", 2*60*60)), StatusAccepted},
+	}
+
+	waivers := []Waiver{waiver(t, "vm-veeam-*", "2024-12-31")}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			finding := ApplyWaivers([]Finding{waivedFinding}, waivers, test.at)[0]
+
+			if finding.Status() != test.status {
+				t.Errorf("got %q, want %q", finding.Status(), test.status)
+			}
+			if finding.Accepted() != (test.status == StatusAccepted) {
+				t.Errorf("Accepted() = %t with status %q", finding.Accepted(), finding.Status())
+			}
+			if finding.Waiver == nil || finding.Waiver.Expires != "2024-12-31" {
+				t.Errorf("got waiver %v, want the expired waiver kept on the finding", finding.Waiver)
+			}
+		})
+	}
+}
+
+func TestWaiverPrecedence(t *testing.T) {
+	at := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		waivers []Waiver
+		expires string
+		status  string
+	}{
+		{"no waiver", nil, "", StatusOpen},
+		{"other resource", []Waiver{waiver(t, "vm-web-*", "2024-12-31")}, "", StatusOpen},
+		{"valid wins over expired", []Waiver{waiver(t, "vm-veeam-01", "2024-01-31"), waiver(t, "vm-veeam-*", "2024-12-31")}, "2024-12-31", StatusAccepted},
+		{"valid kept over a later expired one", []Waiver{waiver(t, "vm-veeam-*", "2024-12-31"), waiver(t, "vm-veeam-01", "2024-01-31")}, "2024-12-31", StatusAccepted},
+		{"latest expired is reported", []Waiver{waiver(t, "vm-veeam-01", "2024-01-31"), waiver(t, "vm-veeam-*", "2024-03-31"), waiver(t, "*", "2023-12-31")}, "2024-03-31", StatusWaiverExpired},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			finding := ApplyWaivers([]Finding{waivedFinding}, test.waivers, at)[0]
+
+			if finding.Status() != test.status {
+				t.Errorf("got %q, want %q", finding.Status(), test.status)
+			}
+			if test.expires != "" && (finding.Waiver == nil || finding.Waiver.Expires != test.expires) {
+				t.Errorf("got waiver %v, want the one expiring %s", finding.Waiver, test.expires)
+			}
+		})
+	}
+}
+
+func TestWaiverExpiryValidation(t *testing.T) {
+	for _, expires := range []string{"", "31/12/2024", "2024-12-31T00:00:00Z", "2024-02-30", "tomorrow"} {
+		t.Run(expires, func(t *testing.T) {
+			w := Waiver{Rule: RuleNotBackedUp, Resource: "vm-veeam-*", Justification: "Backed up by Veeam", Approver: "J. Smith", Expires: expires}
+			err := w.validate()
+			if err == nil || !strings.Contains(err.Error(), "needs an expiry date like 2024-12-31") {
+				t.Errorf("got %v, want an expiry date error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,267 @@
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/jayps/azure-checker-go/azure"
+)
+
+// Problem is a check that could not be completed. Problems are reported alongside the results instead of
+// stopping the run.
+type Problem struct {
+	SubscriptionId string          `json:"subscriptionId"`
+	Check          string          `json:"check"`
+	Kind           azure.ErrorKind `json:"kind"`
+	Message        string          `json:"message"`
+	Hint           string          `json:"hint,omitempty"`
+	ResourceId     string          `json:"resourceId,omitempty"` // the resource the check failed for, empty when it failed for the subscription
+}
+
+// Names of the resource types and checks that can be switched off in Settings.
+const (
+	VirtualMachines            = "virtualMachines"
+	VirtualMachinesDeallocated = "deallocatedVirtualMachines"
+	AKSClusters                = "aksClusters"
+	MySQLServers               = "mysqlServers"
+	FlexibleMySQLServers       = "flexibleMysqlServers"
+	SQLServers                 = "sqlServers"
+	StorageAccounts            = "storageAccounts"
+	WebApps                    = "webApps"
+
+	CheckAlertRules      = "alertRules"
+	CheckBackups         = "backups"
+	CheckPatches         = "patches"
+	CheckRecommendations = "recommendations"
+)
+
+var ResourceTypes = []string{VirtualMachines, VirtualMachinesDeallocated, AKSClusters, MySQLServers, FlexibleMySQLServers, SQLServers, StorageAccounts, WebApps}
+
+var Checks = []string{CheckAlertRules, CheckBackups, CheckPatches, CheckRecommendations}
+
+// Descriptions explains the resource types and checks, e.g. for the list-checks command.
+var Descriptions = map[string]string{
+	VirtualMachines:            "Running virtual machines",
+	VirtualMachinesDeallocated: "Deallocated virtual machines that are still provisioned",
+	AKSClusters:                "Azure Kubernetes Service clusters",
+	MySQLServers:               "Azure Database for MySQL single servers",
+	FlexibleMySQLServers:       "Azure Database for MySQL flexible servers",
+	SQLServers:                 "Azure SQL servers",
+	StorageAccounts:            "Storage accounts",
+	WebApps:                    "App Service web apps, excluding function apps",
+
+	CheckAlertRules:      "Metric alert rules configured for each resource",
+	CheckBackups:         "Azure Backup protection of running virtual machines",
+	CheckPatches:         "Outstanding patches on running virtual machines",
+	CheckRecommendations: "Azure Advisor recommendations by category",
+}
+
+// Settings switches resource types and checks off. The zero value collects and checks everything.
+type Settings struct {
+	Disabled map[string]bool `json:"disabled,omitempty"`
+}
+
+func (s Settings) Enabled(name string) bool {
+	return !s.Disabled[name]
+}
+
+// Result holds everything collected for a single subscription.
+type Result struct {
+	SubscriptionId             string                                   `json:"subscriptionId"`
+	SubscriptionName           string                                   `json:"subscriptionName,omitempty"`
+	TenantId                   string                                   `json:"tenantId,omitempty"`
+	VirtualMachines            map[string]azure.Resource                `json:"virtualMachines"`
+	VirtualMachinesDeallocated map[string]azure.Resource                `json:"deallocatedVirtualMachines"`
+	AzureKubernetesServices    map[string]azure.Resource                `json:"aksClusters"`
+	MySQLServers               map[string]azure.Resource                `json:"mysqlServers"`
+	FlexibleMySQLServers       map[string]azure.Resource                `json:"flexibleMysqlServers"`
+	SqlServers                 map[string]azure.Resource                `json:"sqlServers"`
+	StorageAccounts            map[string]azure.Resource                `json:"storageAccounts"`
+	WebApps                    map[string]azure.Resource                `json:"webApps"`
+	AlertRules                 []azure.AlertRule                        `json:"alertRules"`
+	Recommendations            map[string][]azure.AdvisorRecommendation `json:"recommendations"`
+	Problems                   []Problem                                `json:"problems"`
+}
+
+// Resources returns the collected resources keyed by resource type name, e.g. VirtualMachines.
+func (r Result) Resources() map[string]map[string]azure.Resource {
+	return map[string]map[string]azure.Resource{
+		VirtualMachines:            r.VirtualMachines,
+		VirtualMachinesDeallocated: r.VirtualMachinesDeallocated,
+		AKSClusters:                r.AzureKubernetesServices,
+		MySQLServers:               r.MySQLServers,
+		FlexibleMySQLServers:       r.FlexibleMySQLServers,
+		SQLServers:                 r.SqlServers,
+		StorageAccounts:            r.StorageAccounts,
+		WebApps:                    r.WebApps,
+	}
+}
+
+// resourceChecks maps checks to the name their problem is recorded under, followed by the resource name, when the
+// check failed for a single resource.
+var resourceChecks = map[string]string{
+	CheckBackups: "backups for VM",
+	CheckPatches: "patches for VM",
+}
+
+// Failed reports whether a check could not be completed for a single resource, e.g. the backup status of one VM.
+func (r Result) Failed(check string, resourceId string) bool {
+	name, ok := resourceChecks[check]
+	if !ok || resourceId == "" {
+		return false
+	}
+
+	for _, problem := range r.Problems {
+		if strings.EqualFold(problem.ResourceId, resourceId) && strings.HasPrefix(problem.Check, name+" ") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Result) AddProblem(check string, err error) {
+	r.addProblem(check, "", err)
+}
+
+// addResourceProblem records that a check could not be completed for a single resource.
+func (r *Result) addResourceProblem(check string, resource azure.Resource, err error) {
+	r.addProblem(fmt.Sprintf("%s %s", resourceChecks[check], resource.Name), resource.Id, err)
+}
+
+func (r *Result) addProblem(check string, resourceId string, err error) {
+	problem := Problem{
+		SubscriptionId: r.SubscriptionId,
+		Check:          check,
+		ResourceId:     resourceId,
+		Kind:           azure.ErrorKindUnknown,
+		Message:        err.Error(),
+	}
+
+	var commandError *azure.CommandError
+	if errors.As(err, &commandError) {
+		problem.Kind = commandError.Kind
+		problem.Hint = commandError.Hint()
+	}
+
+	fmt.Println(fmt.Sprintf("Could not check %s: %s", check, azure.Describe(err)))
+	r.Problems = append(r.Problems, problem)
+}
+
+// fetchResources runs a fetcher and records a problem instead of failing when it errors. An empty map is returned
+// in that case so the rest of the checks and the reports can carry on.
+func (r *Result) fetchResources(settings Settings, resourceType string, check string, fetch func() (map[string]azure.Resource, error)) map[string]azure.Resource {
+	if !settings.Enabled(resourceType) {
+		fmt.Println(fmt.Sprintf("Skipping %s, disabled in the configuration.", check))
+		return make(map[string]azure.Resource)
+	}
+
+	resources, err := fetch()
+	if err != nil {
+		r.AddProblem(check, err)
+		return make(map[string]azure.Resource)
+	}
+
+	return resources
+}
+
+func (r *Result) assessPatches(client azure.Collector) {
+	vms := r.VirtualMachines
+	patchResults := make(chan azure.PatchResult, len(vms))
+	var wg sync.WaitGroup
+	wg.Add(len(vms))
+
+	fmt.Println(fmt.Sprintf("Created queue for %d VMs...", len(vms)))
+
+	for _, vm := range vms {
+		vm := vm // Don't remove this. It's for the iteration variable in the range loop. Otherwise you end up with the &vm below constantly pointing to the same object.
+		go client.AssessPatches(&vm, patchResults, &wg)
+	}
+
+	go func() {
+		defer close(patchResults)
+		wg.Wait()
+	}()
+
+	for patchResult := range patchResults {
+		if patchResult.Err != nil {
+			r.addResourceProblem(CheckPatches, *patchResult.VM, patchResult.Err)
+		}
+		vms[strings.ToLower(patchResult.VM.Id)] = *patchResult.VM
+	}
+}
+
+// Run collects every enabled resource type for a subscription and runs the enabled checks on them. Failures are
+// recorded as problems on the result rather than stopping the collection.
+func Run(client azure.Collector, subscriptionId string, settings Settings) Result {
+	result := Result{
+		SubscriptionId:             subscriptionId,
+		VirtualMachines:            make(map[string]azure.Resource),
+		VirtualMachinesDeallocated: make(map[string]azure.Resource),
+		AzureKubernetesServices:    make(map[string]azure.Resource),
+		MySQLServers:               make(map[string]azure.Resource),
+		FlexibleMySQLServers:       make(map[string]azure.Resource),
+		SqlServers:                 make(map[string]azure.Resource),
+		StorageAccounts:            make(map[string]azure.Resource),
+		WebApps:                    make(map[string]azure.Resource),
+		Recommendations:            make(map[string][]azure.AdvisorRecommendation),
+	}
+
+	err := azure.ValidateSubscriptionId(subscriptionId)
+	if err != nil {
+		result.AddProblem("subscription", err)
+		return result
+	}
+	fmt.Println(fmt.Sprintf("Checking subscription %s...", subscriptionId))
+
+	// Fetch resources
+	result.VirtualMachines = result.fetchResources(settings, VirtualMachines, "virtual machines", client.FetchVMs)
+	result.VirtualMachinesDeallocated = result.fetchResources(settings, VirtualMachinesDeallocated, "deallocated virtual machines", client.FetchDeallocatedVMs)
+	result.AzureKubernetesServices = result.fetchResources(settings, AKSClusters, "AKS clusters", client.FetchAKSClusters)
+	result.MySQLServers = result.fetchResources(settings, MySQLServers, "MySQL servers", client.FetchMySQLServers)
+	result.FlexibleMySQLServers = result.fetchResources(settings, FlexibleMySQLServers, "flexible MySQL servers", client.FetchFlexibleMySQLServers)
+	result.SqlServers = result.fetchResources(settings, SQLServers, "SQL servers", client.FetchSQLServers)
+	result.StorageAccounts = result.fetchResources(settings, StorageAccounts, "storage accounts", client.FetchStorageAccounts)
+	result.WebApps = result.fetchResources(settings, WebApps, "web apps", client.FetchWebApps)
+
+	if settings.Enabled(CheckAlertRules) {
+		alertRules, err := client.FetchAlertRules()
+		if err != nil {
+			result.AddProblem("alert rules", err)
+		} else {
+			result.AlertRules = alertRules
+
+			// Assign alert rules
+			azure.AssignAlertRulesToResources(alertRules, result.VirtualMachines)
+			azure.AssignAlertRulesToResources(alertRules, result.AzureKubernetesServices)
+			azure.AssignAlertRulesToResources(alertRules, result.MySQLServers)
+			azure.AssignAlertRulesToResources(alertRules, result.FlexibleMySQLServers)
+			azure.AssignAlertRulesToResources(alertRules, result.SqlServers)
+			azure.AssignAlertRulesToResources(alertRules, result.StorageAccounts)
+			azure.AssignAlertRulesToResources(alertRules, result.WebApps)
+		}
+	}
+
+	if settings.Enabled(CheckBackups) {
+		for vmId, err := range client.FetchVMBackups(result.VirtualMachines) {
+			result.addResourceProblem(CheckBackups, result.VirtualMachines[vmId], err)
+		}
+	}
+
+	if settings.Enabled(CheckRecommendations) {
+		recommendations, err := client.FetchAdvisorRecommendations()
+		if err != nil {
+			result.AddProblem("advisor recommendations", err)
+		} else {
+			result.Recommendations = recommendations
+		}
+	}
+
+	if settings.Enabled(CheckPatches) {
+		result.assessPatches(client)
+	}
+
+	return result
+}
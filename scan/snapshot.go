@@ -0,0 +1,124 @@
+package scan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the version of the snapshot format written by SaveSnapshot. It is raised whenever a field is
+// renamed, removed or changes meaning; new fields are added without raising it. See docs/snapshot.md.
+const SchemaVersion = 1
+
+// Snapshot is the scan data of a subscription saved next to the reports, so they can be rendered again, compared
+// with a later scan or read by other tools without querying Azure.
+type Snapshot struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	ToolVersion   string    `json:"toolVersion"`
+	ClientName    string    `json:"clientName"`
+	StartedAt     time.Time `json:"startedAt"`
+	FinishedAt    time.Time `json:"finishedAt"`
+	Settings      Settings  `json:"settings"`
+	Result        Result    `json:"result"`
+}
+
+func SaveSnapshot(path string, snapshot Snapshot) error {
+	snapshot.SchemaVersion = SchemaVersion
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+func LoadSnapshot(path string) (Snapshot, error) {
+	var result Snapshot
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return result, err
+	}
+
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return result, errors.New(fmt.Sprintf("%s is not a scan snapshot: %s", path, err.Error()))
+	}
+
+	if result.SchemaVersion == 0 {
+		return result, errors.New(fmt.Sprintf("%s is not a scan snapshot: schemaVersion is missing", path))
+	}
+	if result.SchemaVersion > SchemaVersion {
+		return result, errors.New(fmt.Sprintf("%s was written by a newer version of azure-checker (schema version %d, this version reads up to %d)", path, result.SchemaVersion, SchemaVersion))
+	}
+
+	return result, nil
+}
+
+// FindPreviousSnapshot looks in dir for the latest snapshot of the same client and subscription taken before
+// snapshot. It returns nil when there is none. Files that are not snapshots are skipped.
+func FindPreviousSnapshot(dir string, snapshot Snapshot) (*Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *Snapshot
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		candidate, err := LoadSnapshot(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		if candidate.ClientName != snapshot.ClientName ||
+			!strings.EqualFold(candidate.Result.SubscriptionId, snapshot.Result.SubscriptionId) ||
+			!candidate.StartedAt.Before(snapshot.StartedAt) {
+			continue
+		}
+
+		if result == nil || candidate.StartedAt.After(result.StartedAt) {
+			c := candidate
+			result = &c
+		}
+	}
+
+	return result, nil
+}
+
+// subscriptionChecks maps checks to the name their problem is recorded under when the check failed for the whole
+// subscription.
+var subscriptionChecks = map[string]string{
+	CheckAlertRules:      "alert rules",
+	CheckRecommendations: "advisor recommendations",
+}
+
+// Ran reports whether a check was switched on and completed for the subscription. The results of a check that did
+// not run would read as every resource failing it, or as everything being fine.
+func (s Snapshot) Ran(check string) bool {
+	if !s.Settings.Enabled(check) {
+		return false
+	}
+
+	for _, problem := range s.Result.Problems {
+		if problem.Check == subscriptionChecks[check] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// RanFor reports whether a check was switched on and completed for a resource. A check can fail for a single
+// resource, e.g. when the backup status of one VM could not be read, while it completed for the others.
+func (s Snapshot) RanFor(check string, resourceId string) bool {
+	return s.Ran(check) && !s.Result.Failed(check, resourceId)
+}
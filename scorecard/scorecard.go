@@ -0,0 +1,288 @@
+package scorecard
+
+import (
+	"fmt"
+
+	"github.com/jayps/azure-checker-go/rules"
+	"github.com/jayps/azure-checker-go/scan"
+)
+
+// RAG statuses.
+const (
+	Green = "Green"
+	Amber = "Amber"
+	Red   = "Red"
+)
+
+// Thresholds of the RAG statuses of the percentages and the overall score.
+const (
+	greenFrom = 90.0
+	amberFrom = 70.0
+)
+
+// Weights of the metrics in the overall score. Metrics that could not be measured are left out and the weights of the
+// others are scaled up, so a subscription without VMs is not marked down for their backups.
+const (
+	alertWeight          = 25.0
+	backupWeight         = 30.0
+	patchWeight          = 25.0
+	recommendationWeight = 20.0
+)
+
+// recommendationPenalty is taken off the advisor score of 100 for every open high-impact recommendation.
+const recommendationPenalty = 20.0
+
+// Scorecard is the compliance of one subscription at a glance. A metric is nil when its check was switched off or
+// could not be completed, or when there was nothing to measure. Accepted risks count as compliant, they were agreed
+// with the client.
+type Scorecard struct {
+	SubscriptionId            string
+	SubscriptionName          string
+	AlertCoverage             *float64 // percentage of monitored resources with alert rules
+	BackupCoverage            *float64 // percentage of running VMs that should be backed up and are
+	PatchCompliance           *float64 // percentage of running VMs without outstanding critical or security patches
+	HighImpactRecommendations *int     // open high-impact advisor recommendations
+	Score                     *float64 // weighted score out of 100
+	Status                    string   // Green, Amber or Red, empty when there is no score
+
+	// The counts behind the percentages, so scorecards can be combined. Nil when the check did not run.
+	alerts  *ratio
+	backups *ratio
+	patches *ratio
+}
+
+type ratio struct {
+	part  int
+	total int
+}
+
+func (r *ratio) percentage() *float64 {
+	if r == nil || r.total == 0 {
+		return nil
+	}
+
+	value := float64(r.part) * 100 / float64(r.total)
+	return &value
+}
+
+func (r *ratio) add(other *ratio) *ratio {
+	if other == nil {
+		return r
+	}
+	if r == nil {
+		return &ratio{part: other.part, total: other.total}
+	}
+
+	return &ratio{part: r.part + other.part, total: r.total + other.total}
+}
+
+// open reports whether the rule has a finding on the resource that was not accepted as a risk.
+func open(findings []rules.Finding, ruleId string, resourceId string, severity string) bool {
+	for _, finding := range rules.ForResource(findings, ruleId, resourceId) {
+		if !finding.Accepted() && (severity == "" || finding.Severity == severity) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StatusOf returns the RAG status of a percentage or score.
+func StatusOf(value float64) string {
+	if value >= greenFrom {
+		return Green
+	}
+	if value >= amberFrom {
+		return Amber
+	}
+
+	return Red
+}
+
+// RecommendationStatus returns the RAG status of a number of high-impact recommendations.
+func RecommendationStatus(count int) string {
+	switch {
+	case count == 0:
+		return Green
+	case count <= 2:
+		return Amber
+	}
+
+	return Red
+}
+
+// Compute scores a scan from its findings. Non-production resources are not expected to be monitored or backed up,
+// so they are left out of those percentages. So are the VMs whose backup status or patches could not be read.
+func Compute(snapshot scan.Snapshot, findings []rules.Finding, environments rules.Environments) Scorecard {
+	result := Scorecard{
+		SubscriptionId:   snapshot.Result.SubscriptionId,
+		SubscriptionName: snapshot.Result.SubscriptionName,
+	}
+
+	if snapshot.Ran(scan.CheckAlertRules) {
+		total, covered := 0, 0
+		for resourceType, resources := range snapshot.Result.Resources() {
+			// Deallocated VMs are not running, so nobody expects them to alert.
+			if resourceType == scan.VirtualMachinesDeallocated {
+				continue
+			}
+			for _, resource := range resources {
+				if environments.Classify(resource.Tags) == rules.EnvironmentNonProduction {
+					continue
+				}
+				total++
+				if !open(findings, rules.RuleNoAlertRules, resource.Id, "") {
+					covered++
+				}
+			}
+		}
+		result.alerts = &ratio{part: covered, total: total}
+	}
+
+	if snapshot.Ran(scan.CheckBackups) {
+		total, covered := 0, 0
+		for _, vm := range snapshot.Result.VirtualMachines {
+			if !snapshot.RanFor(scan.CheckBackups, vm.Id) || environments.Classify(vm.Tags) == rules.EnvironmentNonProduction {
+				continue
+			}
+			total++
+			if !open(findings, rules.RuleNotBackedUp, vm.Id, "") {
+				covered++
+			}
+		}
+		result.backups = &ratio{part: covered, total: total}
+	}
+
+	if snapshot.Ran(scan.CheckPatches) {
+		total, compliant := 0, 0
+		for _, vm := range snapshot.Result.VirtualMachines {
+			if !snapshot.RanFor(scan.CheckPatches, vm.Id) {
+				continue
+			}
+			total++
+			if !open(findings, rules.RuleOutstandingPatch, vm.Id, rules.SeverityHigh) {
+				compliant++
+			}
+		}
+		result.patches = &ratio{part: compliant, total: total}
+	}
+
+	if snapshot.Ran(scan.CheckRecommendations) {
+		count := 0
+		for _, finding := range findings {
+			if finding.RuleId == rules.RuleAdvisorRecommendation && finding.Severity == rules.SeverityHigh && !finding.Accepted() {
+				count++
+			}
+		}
+		result.HighImpactRecommendations = &count
+	}
+
+	return result.scored()
+}
+
+// Combine adds up the scorecards of several subscriptions, e.g. into the totals of a client.
+func Combine(name string, scorecards []Scorecard) Scorecard {
+	result := Scorecard{SubscriptionName: name}
+	for _, s := range scorecards {
+		result.alerts = result.alerts.add(s.alerts)
+		result.backups = result.backups.add(s.backups)
+		result.patches = result.patches.add(s.patches)
+		if s.HighImpactRecommendations != nil {
+			count := *s.HighImpactRecommendations
+			if result.HighImpactRecommendations != nil {
+				count += *result.HighImpactRecommendations
+			}
+			result.HighImpactRecommendations = &count
+		}
+	}
+
+	return result.scored()
+}
+
+func (s Scorecard) scored() Scorecard {
+	s.AlertCoverage = s.alerts.percentage()
+	s.BackupCoverage = s.backups.percentage()
+	s.PatchCompliance = s.patches.percentage()
+	s.Score = s.score()
+	s.Status = ""
+	if s.Score != nil {
+		s.Status = StatusOf(*s.Score)
+	}
+
+	return s
+}
+
+func (s Scorecard) score() *float64 {
+	total, weights := 0.0, 0.0
+	add := func(value *float64, weight float64) {
+		if value != nil {
+			total += *value * weight
+			weights += weight
+		}
+	}
+
+	add(s.AlertCoverage, alertWeight)
+	add(s.BackupCoverage, backupWeight)
+	add(s.PatchCompliance, patchWeight)
+	if s.HighImpactRecommendations != nil {
+		recommendations := 100 - recommendationPenalty*float64(*s.HighImpactRecommendations)
+		if recommendations < 0 {
+			recommendations = 0
+		}
+		add(&recommendations, recommendationWeight)
+	}
+
+	if weights == 0 {
+		return nil
+	}
+
+	score := total / weights
+	return &score
+}
+
+// Explanation describes how the scorecard is computed, for the footnote of the summary in the reports.
+func Explanation() string {
+	return "Alert coverage is the share of production and unclassified resources with alert rules, backup coverage the share of such running VMs that are backed up, " +
+		"and patch compliance the share of running VMs without outstanding critical or security patches. Accepted risks count as compliant. " +
+		fmt.Sprintf("The overall score weighs alerts %.0f%%, backups %.0f%%, patches %.0f%% and advisor recommendations %.0f%%, where every open high-impact recommendation takes %.0f points off. ",
+			alertWeight, backupWeight, patchWeight, recommendationWeight, recommendationPenalty) +
+		fmt.Sprintf("Green is %.0f or more, amber %.0f or more.", greenFrom, amberFrom)
+}
+
+// Metric is a scorecard value as it is shown in the reports. Status is empty when the value could not be measured.
+type Metric struct {
+	Name   string
+	Value  string
+	Status string
+}
+
+func percentageMetric(name string, value *float64) Metric {
+	if value == nil {
+		return Metric{Name: name, Value: "n/a"}
+	}
+
+	return Metric{Name: name, Value: fmt.Sprintf("%.0f%%", *value), Status: StatusOf(*value)}
+}
+
+// Metrics lists the values of the scorecard in the order they are reported, ending with the overall score.
+func (s Scorecard) Metrics() []Metric {
+	result := []Metric{
+		percentageMetric("Alert coverage", s.AlertCoverage),
+		percentageMetric("Backup coverage", s.BackupCoverage),
+		percentageMetric("Patch compliance", s.PatchCompliance),
+	}
+
+	recommendations := Metric{Name: "High-impact advisor recommendations", Value: "n/a"}
+	if s.HighImpactRecommendations != nil {
+		recommendations.Value = fmt.Sprintf("%d", *s.HighImpactRecommendations)
+		recommendations.Status = RecommendationStatus(*s.HighImpactRecommendations)
+	}
+	result = append(result, recommendations)
+
+	score := Metric{Name: "Overall score", Value: "n/a", Status: s.Status}
+	if s.Score != nil {
+		score.Value = fmt.Sprintf("%.0f", *s.Score)
+	}
+
+	return append(result, score)
+}
@@ -0,0 +1,207 @@
+package scorecard
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/jayps/azure-checker-go/azure"
+	"github.com/jayps/azure-checker-go/rules"
+	"github.com/jayps/azure-checker-go/scan"
+)
+
+func count(n int) *int {
+	return &n
+}
+
+// equal compares a metric with what is expected, nil meaning it is not measured.
+func equal(got *float64, want *float64) bool {
+	if got == nil || want == nil {
+		return got == nil && want == nil
+	}
+
+	return math.Abs(*got-*want) < 0.01
+}
+
+func value(v float64) *float64 {
+	return &v
+}
+
+func format(v *float64) string {
+	if v == nil {
+		return "nil"
+	}
+
+	return fmt.Sprintf("%.2f", *v)
+}
+
+func TestStatusOf(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, Red},
+		{69.99, Red},
+		{70, Amber},
+		{89.99, Amber},
+		{90, Green},
+		{100, Green},
+	}
+
+	for _, test := range tests {
+		if got := StatusOf(test.value); got != test.want {
+			t.Errorf("StatusOf(%v) = %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name      string
+		scorecard Scorecard
+		score     *float64
+		status    string
+	}{
+		{"nothing measured", Scorecard{}, nil, ""},
+		{"nothing to measure", Scorecard{alerts: &ratio{}, backups: &ratio{}}, nil, ""},
+		{"all metrics", Scorecard{alerts: &ratio{9, 10}, backups: &ratio{8, 10}, patches: &ratio{10, 10}, HighImpactRecommendations: count(1)},
+			value((90*alertWeight + 80*backupWeight + 100*patchWeight + 80*recommendationWeight) / 100), Amber},
+		{"green at 90", Scorecard{alerts: &ratio{9, 10}}, value(90), Green},
+		{"amber at 70", Scorecard{alerts: &ratio{7, 10}}, value(70), Amber},
+		{"red below 70", Scorecard{alerts: &ratio{69, 100}}, value(69), Red},
+		// Without VMs the backup and patch weights are left out and the others scaled up to 100.
+		{"backups and patches not measured", Scorecard{alerts: &ratio{1, 2}, backups: &ratio{}, HighImpactRecommendations: count(0)},
+			value((50*alertWeight + 100*recommendationWeight) / (alertWeight + recommendationWeight)), Amber},
+		{"only backups", Scorecard{backups: &ratio{1, 4}}, value(25), Red},
+		{"penalty", Scorecard{HighImpactRecommendations: count(3)}, value(100 - 3*recommendationPenalty), Red},
+		{"penalty floored at 0", Scorecard{HighImpactRecommendations: count(6)}, value(0), Red},
+		{"floored penalty still weighs", Scorecard{alerts: &ratio{1, 1}, HighImpactRecommendations: count(10)},
+			value(100 * alertWeight / (alertWeight + recommendationWeight)), Red},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.scorecard.scored()
+
+			if !equal(got.Score, test.score) {
+				t.Errorf("got score %s, want %s", format(got.Score), format(test.score))
+			}
+			if got.Status != test.status {
+				t.Errorf("got status %q, want %q", got.Status, test.status)
+			}
+		})
+	}
+}
+
+func TestCombine(t *testing.T) {
+	tests := []struct {
+		name            string
+		scorecards      []Scorecard
+		alerts          *float64
+		backups         *float64
+		recommendations *int
+	}{
+		{
+			// A large subscription weighs more than a small one: 2 of 10, not the average of 100% and 11%.
+			name:       "adds the counts",
+			scorecards: []Scorecard{{alerts: &ratio{1, 1}}, {alerts: &ratio{1, 9}}},
+			alerts:     value(20),
+		},
+		{
+			name:            "metric missing in one subscription",
+			scorecards:      []Scorecard{{alerts: &ratio{3, 4}, HighImpactRecommendations: count(2)}, {backups: &ratio{1, 2}}},
+			alerts:          value(75),
+			backups:         value(50),
+			recommendations: count(2),
+		},
+		{
+			name:            "recommendations are added",
+			scorecards:      []Scorecard{{HighImpactRecommendations: count(2)}, {HighImpactRecommendations: count(0)}, {HighImpactRecommendations: count(3)}},
+			recommendations: count(5),
+		},
+		{
+			name:       "nothing measured",
+			scorecards: []Scorecard{{}, {}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var scored []Scorecard
+			for _, s := range test.scorecards {
+				scored = append(scored, s.scored())
+			}
+			got := Combine("Acme", scored)
+
+			if !equal(got.AlertCoverage, test.alerts) {
+				t.Errorf("got alert coverage %s, want %s", format(got.AlertCoverage), format(test.alerts))
+			}
+			if !equal(got.BackupCoverage, test.backups) {
+				t.Errorf("got backup coverage %s, want %s", format(got.BackupCoverage), format(test.backups))
+			}
+			if (got.HighImpactRecommendations == nil) != (test.recommendations == nil) ||
+				(got.HighImpactRecommendations != nil && *got.HighImpactRecommendations != *test.recommendations) {
+				t.Errorf("got recommendations %v, want %v", got.HighImpactRecommendations, test.recommendations)
+			}
+			if got.SubscriptionName != "Acme" {
+				t.Errorf("got name %q", got.SubscriptionName)
+			}
+		})
+	}
+}
+
+const vmIdPrefix = "/subscriptions/00000000-0000-0000-0000-000000000001/resourcegroups/rg-web/providers/microsoft.compute/virtualmachines/"
+
+func vm(t *testing.T, name string, source string) azure.Resource {
+	t.Helper()
+
+	var resource azure.Resource
+	err := json.Unmarshal([]byte(source), &resource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resource.Id = vmIdPrefix + name
+	resource.Name = name
+
+	return resource
+}
+
+func TestComputeLeavesOutFailedVMs(t *testing.T) {
+	// vm-ok is backed up and patched, the others are neither.
+	protected := `{"backupVault": {"id": "vault", "name": "vault-web"}}`
+	unprotected := `{"patchAssessmentResult": {"availablePatches": [{"patchId": "p-1", "classifications": ["Security"]}]}}`
+	vms := map[string]azure.Resource{}
+	for name, source := range map[string]string{"vm-ok": protected, "vm-bad": unprotected, "vm-failed": unprotected} {
+		resource := vm(t, name, source)
+		vms[resource.Id] = resource
+	}
+	failedId := vmIdPrefix + "vm-failed"
+
+	tests := []struct {
+		name     string
+		problems []scan.Problem
+		backups  *float64
+		patches  *float64
+	}{
+		{"every check ran", nil, value(100.0 / 3), value(100.0 / 3)},
+		{"backup check failed for a VM", []scan.Problem{{Check: "backups for VM vm-failed", ResourceId: failedId}}, value(50), value(100.0 / 3)},
+		{"patch check failed for a VM", []scan.Problem{{Check: "patches for VM vm-failed", ResourceId: failedId}}, value(100.0 / 3), value(50)},
+		{"both failed for a VM", []scan.Problem{{Check: "backups for VM vm-failed", ResourceId: failedId}, {Check: "patches for VM vm-failed", ResourceId: failedId}}, value(50), value(50)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			snapshot := scan.Snapshot{Result: scan.Result{VirtualMachines: vms, Problems: test.problems}}
+			findings := rules.Evaluate(rules.Builtin, snapshot, rules.Environments{})
+			got := Compute(snapshot, findings, rules.Environments{})
+
+			if !equal(got.BackupCoverage, test.backups) {
+				t.Errorf("got backup coverage %s, want %s", format(got.BackupCoverage), format(test.backups))
+			}
+			if !equal(got.PatchCompliance, test.patches) {
+				t.Errorf("got patch compliance %s, want %s", format(got.PatchCompliance), format(test.patches))
+			}
+		})
+	}
+}